@@ -1,22 +1,203 @@
 package config
 
 import (
+	"bufio"
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/topology"
 )
 
 type Config struct {
 	ListenAddr           string
+	AdminAddr            string // separate listener for health and poller admin endpoints
 	DBPath               string
+	DBMaxOpenConns       int
+	DBMaxIdleConns       int
+	ArchiveOnRemove      bool // keep removed PRs in the archive tables
 	GitHubToken          string
+	GitHubRepo           string       // "owner/name"
+	Repos                []RepoConfig // repositories tracked besides GitHubRepo
+	GitHubAPIVersion     string
+	GitHubWebhookSecret  string // enables POST /api/github/webhook
 	WebhookURL           string
+	WebhookFormat        string
+	WebhookSecret        string
+	WebhookTimeout       time.Duration
+	WebhookRetries       int
+	WebhookBatch         time.Duration // 0 sends each event on its own
+	WebhookInsecure      bool          // skip TLS verification of the webhook receiver
+	InstanceName         string
 	PollInterval         time.Duration
+	PollTimeout          time.Duration // 0 means PollInterval
+	CompareTimeout       time.Duration // 0 means bounded only by PollTimeout
+	BranchConcurrency    int
+	PollErrorBudget      int
+	RateReserve          int // GitHub requests kept for open PRs; 0 disables
+	DeferFirstPoll       bool
+	PollJitter           int // percent
 	TargetBranches       []string
 	NotificationBranches []string
+	Stages               []string // ordered pipeline over notification branches
+	NotifyOnAdd          bool
+	NotifyEvents         []string // event types to notify for; empty means all
+	SuppressAuthor       string   // GitHub login whose PRs don't notify
+	SuppressAuthorEvents []string // event types suppressed for SuppressAuthor; empty means all
+	PersistEvents        []string // event types recorded in the events log; empty means all
+	TrackPaths           []string // paths a PR must touch to be added; empty means any
+	HTTPProxy            string
+	LandingFallbackAfter time.Duration
+	ChannelRevisionURL   string   // contains "{branch}"
+	LandedStatuses       []string // compare statuses that count as landed
+	DesktopNotify        bool
+	EventFile            string
+	EventFileMaxSize     int64
+	NATSURL              string
+	NATSSubject          string
+	NotifyChecks         bool
+	CommentOnLand        bool // needs a GitHubToken that can write to pull requests
+	GroupLandings        bool // one pr_landed_branch per PR and poll
+	NotifyIncludeBody    bool
+	EventRetention       time.Duration
+	SummaryAt            string // "HH:MM" local time of the daily summary; empty disables
+	PruneClosedAfter     time.Duration
+	ReopenCheckInterval  time.Duration
+	LandedRetention      time.Duration
+	VerifyBranches       string // "off", "warn" or "fail"
+	ReadOnly             bool
+	APIToken             string   // bearer token required on API writes
+	CORSOrigins          []string // browser origins allowed to call the API
+	IndexCacheTTL        time.Duration
+	PRFailureThreshold   int
+	RemoveFailingPRs     bool
+}
+
+// RepoConfig is a repository from NPT_REPOS, tracked alongside GitHubRepo
+// with its own branches.
+type RepoConfig struct {
+	Repo     string   // "owner/name"
+	Branches []string // notification and target branches
+}
+
+// secretFields are the Config fields Redacted hides: tokens and keys, and
+// URLs that may carry credentials.
+var secretFields = map[string]bool{
+	"APIToken":            true,
+	"GitHubToken":         true,
+	"GitHubWebhookSecret": true,
+	"WebhookURL":          true,
+	"WebhookSecret":       true,
+	"NATSURL":             true,
+	"HTTPProxy":           true,
+}
+
+// secretVars are the variables behind secretFields. Each can instead be
+// read from a file, as systemd credentials and Docker secrets provide, by
+// naming it in the variable with a _FILE suffix; see secretEnv.
+var secretVars = []string{
+	"NPT_API_TOKEN",
+	"NPT_GITHUB_TOKEN",
+	"NPT_GITHUB_WEBHOOK_SECRET",
+	"NPT_WEBHOOK_URL",
+	"NPT_WEBHOOK_SECRET",
+	"NPT_NATS_URL",
+	"NPT_HTTP_PROXY",
+}
+
+// secretEnv returns the secret variable name: the contents of the file
+// named by name+"_FILE", trimmed of surrounding whitespace such as a
+// trailing newline, if that is set, and otherwise the variable itself.
+func secretEnv(name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Redacted returns c as field names mapped to values, safe to share: set
+// secrets read "***" and durations are formatted like "5m0s".
+func (c Config) Redacted() map[string]any {
+	v := reflect.ValueOf(c)
+	out := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Name
+		switch f := v.Field(i).Interface().(type) {
+		case string:
+			if secretFields[name] && f != "" {
+				f = "***"
+			}
+			out[name] = f
+		case time.Duration:
+			out[name] = f.String()
+		default:
+			out[name] = f
+		}
+	}
+	return out
+}
+
+// parseEventTypes splits a comma-separated list of event type names, as
+// parseBranches does, and rejects unknown ones. name is the variable the
+// list came from, for the error message.
+func parseEventTypes(name, v string) ([]string, error) {
+	known := make(map[string]bool, len(event.Types))
+	for _, t := range event.Types {
+		known[string(t)] = true
+	}
+	var types []string
+	for _, t := range parseBranches(v) {
+		if !known[t] {
+			return nil, fmt.Errorf("%s: unknown event type %q", name, t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// validRepo reports whether v has the "owner/name" form of a repository.
+func validRepo(v string) bool {
+	owner, name, ok := strings.Cut(v, "/")
+	return ok && owner != "" && name != "" && !strings.Contains(name, "/")
+}
+
+// parseRepos parses NPT_REPOS: semicolon-separated "owner/name=branch,..."
+// entries, e.g. "owner/a=main;owner/b=main,release". primary is
+// NPT_GITHUB_REPO, which can't be listed again.
+func parseRepos(v, primary string) ([]RepoConfig, error) {
+	var repos []RepoConfig
+	seen := map[string]bool{primary: true}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		repo, branches, ok := strings.Cut(entry, "=")
+		repo = strings.TrimSpace(repo)
+		if !ok || !validRepo(repo) {
+			return nil, fmt.Errorf("NPT_REPOS: entry %q must be \"owner/name=branch,...\"", entry)
+		}
+		if seen[repo] {
+			return nil, fmt.Errorf("NPT_REPOS: %s is listed twice or is NPT_GITHUB_REPO", repo)
+		}
+		seen[repo] = true
+		rc := RepoConfig{Repo: repo, Branches: parseBranches(branches)}
+		if len(rc.Branches) == 0 {
+			return nil, fmt.Errorf("NPT_REPOS: %s has no branches", repo)
+		}
+		repos = append(repos, rc)
+	}
+	return repos, nil
 }
 
 // parseBranches splits a comma-separated string into branch names,
@@ -35,29 +216,324 @@ func parseBranches(s string) []string {
 
 func Load() (Config, error) {
 	cfg := Config{
-		ListenAddr:   ":8585",
-		DBPath:       "./tracker.db",
-		PollInterval: 5 * time.Minute,
+		ListenAddr:        ":8585",
+		DBPath:            "./tracker.db",
+		GitHubRepo:        "NixOS/nixpkgs",
+		LandedStatuses:    []string{"behind", "identical"},
+		IndexCacheTTL:     5 * time.Second,
+		GitHubAPIVersion:  "2022-11-28",
+		WebhookFormat:     "flat",
+		WebhookTimeout:    10 * time.Second,
+		NATSSubject:       "nixpkgs-pr-tracker",
+		PollInterval:      5 * time.Minute,
+		BranchConcurrency: 4,
+		NotifyOnAdd:       true,
+		EventRetention:    30 * 24 * time.Hour,
+		VerifyBranches:    "off",
+	}
+
+	secrets := make(map[string]string, len(secretVars))
+	for _, name := range secretVars {
+		v, err := secretEnv(name)
+		if err != nil {
+			return cfg, err
+		}
+		secrets[name] = v
 	}
 
 	if v := os.Getenv("NPT_LISTEN_ADDR"); v != "" {
 		cfg.ListenAddr = v
 	}
+	if v := os.Getenv("NPT_ADMIN_ADDR"); v != "" {
+		cfg.AdminAddr = v
+	}
 	if v := os.Getenv("NPT_DB_PATH"); v != "" {
 		cfg.DBPath = v
 	}
-	if v := os.Getenv("NPT_GITHUB_TOKEN"); v != "" {
+	if v := os.Getenv("NPT_DB_MAX_OPEN_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.DBMaxOpenConns = n
+		}
+	}
+	if v := os.Getenv("NPT_DB_MAX_IDLE_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.DBMaxIdleConns = n
+		}
+	}
+	if v := os.Getenv("NPT_ARCHIVE_ON_REMOVE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.ArchiveOnRemove = b
+		}
+	}
+	if v := secrets["NPT_GITHUB_TOKEN"]; v != "" {
 		cfg.GitHubToken = v
 	}
-	if v := os.Getenv("NPT_WEBHOOK_URL"); v != "" {
+	if v := os.Getenv("NPT_GITHUB_REPO"); v != "" {
+		if !validRepo(v) {
+			return cfg, fmt.Errorf("NPT_GITHUB_REPO must be \"owner/name\", got %q", v)
+		}
+		cfg.GitHubRepo = v
+	}
+	if v := os.Getenv("NPT_REPOS"); v != "" {
+		repos, err := parseRepos(v, cfg.GitHubRepo)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Repos = repos
+	}
+	if v := os.Getenv("NPT_GITHUB_API_VERSION"); v != "" {
+		cfg.GitHubAPIVersion = v
+	}
+	if v := secrets["NPT_API_TOKEN"]; v != "" {
+		cfg.APIToken = v
+	}
+	if v := os.Getenv("NPT_CORS_ORIGINS"); v != "" {
+		cfg.CORSOrigins = parseBranches(v)
+	}
+	if v := secrets["NPT_GITHUB_WEBHOOK_SECRET"]; v != "" {
+		cfg.GitHubWebhookSecret = v
+	}
+	if v := secrets["NPT_WEBHOOK_URL"]; v != "" {
 		cfg.WebhookURL = v
 	}
+	if v := secrets["NPT_WEBHOOK_SECRET"]; v != "" {
+		cfg.WebhookSecret = v
+	}
+	if v := os.Getenv("NPT_WEBHOOK_FORMAT"); v != "" {
+		if v != "flat" && v != "cloudevents" {
+			return cfg, fmt.Errorf("NPT_WEBHOOK_FORMAT must be \"flat\" or \"cloudevents\", got %q", v)
+		}
+		cfg.WebhookFormat = v
+	}
+	if v := os.Getenv("NPT_VERIFY_BRANCHES"); v != "" {
+		if v != "off" && v != "warn" && v != "fail" {
+			return cfg, fmt.Errorf("NPT_VERIFY_BRANCHES must be \"off\", \"warn\" or \"fail\", got %q", v)
+		}
+		cfg.VerifyBranches = v
+	}
+	if v := os.Getenv("NPT_INSTANCE_NAME"); v != "" {
+		cfg.InstanceName = v
+	}
+	if v := secrets["NPT_HTTP_PROXY"]; v != "" {
+		// The value may carry credentials, so it is left out of the error.
+		if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+			return cfg, fmt.Errorf("NPT_HTTP_PROXY must be a URL such as http://proxy:3128")
+		}
+		cfg.HTTPProxy = v
+	}
 	if v := os.Getenv("NPT_POLL_INTERVAL"); v != "" {
 		if d, err := time.ParseDuration(v); err == nil {
 			cfg.PollInterval = d
 		}
 	}
 
+	if v := os.Getenv("NPT_POLL_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.PollTimeout = d
+		}
+	}
+	if v := os.Getenv("NPT_COMPARE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.CompareTimeout = d
+		}
+	}
+	if v := os.Getenv("NPT_BRANCH_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.BranchConcurrency = n
+		}
+	}
+	if v := os.Getenv("NPT_POLL_ERROR_BUDGET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.PollErrorBudget = n
+		}
+	}
+	if v := os.Getenv("NPT_RATE_LIMIT_RESERVE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.RateReserve = n
+		}
+	}
+	if v := os.Getenv("NPT_DEFER_FIRST_POLL"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.DeferFirstPoll = b
+		}
+	}
+	if v := os.Getenv("NPT_POLL_JITTER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n < 100 {
+			cfg.PollJitter = n
+		}
+	}
+	if v := os.Getenv("NPT_WEBHOOK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.WebhookTimeout = d
+		}
+	}
+	if v := os.Getenv("NPT_WEBHOOK_INSECURE_SKIP_VERIFY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.WebhookInsecure = b
+		}
+	}
+	if v := os.Getenv("NPT_WEBHOOK_BATCH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.WebhookBatch = d
+		}
+	}
+	if v := os.Getenv("NPT_WEBHOOK_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.WebhookRetries = n
+		}
+	}
+	if v := os.Getenv("NPT_LANDING_FALLBACK_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.LandingFallbackAfter = d
+		}
+	}
+	if v := os.Getenv("NPT_CHANNEL_REVISION_URL"); v != "" {
+		if !strings.Contains(v, "{branch}") {
+			return cfg, fmt.Errorf("NPT_CHANNEL_REVISION_URL must contain {branch}, got %q", v)
+		}
+		cfg.ChannelRevisionURL = v
+	}
+	if v := os.Getenv("NPT_LANDED_STATUSES"); v != "" {
+		var statuses []string
+		for _, st := range parseBranches(v) {
+			switch st {
+			case "ahead", "behind", "identical", "diverged":
+				statuses = append(statuses, st)
+			default:
+				return cfg, fmt.Errorf("NPT_LANDED_STATUSES: unknown compare status %q (want ahead, behind, identical or diverged)", st)
+			}
+		}
+		if len(statuses) == 0 {
+			return cfg, fmt.Errorf("NPT_LANDED_STATUSES is set but contains no statuses")
+		}
+		cfg.LandedStatuses = statuses
+	}
+	if v := os.Getenv("NPT_EVENT_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.EventRetention = d
+		}
+	}
+	if v := os.Getenv("NPT_SUMMARY_AT"); v != "" {
+		if _, err := time.Parse("15:04", v); err != nil {
+			return cfg, fmt.Errorf("NPT_SUMMARY_AT must be a time of day as \"HH:MM\", got %q", v)
+		}
+		cfg.SummaryAt = v
+	}
+	if v := os.Getenv("NPT_PRUNE_CLOSED_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PruneClosedAfter = d
+		}
+	}
+	if v := os.Getenv("NPT_REOPEN_CHECK_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.ReopenCheckInterval = d
+		}
+	}
+	if v := os.Getenv("NPT_LANDED_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.LandedRetention = d
+		}
+	}
+	if v := os.Getenv("NPT_PR_FAILURE_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.PRFailureThreshold = n
+		}
+	}
+	if v := os.Getenv("NPT_REMOVE_FAILING_PRS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.RemoveFailingPRs = b
+		}
+	}
+	if v := os.Getenv("NPT_EVENT_FILE"); v != "" {
+		cfg.EventFile = v
+	}
+	if v := os.Getenv("NPT_EVENT_FILE_MAX_SIZE"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.EventFileMaxSize = n
+		}
+	}
+	if v := secrets["NPT_NATS_URL"]; v != "" {
+		cfg.NATSURL = v
+	}
+	if v := os.Getenv("NPT_NATS_SUBJECT"); v != "" {
+		cfg.NATSSubject = v
+	}
+	if v := os.Getenv("NPT_DESKTOP_NOTIFY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.DesktopNotify = b
+		}
+	}
+	if v := os.Getenv("NPT_NOTIFY_CHECKS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.NotifyChecks = b
+		}
+	}
+	if v := os.Getenv("NPT_GROUP_LANDINGS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.GroupLandings = b
+		}
+	}
+	if v := os.Getenv("NPT_COMMENT_ON_LAND"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.CommentOnLand = b
+		}
+	}
+	if cfg.CommentOnLand && cfg.GitHubToken == "" {
+		return cfg, fmt.Errorf("NPT_COMMENT_ON_LAND requires NPT_GITHUB_TOKEN with write access to pull requests")
+	}
+	if v := os.Getenv("NPT_NOTIFY_INCLUDE_BODY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.NotifyIncludeBody = b
+		}
+	}
+	if v := os.Getenv("NPT_INDEX_CACHE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.IndexCacheTTL = d
+		}
+	}
+	if v := os.Getenv("NPT_READ_ONLY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.ReadOnly = b
+		}
+	}
+	if v := os.Getenv("NPT_NOTIFY_ON_ADD"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.NotifyOnAdd = b
+		}
+	}
+
+	if v := os.Getenv("NPT_NOTIFY_EVENTS"); v != "" {
+		types, err := parseEventTypes("NPT_NOTIFY_EVENTS", v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.NotifyEvents = types
+	}
+	if v := os.Getenv("NPT_SUPPRESS_AUTHOR"); v != "" {
+		cfg.SuppressAuthor = strings.TrimPrefix(strings.TrimSpace(v), "@")
+	}
+	if v := os.Getenv("NPT_SUPPRESS_AUTHOR_EVENTS"); v != "" {
+		types, err := parseEventTypes("NPT_SUPPRESS_AUTHOR_EVENTS", v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.SuppressAuthorEvents = types
+	}
+	if v := os.Getenv("NPT_PERSIST_EVENTS"); v != "" {
+		types, err := parseEventTypes("NPT_PERSIST_EVENTS", v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.PersistEvents = types
+	}
+	if v := os.Getenv("NPT_TRACK_PATHS"); v != "" {
+		for _, path := range parseBranches(v) {
+			if path = strings.Trim(path, "/"); path != "" {
+				cfg.TrackPaths = append(cfg.TrackPaths, path)
+			}
+		}
+	}
+
 	if v := os.Getenv("NPT_TARGET_BRANCHES"); v != "" {
 		cfg.TargetBranches = parseBranches(v)
 	}
@@ -93,10 +569,24 @@ func Load() (Config, error) {
 		return cfg, fmt.Errorf("target branches %v are not in NPT_NOTIFICATION_BRANCHES; they would never be checked", missing)
 	}
 
+	if v := os.Getenv("NPT_STAGES"); v != "" {
+		cfg.Stages = parseBranches(v)
+		var unchecked []string
+		for _, b := range cfg.Stages {
+			if !notifSet[b] {
+				unchecked = append(unchecked, b)
+			}
+		}
+		if len(unchecked) > 0 {
+			return cfg, fmt.Errorf("stages %v are not in NPT_NOTIFICATION_BRANCHES; they would never be checked", unchecked)
+		}
+	}
+
 	return cfg, nil
 }
 
-// ValidateBranches checks that all branches are in topology.KnownBranches.
+// ValidateBranches checks that all branches are in topology.KnownBranches or
+// are fully-qualified refs (refs/heads/..., refs/tags/...).
 func ValidateBranches(branches []string) error {
 	known := make(map[string]bool, len(topology.KnownBranches))
 	for _, b := range topology.KnownBranches {
@@ -104,7 +594,7 @@ func ValidateBranches(branches []string) error {
 	}
 	var unknown []string
 	for _, b := range branches {
-		if !known[b] {
+		if !known[b] && !isFullRef(b) {
 			unknown = append(unknown, b)
 		}
 	}
@@ -113,3 +603,48 @@ func ValidateBranches(branches []string) error {
 	}
 	return nil
 }
+
+// isFullRef reports whether b is a fully-qualified git ref such as
+// "refs/heads/staging-next" or "refs/tags/24.11". Full refs are passed to
+// GitHub as-is, so they may name branches or tags outside the known topology.
+func isFullRef(b string) bool {
+	rest, ok := strings.CutPrefix(b, "refs/")
+	if !ok || rest == "" || strings.HasSuffix(rest, "/") {
+		return false
+	}
+	return !strings.Contains(rest, "..") && !strings.ContainsAny(rest, " ~^:?*[\\")
+}
+
+// LoadEnvFile reads KEY=VALUE lines (systemd EnvironmentFile syntax: blank
+// lines and # comments are skipped, values may be quoted) and sets them in
+// the process environment, so a following Load picks them up.
+func LoadEnvFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -10,11 +13,15 @@ import (
 
 type TrackedPR struct {
 	ID            int
+	Repo          string // "owner/name" for PRs in an NPT_REPOS repository; empty for NPT_GITHUB_REPO
 	PRNumber      int
 	Title         string
 	Author        string
 	Status        string
 	MergeCommit   string
+	BaseRef       string    // branch the PR targets; empty until first polled
+	MergedAt      time.Time // when GitHub merged the PR; zero until known
+	Body          string    // truncated description, only kept with NPT_NOTIFY_INCLUDE_BODY
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	LastCheckedAt time.Time
@@ -25,26 +32,181 @@ type BranchStatus struct {
 	Branch   string
 	Landed   bool
 	LandedAt *time.Time
+
+	// LastStatus is the compare status ("ahead", "behind", ...) of the
+	// merge commit against the branch at the last check; empty if the
+	// branch hasn't been checked since it was recorded.
+	LastStatus string
+}
+
+// TrackedCommit is a bare commit tracked by SHA, independent of any PR.
+type TrackedCommit struct {
+	ID            int
+	SHA           string
+	Title         string
+	CreatedAt     time.Time
+	LastCheckedAt time.Time
+	Branches      []BranchStatus
+}
+
+// EventRecord is a persisted event.Event from the events log.
+type EventRecord struct {
+	ID        int
+	Repo      string
+	Type      string
+	PRNumber  int
+	Title     string
+	Author    string
+	Branch    string
+	Commit    string
+	CreatedAt time.Time
+}
+
+// ArchivedPR is a removed PR kept in the archive, with the branch statuses
+// it had when it was removed. ID identifies the archive entry, not the
+// tracked_prs row it came from.
+type ArchivedPR struct {
+	TrackedPR
+	ArchivedAt time.Time
 }
 
+// ErrNotFound is returned when the requested PR or commit is not tracked.
+var ErrNotFound = errors.New("not found")
+
 type DB struct {
 	db *sql.DB
+
+	// repo scopes every PR and commit query; empty is the primary
+	// repository. See ForRepo.
+	repo string
+
+	// Statements on the poller and server hot paths, prepared once in New.
+	getPRStmt              *sql.Stmt
+	getBranchStatusStmt    *sql.Stmt
+	updateBranchLandedStmt *sql.Stmt
+
+	maxOpenConns    int
+	maxIdleConns    int
+	archiveOnRemove bool
+}
+
+// ForRepo returns a view of d whose PRs and commits belong to repo, given as
+// "owner/name". The view shares d's connections and prepared statements, so
+// closing d closes it too; don't Close the view itself. The events log and
+// the archive are shared by all repositories.
+func (d *DB) ForRepo(repo string) *DB {
+	rd := *d
+	rd.repo = repo
+	return &rd
+}
+
+// Repo returns the repository d is scoped to, empty for the primary one.
+func (d *DB) Repo() string {
+	return d.repo
+}
+
+// Option configures optional DB behavior.
+type Option func(*DB)
+
+// WithMaxOpenConns limits the number of open connections to the database.
+// Zero or less means no limit.
+func WithMaxOpenConns(n int) Option {
+	return func(d *DB) {
+		d.maxOpenConns = n
+	}
 }
 
-func New(path string) (*DB, error) {
-	sqlDB, err := sql.Open("sqlite", path)
+// WithMaxIdleConns sets how many idle connections are kept for reuse.
+// Zero or less keeps database/sql's default.
+func WithMaxIdleConns(n int) Option {
+	return func(d *DB) {
+		d.maxIdleConns = n
+	}
+}
+
+// WithArchiveOnRemove makes RemovePR move the PR and its branch statuses
+// into the archive tables instead of deleting them.
+func WithArchiveOnRemove() Option {
+	return func(d *DB) {
+		d.archiveOnRemove = true
+	}
+}
+
+// busyTimeout is how long a connection waits for another's write lock
+// before failing with SQLITE_BUSY. The poller, API and event recorder all
+// write from their own goroutines.
+const busyTimeout = 5 * time.Second
+
+// withBusyTimeout adds busyTimeout to path as a _pragma query parameter, so
+// it applies to every connection in the pool, unless path sets one already.
+func withBusyTimeout(path string) string {
+	if strings.Contains(path, "busy_timeout") {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=busy_timeout(" + strconv.FormatInt(busyTimeout.Milliseconds(), 10) + ")"
+}
+
+func New(path string, opts ...Option) (*DB, error) {
+	sqlDB, err := sql.Open("sqlite", withBusyTimeout(path))
 	if err != nil {
 		return nil, err
 	}
 	d := &DB{db: sqlDB}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.maxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(d.maxOpenConns)
+	}
+	if d.maxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(d.maxIdleConns)
+	}
+
 	if err := d.migrate(); err != nil {
 		sqlDB.Close()
 		return nil, err
 	}
+	if err := d.prepare(); err != nil {
+		d.Close()
+		return nil, err
+	}
 	return d, nil
 }
 
+// prepare prepares the statements reused across calls. It must run after
+// migrate, since the statements reference migrated columns.
+func (d *DB) prepare() error {
+	var err error
+	if d.getPRStmt, err = d.db.Prepare(
+		`SELECT id, repo, pr_number, title, author, status, merge_commit, base_ref, merged_at, body, created_at, updated_at, last_checked_at FROM tracked_prs WHERE repo = ? AND pr_number = ?`,
+	); err != nil {
+		return err
+	}
+	if d.getBranchStatusStmt, err = d.db.Prepare(
+		`SELECT branch, landed, landed_at, last_status FROM branch_status WHERE repo = ? AND pr_number = ?`,
+	); err != nil {
+		return err
+	}
+	if d.updateBranchLandedStmt, err = d.db.Prepare(
+		`INSERT INTO branch_status (repo, pr_number, branch, landed, landed_at) VALUES (?, ?, ?, 1, CURRENT_TIMESTAMP)
+		 ON CONFLICT(repo, pr_number, branch) DO UPDATE SET landed = 1, landed_at = CURRENT_TIMESTAMP
+		 WHERE branch_status.landed = 0`,
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (d *DB) Close() error {
+	for _, stmt := range []*sql.Stmt{d.getPRStmt, d.getBranchStatusStmt, d.updateBranchLandedStmt} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
 	return d.db.Close()
 }
 
@@ -94,17 +256,255 @@ func (d *DB) migrate() error {
 		}
 	}
 
+	if version < 3 {
+		log.Printf("db: migrating schema to version 3 (add tracked_commits)")
+		if _, err := d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS tracked_commits (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				sha             TEXT UNIQUE NOT NULL,
+				title           TEXT NOT NULL DEFAULT '',
+				created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+				last_checked_at DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00'
+			);
+
+			CREATE TABLE IF NOT EXISTS commit_branch_status (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				sha         TEXT NOT NULL,
+				branch      TEXT NOT NULL,
+				landed      BOOLEAN NOT NULL DEFAULT 0,
+				landed_at   DATETIME,
+				UNIQUE(sha, branch),
+				FOREIGN KEY (sha) REFERENCES tracked_commits(sha)
+			);
+
+			PRAGMA user_version = 3;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 4 {
+		log.Printf("db: migrating schema to version 4 (add events)")
+		if _, err := d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS events (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				type        TEXT NOT NULL,
+				pr_number   INTEGER NOT NULL DEFAULT 0,
+				title       TEXT NOT NULL DEFAULT '',
+				author      TEXT NOT NULL DEFAULT '',
+				branch      TEXT NOT NULL DEFAULT '',
+				commit_sha  TEXT NOT NULL DEFAULT '',
+				created_at  DATETIME NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
+
+			PRAGMA user_version = 4;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 5 {
+		log.Printf("db: migrating schema to version 5 (add base_ref)")
+		if _, err := d.db.Exec(`
+			ALTER TABLE tracked_prs ADD COLUMN base_ref TEXT NOT NULL DEFAULT '';
+			PRAGMA user_version = 5;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 6 {
+		log.Printf("db: migrating schema to version 6 (add branch_status.last_status)")
+		if _, err := d.db.Exec(`
+			ALTER TABLE branch_status ADD COLUMN last_status TEXT NOT NULL DEFAULT '';
+			PRAGMA user_version = 6;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 7 {
+		log.Printf("db: migrating schema to version 7 (add body)")
+		if _, err := d.db.Exec(`
+			ALTER TABLE tracked_prs ADD COLUMN body TEXT NOT NULL DEFAULT '';
+			PRAGMA user_version = 7;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 8 {
+		log.Printf("db: migrating schema to version 8 (index merge_commit)")
+		if _, err := d.db.Exec(`
+			CREATE INDEX IF NOT EXISTS idx_tracked_prs_merge_commit ON tracked_prs(merge_commit) WHERE merge_commit != '';
+			PRAGMA user_version = 8;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 9 {
+		log.Printf("db: migrating schema to version 9 (add archive tables)")
+		if _, err := d.db.Exec(`
+			CREATE TABLE IF NOT EXISTS archived_prs (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				pr_number       INTEGER NOT NULL,
+				title           TEXT NOT NULL DEFAULT '',
+				author          TEXT NOT NULL DEFAULT '',
+				status          TEXT NOT NULL DEFAULT 'open',
+				merge_commit    TEXT NOT NULL DEFAULT '',
+				base_ref        TEXT NOT NULL DEFAULT '',
+				body            TEXT NOT NULL DEFAULT '',
+				created_at      DATETIME,
+				updated_at      DATETIME,
+				last_checked_at DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00',
+				archived_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS archived_branch_status (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				archive_id  INTEGER NOT NULL,
+				branch      TEXT NOT NULL,
+				landed      BOOLEAN NOT NULL DEFAULT 0,
+				landed_at   DATETIME,
+				last_status TEXT NOT NULL DEFAULT '',
+				FOREIGN KEY (archive_id) REFERENCES archived_prs(id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_archived_branch_status_archive_id ON archived_branch_status(archive_id);
+
+			PRAGMA user_version = 9;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 10 {
+		log.Printf("db: migrating schema to version 10 (key PRs and commits by repo)")
+		// SQLite can't change a UNIQUE constraint in place, so the keyed
+		// tables are rebuilt. Existing rows belong to the primary repo.
+		tx, err := d.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec(`
+			CREATE TABLE tracked_prs_new (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				repo            TEXT NOT NULL DEFAULT '',
+				pr_number       INTEGER NOT NULL,
+				title           TEXT NOT NULL DEFAULT '',
+				author          TEXT NOT NULL DEFAULT '',
+				status          TEXT NOT NULL DEFAULT 'open',
+				merge_commit    TEXT NOT NULL DEFAULT '',
+				created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+				last_checked_at DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00',
+				base_ref        TEXT NOT NULL DEFAULT '',
+				body            TEXT NOT NULL DEFAULT '',
+				UNIQUE(repo, pr_number)
+			);
+			INSERT INTO tracked_prs_new (id, pr_number, title, author, status, merge_commit, created_at, updated_at, last_checked_at, base_ref, body)
+				SELECT id, pr_number, title, author, status, merge_commit, created_at, updated_at, last_checked_at, base_ref, body FROM tracked_prs;
+
+			CREATE TABLE branch_status_new (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				repo        TEXT NOT NULL DEFAULT '',
+				pr_number   INTEGER NOT NULL,
+				branch      TEXT NOT NULL,
+				landed      BOOLEAN NOT NULL DEFAULT 0,
+				landed_at   DATETIME,
+				last_status TEXT NOT NULL DEFAULT '',
+				UNIQUE(repo, pr_number, branch),
+				FOREIGN KEY (repo, pr_number) REFERENCES tracked_prs(repo, pr_number)
+			);
+			INSERT INTO branch_status_new (id, pr_number, branch, landed, landed_at, last_status)
+				SELECT id, pr_number, branch, landed, landed_at, last_status FROM branch_status;
+
+			CREATE TABLE tracked_commits_new (
+				id              INTEGER PRIMARY KEY AUTOINCREMENT,
+				repo            TEXT NOT NULL DEFAULT '',
+				sha             TEXT NOT NULL,
+				title           TEXT NOT NULL DEFAULT '',
+				created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+				last_checked_at DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00',
+				UNIQUE(repo, sha)
+			);
+			INSERT INTO tracked_commits_new (id, sha, title, created_at, last_checked_at)
+				SELECT id, sha, title, created_at, last_checked_at FROM tracked_commits;
+
+			CREATE TABLE commit_branch_status_new (
+				id          INTEGER PRIMARY KEY AUTOINCREMENT,
+				repo        TEXT NOT NULL DEFAULT '',
+				sha         TEXT NOT NULL,
+				branch      TEXT NOT NULL,
+				landed      BOOLEAN NOT NULL DEFAULT 0,
+				landed_at   DATETIME,
+				UNIQUE(repo, sha, branch),
+				FOREIGN KEY (repo, sha) REFERENCES tracked_commits(repo, sha)
+			);
+			INSERT INTO commit_branch_status_new (id, sha, branch, landed, landed_at)
+				SELECT id, sha, branch, landed, landed_at FROM commit_branch_status;
+
+			DROP TABLE branch_status;
+			DROP TABLE tracked_prs;
+			DROP TABLE commit_branch_status;
+			DROP TABLE tracked_commits;
+			ALTER TABLE tracked_prs_new RENAME TO tracked_prs;
+			ALTER TABLE branch_status_new RENAME TO branch_status;
+			ALTER TABLE tracked_commits_new RENAME TO tracked_commits;
+			ALTER TABLE commit_branch_status_new RENAME TO commit_branch_status;
+
+			CREATE INDEX IF NOT EXISTS idx_tracked_prs_merge_commit ON tracked_prs(merge_commit) WHERE merge_commit != '';
+
+			ALTER TABLE events ADD COLUMN repo TEXT NOT NULL DEFAULT '';
+			ALTER TABLE archived_prs ADD COLUMN repo TEXT NOT NULL DEFAULT '';
+
+			PRAGMA user_version = 10;
+		`); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	if version < 11 {
+		log.Printf("db: migrating schema to version 11 (add merged_at)")
+		if _, err := d.db.Exec(`
+			ALTER TABLE tracked_prs ADD COLUMN merged_at DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00';
+			PRAGMA user_version = 11;
+		`); err != nil {
+			return err
+		}
+	}
+
+	if version < 12 {
+		log.Printf("db: migrating schema to version 12 (archive merged_at)")
+		if _, err := d.db.Exec(`
+			ALTER TABLE archived_prs ADD COLUMN merged_at DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00';
+			PRAGMA user_version = 12;
+		`); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (d *DB) AddPR(prNumber int) error {
 	_, err := d.db.Exec(
-		`INSERT OR IGNORE INTO tracked_prs (pr_number) VALUES (?)`,
-		prNumber,
+		`INSERT OR IGNORE INTO tracked_prs (repo, pr_number) VALUES (?, ?)`,
+		d.repo, prNumber,
 	)
 	return err
 }
 
+// RemovePR stops tracking a PR, deleting it and its branch statuses or, with
+// WithArchiveOnRemove, moving them to the archive. Removing an untracked PR
+// is a no-op.
 func (d *DB) RemovePR(prNumber int) error {
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -112,17 +512,190 @@ func (d *DB) RemovePR(prNumber int) error {
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec(`DELETE FROM branch_status WHERE pr_number = ?`, prNumber); err != nil {
+	if d.archiveOnRemove {
+		if err := archivePR(tx, d.repo, prNumber); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM branch_status WHERE repo = ? AND pr_number = ?`, d.repo, prNumber); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM tracked_prs WHERE repo = ? AND pr_number = ?`, d.repo, prNumber); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// archivePR copies a tracked PR and its branch statuses into the archive
+// tables within tx. The caller deletes the originals.
+func archivePR(tx *sql.Tx, repo string, prNumber int) error {
+	res, err := tx.Exec(
+		`INSERT INTO archived_prs (repo, pr_number, title, author, status, merge_commit, base_ref, merged_at, body, created_at, updated_at, last_checked_at)
+		 SELECT repo, pr_number, title, author, status, merge_commit, base_ref, merged_at, body, created_at, updated_at, last_checked_at FROM tracked_prs WHERE repo = ? AND pr_number = ?`,
+		repo, prNumber,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return nil // not tracked
+	}
+	archiveID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO archived_branch_status (archive_id, branch, landed, landed_at, last_status)
+		 SELECT ?, branch, landed, landed_at, last_status FROM branch_status WHERE repo = ? AND pr_number = ? ORDER BY id`,
+		archiveID, repo, prNumber,
+	)
+	return err
+}
+
+// ListArchivedPRs returns the archived PRs with their branch statuses, most
+// recently archived first. A PR removed more than once appears once per
+// removal.
+func (d *DB) ListArchivedPRs() ([]ArchivedPR, error) {
+	rows, err := d.db.Query(`SELECT id, repo, pr_number, title, author, status, merge_commit, base_ref, merged_at, body, created_at, updated_at, last_checked_at, archived_at FROM archived_prs ORDER BY archived_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prs []ArchivedPR
+	for rows.Next() {
+		var pr ArchivedPR
+		if err := rows.Scan(&pr.ID, &pr.Repo, &pr.PRNumber, &pr.Title, &pr.Author, &pr.Status, &pr.MergeCommit, &pr.BaseRef, &pr.MergedAt, &pr.Body, &pr.CreatedAt, &pr.UpdatedAt, &pr.LastCheckedAt, &pr.ArchivedAt); err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range prs {
+		branches, err := d.archivedBranchStatus(prs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		prs[i].Branches = branches
+	}
+	return prs, nil
+}
+
+func (d *DB) archivedBranchStatus(archiveID int) ([]BranchStatus, error) {
+	rows, err := d.db.Query(`SELECT branch, landed, landed_at, last_status FROM archived_branch_status WHERE archive_id = ? ORDER BY id`, archiveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []BranchStatus
+	for rows.Next() {
+		var bs BranchStatus
+		if err := rows.Scan(&bs.Branch, &bs.Landed, &bs.LandedAt, &bs.LastStatus); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, bs)
+	}
+	return statuses, rows.Err()
+}
+
+// ResetBranchStatus forgets every recorded landing of a tracked PR, so the
+// next poll checks all branches again. A PR with a merge commit goes back to
+// "merged", since one kept as "landed" is otherwise never polled. It returns
+// ErrNotFound if the PR is not tracked.
+func (d *DB) ResetBranchStatus(prNumber int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE tracked_prs SET status = CASE WHEN merge_commit != '' THEN 'merged' ELSE status END, updated_at = CURRENT_TIMESTAMP WHERE repo = ? AND pr_number = ?`, d.repo, prNumber)
+	if err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`DELETE FROM tracked_prs WHERE pr_number = ?`, prNumber); err != nil {
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM branch_status WHERE repo = ? AND pr_number = ?`, d.repo, prNumber); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteTimeFormat matches the text SQLite's CURRENT_TIMESTAMP produces, so
+// timestamps written from Go sort and compare like the column defaults.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// RestorePR re-inserts a previously removed PR with its recorded state,
+// timestamps and branch statuses. It fails if the PR is already tracked.
+// With WithArchiveOnRemove, the PR's latest archive entry is dropped, since
+// the removal it recorded has been undone.
+func (d *DB) RestorePR(pr TrackedPR) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if d.archiveOnRemove {
+		var archiveID int
+		err := tx.QueryRow(`SELECT id FROM archived_prs WHERE repo = ? AND pr_number = ? ORDER BY id DESC LIMIT 1`, d.repo, pr.PRNumber).Scan(&archiveID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			if _, err := tx.Exec(`DELETE FROM archived_branch_status WHERE archive_id = ?`, archiveID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM archived_prs WHERE id = ?`, archiveID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO tracked_prs (repo, pr_number, title, author, status, merge_commit, base_ref, merged_at, body, created_at, updated_at, last_checked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.repo, pr.PRNumber, pr.Title, pr.Author, pr.Status, pr.MergeCommit, pr.BaseRef, pr.MergedAt.UTC().Format(sqliteTimeFormat), pr.Body,
+		pr.CreatedAt.UTC().Format(sqliteTimeFormat), pr.UpdatedAt.UTC().Format(sqliteTimeFormat), pr.LastCheckedAt.UTC().Format(sqliteTimeFormat),
+	); err != nil {
 		return err
 	}
+	for _, bs := range pr.Branches {
+		var landedAt any
+		if bs.LandedAt != nil {
+			landedAt = bs.LandedAt.UTC().Format(sqliteTimeFormat)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO branch_status (repo, pr_number, branch, landed, landed_at, last_status) VALUES (?, ?, ?, ?, ?, ?)`,
+			d.repo, pr.PRNumber, bs.Branch, bs.Landed, landedAt, bs.LastStatus,
+		); err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
 
 func (d *DB) ListPRs() ([]TrackedPR, error) {
-	rows, err := d.db.Query(`SELECT id, pr_number, title, author, status, merge_commit, created_at, updated_at, last_checked_at FROM tracked_prs ORDER BY pr_number DESC`)
+	return d.listPRs(true)
+}
+
+// ListPRsWithoutBranches is ListPRs without the per-PR branch status
+// queries; Branches is nil on every returned PR.
+func (d *DB) ListPRsWithoutBranches() ([]TrackedPR, error) {
+	return d.listPRs(false)
+}
+
+func (d *DB) listPRs(withBranches bool) ([]TrackedPR, error) {
+	rows, err := d.db.Query(`SELECT id, repo, pr_number, title, author, status, merge_commit, base_ref, merged_at, body, created_at, updated_at, last_checked_at FROM tracked_prs WHERE repo = ? ORDER BY pr_number DESC`, d.repo)
 	if err != nil {
 		return nil, err
 	}
@@ -131,25 +704,28 @@ func (d *DB) ListPRs() ([]TrackedPR, error) {
 	var prs []TrackedPR
 	for rows.Next() {
 		var pr TrackedPR
-		if err := rows.Scan(&pr.ID, &pr.PRNumber, &pr.Title, &pr.Author, &pr.Status, &pr.MergeCommit, &pr.CreatedAt, &pr.UpdatedAt, &pr.LastCheckedAt); err != nil {
+		if err := rows.Scan(&pr.ID, &pr.Repo, &pr.PRNumber, &pr.Title, &pr.Author, &pr.Status, &pr.MergeCommit, &pr.BaseRef, &pr.MergedAt, &pr.Body, &pr.CreatedAt, &pr.UpdatedAt, &pr.LastCheckedAt); err != nil {
 			return nil, err
 		}
-		branches, err := d.GetBranchStatus(pr.PRNumber)
-		if err != nil {
-			return nil, err
+		if withBranches {
+			branches, err := d.GetBranchStatus(pr.PRNumber)
+			if err != nil {
+				return nil, err
+			}
+			pr.Branches = branches
 		}
-		pr.Branches = branches
 		prs = append(prs, pr)
 	}
 	return prs, rows.Err()
 }
 
+// GetPR returns a tracked PR with its branch statuses, or ErrNotFound.
 func (d *DB) GetPR(prNumber int) (*TrackedPR, error) {
 	var pr TrackedPR
-	err := d.db.QueryRow(
-		`SELECT id, pr_number, title, author, status, merge_commit, created_at, updated_at, last_checked_at FROM tracked_prs WHERE pr_number = ?`,
-		prNumber,
-	).Scan(&pr.ID, &pr.PRNumber, &pr.Title, &pr.Author, &pr.Status, &pr.MergeCommit, &pr.CreatedAt, &pr.UpdatedAt, &pr.LastCheckedAt)
+	err := d.getPRStmt.QueryRow(d.repo, prNumber).Scan(&pr.ID, &pr.Repo, &pr.PRNumber, &pr.Title, &pr.Author, &pr.Status, &pr.MergeCommit, &pr.BaseRef, &pr.MergedAt, &pr.Body, &pr.CreatedAt, &pr.UpdatedAt, &pr.LastCheckedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -161,33 +737,201 @@ func (d *DB) GetPR(prNumber int) (*TrackedPR, error) {
 	return &pr, nil
 }
 
+// getPRByMergeCommitQuery repeats the partial index's condition so SQLite
+// can use idx_tracked_prs_merge_commit.
+const getPRByMergeCommitQuery = `SELECT pr_number FROM tracked_prs WHERE repo = ? AND merge_commit = ? AND merge_commit != '' LIMIT 1`
+
+// GetPRByMergeCommit returns the tracked PR whose merge commit is sha, or
+// ErrNotFound if none is.
+func (d *DB) GetPRByMergeCommit(sha string) (*TrackedPR, error) {
+	if sha == "" {
+		return nil, ErrNotFound
+	}
+	var prNumber int
+	err := d.db.QueryRow(getPRByMergeCommitQuery, d.repo, sha).Scan(&prNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.GetPR(prNumber)
+}
+
 func (d *DB) UpdatePRStatus(prNumber int, status string, mergeCommit string, title string, author string) error {
 	_, err := d.db.Exec(
-		`UPDATE tracked_prs SET status = ?, merge_commit = ?, title = ?, author = ?, updated_at = CURRENT_TIMESTAMP WHERE pr_number = ?`,
-		status, mergeCommit, title, author, prNumber,
+		`UPDATE tracked_prs SET status = ?, merge_commit = ?, title = ?, author = ?, updated_at = CURRENT_TIMESTAMP WHERE repo = ? AND pr_number = ?`,
+		status, mergeCommit, title, author, d.repo, prNumber,
+	)
+	return err
+}
+
+// UpdatePRBase records the branch a PR targets. It leaves updated_at alone,
+// since a base is not a change in the PR's tracking state.
+func (d *DB) UpdatePRBase(prNumber int, baseRef string) error {
+	_, err := d.db.Exec(
+		`UPDATE tracked_prs SET base_ref = ? WHERE repo = ? AND pr_number = ?`,
+		baseRef, d.repo, prNumber,
+	)
+	return err
+}
+
+// UpdatePRMergedAt records when GitHub merged a PR. Like UpdatePRBase it
+// leaves updated_at alone.
+func (d *DB) UpdatePRMergedAt(prNumber int, mergedAt time.Time) error {
+	_, err := d.db.Exec(
+		`UPDATE tracked_prs SET merged_at = ? WHERE repo = ? AND pr_number = ?`,
+		mergedAt.UTC().Format(sqliteTimeFormat), d.repo, prNumber,
+	)
+	return err
+}
+
+// UpdatePRBody records a PR's description for notifications. Like
+// UpdatePRBase it leaves updated_at alone.
+func (d *DB) UpdatePRBody(prNumber int, body string) error {
+	_, err := d.db.Exec(
+		`UPDATE tracked_prs SET body = ? WHERE repo = ? AND pr_number = ?`,
+		body, d.repo, prNumber,
 	)
 	return err
 }
 
 func (d *DB) UpdateLastChecked(prNumber int) error {
 	_, err := d.db.Exec(
-		`UPDATE tracked_prs SET last_checked_at = CURRENT_TIMESTAMP WHERE pr_number = ?`,
-		prNumber,
+		`UPDATE tracked_prs SET last_checked_at = CURRENT_TIMESTAMP WHERE repo = ? AND pr_number = ?`,
+		d.repo, prNumber,
 	)
 	return err
 }
 
+// UpdateBranchLanded marks a PR as landed in branch. A branch that has
+// already landed keeps its original landed_at.
 func (d *DB) UpdateBranchLanded(prNumber int, branch string) error {
+	_, err := d.updateBranchLandedStmt.Exec(d.repo, prNumber, branch)
+	return err
+}
+
+// UpdateBranchLastStatus records the compare status last seen for a PR's
+// merge commit against branch, adding a not-yet-landed row for the branch if
+// there is none. It leaves landed and landed_at alone.
+func (d *DB) UpdateBranchLastStatus(prNumber int, branch, status string) error {
 	_, err := d.db.Exec(
-		`INSERT INTO branch_status (pr_number, branch, landed, landed_at) VALUES (?, ?, 1, CURRENT_TIMESTAMP)
-		 ON CONFLICT(pr_number, branch) DO UPDATE SET landed = 1, landed_at = CURRENT_TIMESTAMP`,
-		prNumber, branch,
+		`INSERT INTO branch_status (repo, pr_number, branch, landed, last_status) VALUES (?, ?, ?, 0, ?)
+		 ON CONFLICT(repo, pr_number, branch) DO UPDATE SET last_status = excluded.last_status`,
+		d.repo, prNumber, branch, status,
 	)
 	return err
 }
 
 func (d *DB) GetBranchStatus(prNumber int) ([]BranchStatus, error) {
-	rows, err := d.db.Query(`SELECT branch, landed, landed_at FROM branch_status WHERE pr_number = ?`, prNumber)
+	rows, err := d.getBranchStatusStmt.Query(d.repo, prNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []BranchStatus
+	for rows.Next() {
+		var bs BranchStatus
+		if err := rows.Scan(&bs.Branch, &bs.Landed, &bs.LandedAt, &bs.LastStatus); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, bs)
+	}
+	return statuses, rows.Err()
+}
+
+func (d *DB) AddCommit(sha string, title string) error {
+	_, err := d.db.Exec(
+		`INSERT OR IGNORE INTO tracked_commits (repo, sha, title) VALUES (?, ?, ?)`,
+		d.repo, sha, title,
+	)
+	return err
+}
+
+func (d *DB) RemoveCommit(sha string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM commit_branch_status WHERE repo = ? AND sha = ?`, d.repo, sha); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM tracked_commits WHERE repo = ? AND sha = ?`, d.repo, sha); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) ListCommits() ([]TrackedCommit, error) {
+	rows, err := d.db.Query(`SELECT id, sha, title, created_at, last_checked_at FROM tracked_commits WHERE repo = ? ORDER BY id DESC`, d.repo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commits []TrackedCommit
+	for rows.Next() {
+		var c TrackedCommit
+		if err := rows.Scan(&c.ID, &c.SHA, &c.Title, &c.CreatedAt, &c.LastCheckedAt); err != nil {
+			return nil, err
+		}
+		branches, err := d.GetCommitBranchStatus(c.SHA)
+		if err != nil {
+			return nil, err
+		}
+		c.Branches = branches
+		commits = append(commits, c)
+	}
+	return commits, rows.Err()
+}
+
+// GetCommit returns a tracked commit with its branch statuses, or
+// ErrNotFound.
+func (d *DB) GetCommit(sha string) (*TrackedCommit, error) {
+	var c TrackedCommit
+	err := d.db.QueryRow(
+		`SELECT id, sha, title, created_at, last_checked_at FROM tracked_commits WHERE repo = ? AND sha = ?`,
+		d.repo, sha,
+	).Scan(&c.ID, &c.SHA, &c.Title, &c.CreatedAt, &c.LastCheckedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	branches, err := d.GetCommitBranchStatus(c.SHA)
+	if err != nil {
+		return nil, err
+	}
+	c.Branches = branches
+	return &c, nil
+}
+
+func (d *DB) UpdateCommitLastChecked(sha string) error {
+	_, err := d.db.Exec(
+		`UPDATE tracked_commits SET last_checked_at = CURRENT_TIMESTAMP WHERE repo = ? AND sha = ?`,
+		d.repo, sha,
+	)
+	return err
+}
+
+// UpdateCommitBranchLanded marks a tracked commit as landed in branch. A
+// branch that has already landed keeps its original landed_at.
+func (d *DB) UpdateCommitBranchLanded(sha string, branch string) error {
+	_, err := d.db.Exec(
+		`INSERT INTO commit_branch_status (repo, sha, branch, landed, landed_at) VALUES (?, ?, ?, 1, CURRENT_TIMESTAMP)
+		 ON CONFLICT(repo, sha, branch) DO UPDATE SET landed = 1, landed_at = CURRENT_TIMESTAMP
+		 WHERE commit_branch_status.landed = 0`,
+		d.repo, sha, branch,
+	)
+	return err
+}
+
+func (d *DB) GetCommitBranchStatus(sha string) ([]BranchStatus, error) {
+	rows, err := d.db.Query(`SELECT branch, landed, landed_at FROM commit_branch_status WHERE repo = ? AND sha = ?`, d.repo, sha)
 	if err != nil {
 		return nil, err
 	}
@@ -203,3 +947,69 @@ func (d *DB) GetBranchStatus(prNumber int) ([]BranchStatus, error) {
 	}
 	return statuses, rows.Err()
 }
+
+func (d *DB) AddEvent(e EventRecord) error {
+	_, err := d.db.Exec(
+		`INSERT INTO events (repo, type, pr_number, title, author, branch, commit_sha, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Repo, e.Type, e.PRNumber, e.Title, e.Author, e.Branch, e.Commit, e.CreatedAt.UTC(),
+	)
+	return err
+}
+
+// ListEvents returns up to limit events, most recent first.
+func (d *DB) ListEvents(limit int) ([]EventRecord, error) {
+	rows, err := d.db.Query(
+		`SELECT id, repo, type, pr_number, title, author, branch, commit_sha, created_at FROM events ORDER BY created_at DESC, id DESC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// ListEventsByType returns up to limit events of the given types, most
+// recent first.
+func (d *DB) ListEventsByType(limit int, types ...string) ([]EventRecord, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.Repeat(", ?", len(types))[2:]
+	args := make([]any, 0, len(types)+1)
+	for _, t := range types {
+		args = append(args, t)
+	}
+	args = append(args, limit)
+	rows, err := d.db.Query(
+		`SELECT id, repo, type, pr_number, title, author, branch, commit_sha, created_at FROM events WHERE type IN (`+placeholders+`) ORDER BY created_at DESC, id DESC LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+func scanEvents(rows *sql.Rows) ([]EventRecord, error) {
+	defer rows.Close()
+
+	var events []EventRecord
+	for rows.Next() {
+		var e EventRecord
+		if err := rows.Scan(&e.ID, &e.Repo, &e.Type, &e.PRNumber, &e.Title, &e.Author, &e.Branch, &e.Commit, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// PruneEvents deletes events created before olderThan and returns how many
+// were removed.
+func (d *DB) PruneEvents(olderThan time.Time) (int64, error) {
+	res, err := d.db.Exec(`DELETE FROM events WHERE created_at < ?`, olderThan.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -1,8 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -34,6 +40,31 @@ func TestMigration(t *testing.T) {
 	}
 }
 
+func TestBusyTimeout(t *testing.T) {
+	d := newTestDB(t)
+
+	// Check several connections: the timeout must apply to each one the
+	// pool opens, not just the first.
+	var conns []*sql.Conn
+	for range 3 {
+		conn, err := d.db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		var ms int64
+		if err := conn.QueryRowContext(context.Background(), `PRAGMA busy_timeout`).Scan(&ms); err != nil {
+			t.Fatalf("PRAGMA busy_timeout: %v", err)
+		}
+		if ms != busyTimeout.Milliseconds() {
+			t.Errorf("busy_timeout = %dms, want %dms", ms, busyTimeout.Milliseconds())
+		}
+		conn.Close()
+	}
+}
+
 func TestAddPR(t *testing.T) {
 	d := newTestDB(t)
 
@@ -80,6 +111,9 @@ func TestGetPRNotFound(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for non-existent PR")
 	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPR(999) = %v, want ErrNotFound", err)
+	}
 }
 
 func TestRemovePR(t *testing.T) {
@@ -116,6 +150,107 @@ func TestRemovePRWithBranchStatus(t *testing.T) {
 	}
 }
 
+func newArchivingTestDB(t *testing.T) *DB {
+	t.Helper()
+	d, err := New("file:"+t.Name()+"?mode=memory&cache=shared", WithArchiveOnRemove())
+	if err != nil {
+		t.Fatalf("opening in-memory DB: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestArchiveOnRemove(t *testing.T) {
+	d := newArchivingTestDB(t)
+
+	d.AddPR(1)
+	d.UpdatePRStatus(1, "merged", "sha1", "foo: 1 -> 2", "alice")
+	d.UpdatePRBase(1, "staging")
+	d.UpdateBranchLanded(1, "staging")
+	d.UpdateBranchLastStatus(1, "nixos-unstable", "ahead")
+	mergedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	d.UpdatePRMergedAt(1, mergedAt)
+	before, _ := d.GetPR(1)
+
+	if err := d.RemovePR(1); err != nil {
+		t.Fatalf("RemovePR: %v", err)
+	}
+	if _, err := d.GetPR(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetPR after archiving: err = %v, want ErrNotFound", err)
+	}
+	if statuses, _ := d.GetBranchStatus(1); len(statuses) != 0 {
+		t.Errorf("remaining branch statuses = %d, want 0", len(statuses))
+	}
+
+	archived, err := d.ListArchivedPRs()
+	if err != nil {
+		t.Fatalf("ListArchivedPRs: %v", err)
+	}
+	if len(archived) != 1 {
+		t.Fatalf("archived %d PRs, want 1", len(archived))
+	}
+	a := archived[0]
+	if a.PRNumber != 1 || a.Title != "foo: 1 -> 2" || a.Author != "alice" || a.Status != "merged" || a.MergeCommit != "sha1" || a.BaseRef != "staging" {
+		t.Errorf("archived PR = %+v", a)
+	}
+	if !a.CreatedAt.Equal(before.CreatedAt) || !a.MergedAt.Equal(mergedAt) || a.ArchivedAt.IsZero() {
+		t.Errorf("archived timestamps: created %v (want %v), merged %v (want %v), archived %v", a.CreatedAt, before.CreatedAt, a.MergedAt, mergedAt, a.ArchivedAt)
+	}
+	if len(a.Branches) != 2 || a.Branches[0].Branch != "staging" || !a.Branches[0].Landed || a.Branches[0].LandedAt == nil ||
+		a.Branches[1].Branch != "nixos-unstable" || a.Branches[1].Landed || a.Branches[1].LastStatus != "ahead" {
+		t.Errorf("archived branches = %+v", a.Branches)
+	}
+
+	// Undoing the removal drops its archive entry.
+	if err := d.RestorePR(*before); err != nil {
+		t.Fatalf("RestorePR: %v", err)
+	}
+	if archived, _ := d.ListArchivedPRs(); len(archived) != 0 {
+		t.Errorf("archive after restore = %+v, want empty", archived)
+	}
+}
+
+func TestListArchivedPRs(t *testing.T) {
+	d := newArchivingTestDB(t)
+
+	if archived, err := d.ListArchivedPRs(); err != nil || len(archived) != 0 {
+		t.Fatalf("empty archive = %v, %v", archived, err)
+	}
+
+	d.AddPR(1)
+	d.RemovePR(1)
+	d.AddPR(2)
+	d.UpdateBranchLanded(2, "master")
+	d.RemovePR(2)
+	d.AddPR(1) // tracked again, then removed again
+	d.RemovePR(1)
+	d.RemovePR(3) // never tracked
+
+	archived, err := d.ListArchivedPRs()
+	if err != nil {
+		t.Fatalf("ListArchivedPRs: %v", err)
+	}
+	var got []int
+	for _, a := range archived {
+		got = append(got, a.PRNumber)
+	}
+	if !slices.Equal(got, []int{1, 2, 1}) {
+		t.Errorf("archived PRs = %v, want most recent first [1 2 1]", got)
+	}
+	if len(archived[1].Branches) != 1 || len(archived[0].Branches) != 0 {
+		t.Errorf("branches = %+v / %+v, want each entry's own", archived[0].Branches, archived[1].Branches)
+	}
+
+	t.Run("without option", func(t *testing.T) {
+		plain := newTestDB(t)
+		plain.AddPR(5)
+		plain.RemovePR(5)
+		if archived, _ := plain.ListArchivedPRs(); len(archived) != 0 {
+			t.Errorf("archive = %+v, want removal to delete", archived)
+		}
+	})
+}
+
 func TestListPRsOrdering(t *testing.T) {
 	d := newTestDB(t)
 
@@ -162,6 +297,133 @@ func TestUpdatePRStatus(t *testing.T) {
 	}
 }
 
+func TestGetPRByMergeCommit(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(5)
+	d.AddPR(6)
+	if err := d.UpdatePRStatus(5, "merged", "abc123", "My PR", "author1"); err != nil {
+		t.Fatalf("UpdatePRStatus: %v", err)
+	}
+
+	pr, err := d.GetPRByMergeCommit("abc123")
+	if err != nil {
+		t.Fatalf("GetPRByMergeCommit: %v", err)
+	}
+	if pr.PRNumber != 5 || pr.Title != "My PR" {
+		t.Errorf("GetPRByMergeCommit = PR #%d %q, want PR #5 %q", pr.PRNumber, pr.Title, "My PR")
+	}
+
+	// PR #6 has no merge commit yet; an empty SHA must not match it.
+	for _, sha := range []string{"def456", ""} {
+		if _, err := d.GetPRByMergeCommit(sha); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetPRByMergeCommit(%q) = %v, want ErrNotFound", sha, err)
+		}
+	}
+}
+
+func TestGetPRByMergeCommitUsesIndex(t *testing.T) {
+	d := newTestDB(t)
+
+	rows, err := d.db.Query(`EXPLAIN QUERY PLAN `+getPRByMergeCommitQuery, "", "abc123")
+	if err != nil {
+		t.Fatalf("EXPLAIN QUERY PLAN: %v", err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatalf("scanning plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("reading plan: %v", err)
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_tracked_prs_merge_commit") {
+		t.Errorf("query plan = %q, want it to use idx_tracked_prs_merge_commit", plan)
+	}
+}
+
+func TestUpdatePRBase(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(6)
+	before, _ := d.GetPR(6)
+	if before.BaseRef != "" {
+		t.Errorf("BaseRef = %q, want empty before the first poll", before.BaseRef)
+	}
+
+	if err := d.UpdatePRBase(6, "staging"); err != nil {
+		t.Fatalf("UpdatePRBase: %v", err)
+	}
+	pr, err := d.GetPR(6)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.BaseRef != "staging" {
+		t.Errorf("BaseRef = %q, want %q", pr.BaseRef, "staging")
+	}
+	if !pr.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("UpdatedAt changed from %v to %v", before.UpdatedAt, pr.UpdatedAt)
+	}
+	prs, _ := d.ListPRs()
+	if len(prs) != 1 || prs[0].BaseRef != "staging" {
+		t.Errorf("ListPRs BaseRef = %+v, want staging", prs)
+	}
+}
+
+func TestUpdatePRMergedAt(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(7)
+	before, _ := d.GetPR(7)
+	if !before.MergedAt.IsZero() {
+		t.Errorf("MergedAt = %v, want zero before the merge is known", before.MergedAt)
+	}
+
+	mergedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := d.UpdatePRMergedAt(7, mergedAt); err != nil {
+		t.Fatalf("UpdatePRMergedAt: %v", err)
+	}
+	pr, err := d.GetPR(7)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if !pr.MergedAt.Equal(mergedAt) {
+		t.Errorf("MergedAt = %v, want %v", pr.MergedAt, mergedAt)
+	}
+	if !pr.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("UpdatedAt changed from %v to %v", before.UpdatedAt, pr.UpdatedAt)
+	}
+	prs, _ := d.ListPRs()
+	if len(prs) != 1 || !prs[0].MergedAt.Equal(mergedAt) {
+		t.Errorf("ListPRs MergedAt = %+v, want %v", prs, mergedAt)
+	}
+}
+
+func TestUpdatePRBody(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(6)
+	before, _ := d.GetPR(6)
+	if err := d.UpdatePRBody(6, "Changelog: https://example.com"); err != nil {
+		t.Fatalf("UpdatePRBody: %v", err)
+	}
+	pr, err := d.GetPR(6)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Body != "Changelog: https://example.com" {
+		t.Errorf("Body = %q, want the stored description", pr.Body)
+	}
+	if !pr.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("UpdatedAt changed from %v to %v", before.UpdatedAt, pr.UpdatedAt)
+	}
+}
+
 func TestUpdateBranchLanded(t *testing.T) {
 	d := newTestDB(t)
 
@@ -185,6 +447,44 @@ func TestUpdateBranchLanded(t *testing.T) {
 	}
 }
 
+func TestUpdateBranchLastStatus(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(7)
+	if err := d.UpdateBranchLastStatus(7, "nixos-unstable", "ahead"); err != nil {
+		t.Fatalf("UpdateBranchLastStatus: %v", err)
+	}
+	statuses, err := d.GetBranchStatus(7)
+	if err != nil {
+		t.Fatalf("GetBranchStatus: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Landed || statuses[0].LandedAt != nil || statuses[0].LastStatus != "ahead" {
+		t.Fatalf("statuses = %+v, want one pending nixos-unstable row with last status ahead", statuses)
+	}
+
+	// Landing keeps the status, and a later status update keeps the landing.
+	d.UpdateBranchLanded(7, "nixos-unstable")
+	d.UpdateBranchLastStatus(7, "nixos-unstable", "behind")
+	pr, err := d.GetPR(7)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	bs := pr.Branches[0]
+	if !bs.Landed || bs.LandedAt == nil || bs.LastStatus != "behind" {
+		t.Errorf("branch = %+v, want landed with last status behind", bs)
+	}
+
+	// The status survives a remove and restore.
+	d.RemovePR(7)
+	if err := d.RestorePR(*pr); err != nil {
+		t.Fatalf("RestorePR: %v", err)
+	}
+	restored, _ := d.GetPR(7)
+	if len(restored.Branches) != 1 || restored.Branches[0].LastStatus != "behind" {
+		t.Errorf("restored branches = %+v, want last status behind", restored.Branches)
+	}
+}
+
 func TestUpdateBranchLandedIdempotent(t *testing.T) {
 	d := newTestDB(t)
 
@@ -205,6 +505,56 @@ func TestUpdateBranchLandedIdempotent(t *testing.T) {
 	}
 }
 
+func TestUpdateBranchLandedKeepsFirstLanding(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(8)
+	d.UpdateBranchLastStatus(8, "nixos-unstable", "ahead")
+	if err := d.UpdateBranchLanded(8, "nixos-unstable"); err != nil {
+		t.Fatalf("UpdateBranchLanded: %v", err)
+	}
+
+	// Backdate the landing so a second write would be visible.
+	first := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := d.db.Exec(`UPDATE branch_status SET landed_at = ? WHERE pr_number = 8`, first); err != nil {
+		t.Fatalf("backdating landed_at: %v", err)
+	}
+	if err := d.UpdateBranchLanded(8, "nixos-unstable"); err != nil {
+		t.Fatalf("second UpdateBranchLanded: %v", err)
+	}
+
+	statuses, err := d.GetBranchStatus(8)
+	if err != nil {
+		t.Fatalf("GetBranchStatus: %v", err)
+	}
+	if len(statuses) != 1 || !statuses[0].Landed || statuses[0].LandedAt == nil {
+		t.Fatalf("statuses = %+v, want one landed branch", statuses)
+	}
+	if !statuses[0].LandedAt.Equal(first) {
+		t.Errorf("LandedAt = %v, want the first landing %v", statuses[0].LandedAt, first)
+	}
+}
+
+func TestUpdateCommitBranchLandedKeepsFirstLanding(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddCommit("abc123", "")
+	d.UpdateCommitBranchLanded("abc123", "master")
+	first := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := d.db.Exec(`UPDATE commit_branch_status SET landed_at = ? WHERE sha = 'abc123'`, first); err != nil {
+		t.Fatalf("backdating landed_at: %v", err)
+	}
+	d.UpdateCommitBranchLanded("abc123", "master")
+
+	c, err := d.GetCommit("abc123")
+	if err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	if len(c.Branches) != 1 || c.Branches[0].LandedAt == nil || !c.Branches[0].LandedAt.Equal(first) {
+		t.Errorf("branches = %+v, want master still landed at %v", c.Branches, first)
+	}
+}
+
 func TestMultipleBranches(t *testing.T) {
 	d := newTestDB(t)
 
@@ -242,6 +592,30 @@ func TestListPRsIncludesBranches(t *testing.T) {
 	}
 }
 
+func TestListPRsWithoutBranches(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(11)
+	d.UpdateBranchLanded(11, "nixos-unstable")
+
+	// With the branch status statement closed, any branch query fails.
+	d.getBranchStatusStmt.Close()
+	if _, err := d.ListPRs(); err == nil {
+		t.Fatal("ListPRs with branch queries disabled succeeded, want error")
+	}
+
+	prs, err := d.ListPRsWithoutBranches()
+	if err != nil {
+		t.Fatalf("ListPRsWithoutBranches: %v", err)
+	}
+	if len(prs) != 1 || prs[0].PRNumber != 11 {
+		t.Fatalf("prs = %+v, want PR 11", prs)
+	}
+	if prs[0].Branches != nil {
+		t.Errorf("Branches = %+v, want nil", prs[0].Branches)
+	}
+}
+
 func TestUpdateLastChecked(t *testing.T) {
 	d := newTestDB(t)
 
@@ -310,6 +684,9 @@ func TestMigrationFromV1(t *testing.T) {
 	if _, err := sqlDB.Exec(`INSERT INTO tracked_prs (pr_number) VALUES (99)`); err != nil {
 		t.Fatalf("inserting v1 row: %v", err)
 	}
+	if _, err := sqlDB.Exec(`INSERT INTO branch_status (pr_number, branch, landed) VALUES (99, 'master', 1)`); err != nil {
+		t.Fatalf("inserting v1 branch status: %v", err)
+	}
 
 	// Open via New() which should apply v2 migration.
 	d, err := New(dsn)
@@ -325,13 +702,410 @@ func TestMigrationFromV1(t *testing.T) {
 	if !pr.LastCheckedAt.IsZero() {
 		t.Errorf("LastCheckedAt for pre-existing row = %v, want zero", pr.LastCheckedAt)
 	}
+	if pr.Repo != "" {
+		t.Errorf("Repo for pre-existing row = %q, want the primary repo", pr.Repo)
+	}
+	if len(pr.Branches) != 1 || pr.Branches[0].Branch != "master" || !pr.Branches[0].Landed {
+		t.Errorf("Branches for pre-existing row = %+v, want [master landed]", pr.Branches)
+	}
 
-	// Verify user_version is now 2.
+	// Verify user_version is now the latest.
 	var version int
 	if err := d.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
 		t.Fatalf("PRAGMA user_version: %v", err)
 	}
-	if version != 2 {
-		t.Errorf("user_version = %d, want 2", version)
+	if version != 12 {
+		t.Errorf("user_version = %d, want 12", version)
+	}
+}
+
+func TestAddCommit(t *testing.T) {
+	d := newTestDB(t)
+
+	if err := d.AddCommit("abc123", "staging merge"); err != nil {
+		t.Fatalf("AddCommit: %v", err)
+	}
+	// INSERT OR IGNORE should not error on duplicate
+	if err := d.AddCommit("abc123", "staging merge"); err != nil {
+		t.Fatalf("duplicate AddCommit: %v", err)
+	}
+
+	commits, err := d.ListCommits()
+	if err != nil {
+		t.Fatalf("ListCommits: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("len(commits) = %d, want 1", len(commits))
+	}
+	if commits[0].SHA != "abc123" || commits[0].Title != "staging merge" {
+		t.Errorf("commit = %+v, want sha abc123 with title", commits[0])
+	}
+}
+
+func TestCommitBranchLandedAndRemove(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddCommit("def456", "")
+	if err := d.UpdateCommitBranchLanded("def456", "master"); err != nil {
+		t.Fatalf("UpdateCommitBranchLanded: %v", err)
+	}
+
+	c, err := d.GetCommit("def456")
+	if err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	if len(c.Branches) != 1 || !c.Branches[0].Landed || c.Branches[0].Branch != "master" {
+		t.Errorf("Branches = %+v, want [master landed]", c.Branches)
+	}
+
+	// Commit branch status must not leak into PR branch status.
+	if statuses, _ := d.GetBranchStatus(0); len(statuses) != 0 {
+		t.Errorf("PR branch statuses = %d, want 0", len(statuses))
+	}
+
+	if err := d.RemoveCommit("def456"); err != nil {
+		t.Fatalf("RemoveCommit: %v", err)
+	}
+	if _, err := d.GetCommit("def456"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCommit after removal = %v, want ErrNotFound", err)
+	}
+	statuses, err := d.GetCommitBranchStatus("def456")
+	if err != nil {
+		t.Fatalf("GetCommitBranchStatus: %v", err)
+	}
+	if len(statuses) != 0 {
+		t.Errorf("remaining commit branch statuses = %d, want 0", len(statuses))
+	}
+}
+
+func TestPruneEvents(t *testing.T) {
+	d := newTestDB(t)
+
+	now := time.Now()
+	d.AddEvent(EventRecord{Type: "pr_added", PRNumber: 1, CreatedAt: now.Add(-60 * 24 * time.Hour)})
+	d.AddEvent(EventRecord{Type: "pr_merged", PRNumber: 1, CreatedAt: now.Add(-31 * 24 * time.Hour)})
+	d.AddEvent(EventRecord{Type: "pr_landed_branch", PRNumber: 1, Branch: "nixos-unstable", CreatedAt: now.Add(-time.Hour)})
+
+	n, err := d.PruneEvents(now.Add(-30 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PruneEvents: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("pruned = %d, want 2", n)
+	}
+
+	events, err := d.ListEvents(10)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("len(events) = %d, want 1", len(events))
+	}
+	if events[0].Type != "pr_landed_branch" || events[0].Branch != "nixos-unstable" {
+		t.Errorf("remaining event = %+v, want the recent pr_landed_branch", events[0])
+	}
+}
+
+func TestListEventsOrdering(t *testing.T) {
+	d := newTestDB(t)
+
+	now := time.Now()
+	d.AddEvent(EventRecord{Type: "pr_added", PRNumber: 1, CreatedAt: now.Add(-2 * time.Minute)})
+	d.AddEvent(EventRecord{Type: "pr_merged", PRNumber: 1, CreatedAt: now})
+	d.AddEvent(EventRecord{Type: "pr_removed", PRNumber: 1, CreatedAt: now.Add(-time.Minute)})
+
+	events, err := d.ListEvents(2)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "pr_merged" || events[1].Type != "pr_removed" {
+		t.Errorf("events = %+v, want [pr_merged pr_removed]", events)
+	}
+	if !events[0].CreatedAt.Equal(now) {
+		t.Errorf("CreatedAt = %v, want %v", events[0].CreatedAt, now)
+	}
+}
+
+func TestListEventsByType(t *testing.T) {
+	d := newTestDB(t)
+
+	now := time.Now()
+	d.AddEvent(EventRecord{Type: "pr_landed_branch", PRNumber: 1, Branch: "master", CreatedAt: now.Add(-3 * time.Minute)})
+	d.AddEvent(EventRecord{Type: "pr_merged", PRNumber: 2, CreatedAt: now.Add(-2 * time.Minute)})
+	d.AddEvent(EventRecord{Type: "pr_fully_landed", PRNumber: 1, CreatedAt: now.Add(-time.Minute)})
+	d.AddEvent(EventRecord{Type: "pr_added", PRNumber: 3, CreatedAt: now})
+
+	events, err := d.ListEventsByType(10, "pr_landed_branch", "pr_fully_landed")
+	if err != nil {
+		t.Fatalf("ListEventsByType: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "pr_fully_landed" || events[1].Type != "pr_landed_branch" {
+		t.Errorf("events = %+v, want [pr_fully_landed pr_landed_branch]", events)
+	}
+
+	events, err = d.ListEventsByType(1, "pr_landed_branch", "pr_fully_landed")
+	if err != nil {
+		t.Fatalf("ListEventsByType: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "pr_fully_landed" {
+		t.Errorf("events = %+v, want only the newest landing", events)
+	}
+}
+
+func TestRestorePR(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(300)
+	d.UpdatePRStatus(300, "merged", "sha300", "qux: 1 -> 2", "ivan")
+	d.UpdatePRBase(300, "staging")
+	d.UpdatePRBody(300, "Bumps qux.")
+	d.UpdateBranchLanded(300, "master")
+	d.UpdateLastChecked(300)
+	d.UpdatePRMergedAt(300, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	before, err := d.GetPR(300)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if err := d.RemovePR(300); err != nil {
+		t.Fatalf("RemovePR: %v", err)
+	}
+
+	if err := d.RestorePR(*before); err != nil {
+		t.Fatalf("RestorePR: %v", err)
+	}
+	after, err := d.GetPR(300)
+	if err != nil {
+		t.Fatalf("GetPR after restore: %v", err)
+	}
+	if after.Title != before.Title || after.Author != before.Author || after.Status != before.Status || after.MergeCommit != before.MergeCommit || after.BaseRef != before.BaseRef || after.Body != before.Body {
+		t.Errorf("restored PR = %+v, want %+v", after, before)
+	}
+	if !after.CreatedAt.Equal(before.CreatedAt) || !after.LastCheckedAt.Equal(before.LastCheckedAt) || !after.MergedAt.Equal(before.MergedAt) {
+		t.Errorf("restored timestamps = %v/%v/%v, want %v/%v/%v", after.CreatedAt, after.LastCheckedAt, after.MergedAt, before.CreatedAt, before.LastCheckedAt, before.MergedAt)
+	}
+	if len(after.Branches) != 1 || after.Branches[0].Branch != "master" || !after.Branches[0].Landed ||
+		after.Branches[0].LandedAt == nil || !after.Branches[0].LandedAt.Equal(*before.Branches[0].LandedAt) {
+		t.Errorf("restored branches = %+v, want %+v", after.Branches, before.Branches)
+	}
+
+	if err := d.RestorePR(*before); err == nil {
+		t.Error("RestorePR of a tracked PR: expected error")
+	}
+}
+
+func TestPreparedStatementsConcurrent(t *testing.T) {
+	dsn := "file:" + t.Name() + "?mode=memory&cache=shared"
+	d, err := New(dsn, WithMaxOpenConns(4), WithMaxIdleConns(4))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if err := d.AddPR(1); err != nil {
+		t.Fatalf("AddPR: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.UpdateBranchLanded(1, "nixos-unstable"); err != nil {
+				errs <- err
+			}
+			if _, err := d.GetPR(1); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent call: %v", err)
+	}
+
+	pr, err := d.GetPR(1)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if len(pr.Branches) != 1 || !pr.Branches[0].Landed {
+		t.Errorf("branches = %+v, want nixos-unstable landed", pr.Branches)
+	}
+}
+
+func TestCloseReleasesStatements(t *testing.T) {
+	d := newTestDB(t)
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := d.GetPR(1); err == nil {
+		t.Error("GetPR after Close succeeded, want error")
+	}
+}
+
+func BenchmarkGetPR(b *testing.B) {
+	d, err := New("file:" + b.Name() + "?mode=memory&cache=shared")
+	if err != nil {
+		b.Fatalf("New: %v", err)
+	}
+	b.Cleanup(func() { d.Close() })
+	d.AddPR(1)
+	d.UpdateBranchLanded(1, "master")
+	d.UpdateBranchLanded(1, "nixos-unstable")
+
+	b.Run("prepared", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := d.GetPR(1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unprepared", func(b *testing.B) {
+		for b.Loop() {
+			var pr TrackedPR
+			if err := d.db.QueryRow(
+				`SELECT id, pr_number, title, author, status, merge_commit, created_at, updated_at, last_checked_at FROM tracked_prs WHERE pr_number = ?`, 1,
+			).Scan(&pr.ID, &pr.PRNumber, &pr.Title, &pr.Author, &pr.Status, &pr.MergeCommit, &pr.CreatedAt, &pr.UpdatedAt, &pr.LastCheckedAt); err != nil {
+				b.Fatal(err)
+			}
+			rows, err := d.db.Query(`SELECT branch, landed, landed_at FROM branch_status WHERE pr_number = ?`, 1)
+			if err != nil {
+				b.Fatal(err)
+			}
+			rows.Close()
+		}
+	})
+}
+
+func TestResetBranchStatus(t *testing.T) {
+	d := newTestDB(t)
+
+	d.AddPR(310)
+	d.UpdatePRStatus(310, "merged", "sha310", "reset", "kim")
+	d.UpdateBranchLanded(310, "master")
+	d.UpdateBranchLanded(310, "nixos-unstable")
+	d.AddPR(311)
+	d.UpdateBranchLanded(311, "master")
+
+	if err := d.ResetBranchStatus(310); err != nil {
+		t.Fatalf("ResetBranchStatus: %v", err)
+	}
+
+	pr, err := d.GetPR(310)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if len(pr.Branches) != 0 {
+		t.Errorf("branches = %+v, want none", pr.Branches)
+	}
+	if pr.Status != "merged" || pr.MergeCommit != "sha310" {
+		t.Errorf("PR = %+v, want status and merge commit kept", pr)
+	}
+
+	// Other PRs are untouched.
+	other, _ := d.GetPR(311)
+	if len(other.Branches) != 1 {
+		t.Errorf("PR 311 branches = %+v, want 1", other.Branches)
+	}
+
+	// A PR kept as landed goes back to merged so the poller checks it again.
+	d.AddPR(312)
+	d.UpdatePRStatus(312, "landed", "sha312", "kept", "kim")
+	d.UpdateBranchLanded(312, "nixos-unstable")
+	if err := d.ResetBranchStatus(312); err != nil {
+		t.Fatalf("ResetBranchStatus(312): %v", err)
+	}
+	landed, err := d.GetPR(312)
+	if err != nil {
+		t.Fatalf("GetPR(312): %v", err)
+	}
+	if landed.Status != "merged" || landed.MergeCommit != "sha312" || len(landed.Branches) != 0 {
+		t.Errorf("PR 312 = %+v, want merged with sha312 and no branches", landed)
+	}
+
+	// An open PR keeps its status.
+	d.AddPR(313)
+	d.UpdatePRStatus(313, "open", "", "open", "kim")
+	if err := d.ResetBranchStatus(313); err != nil {
+		t.Fatalf("ResetBranchStatus(313): %v", err)
+	}
+	if open, _ := d.GetPR(313); open.Status != "open" {
+		t.Errorf("PR 313 status = %q, want open", open.Status)
+	}
+
+	if err := d.ResetBranchStatus(999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResetBranchStatus(999) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestForRepo(t *testing.T) {
+	d := newTestDB(t)
+	other := d.ForRepo("owner/other")
+	if d.Repo() != "" || other.Repo() != "owner/other" {
+		t.Fatalf("Repo() = %q, %q; want \"\", \"owner/other\"", d.Repo(), other.Repo())
+	}
+
+	// The same PR number and SHA are tracked independently in each repo.
+	d.AddPR(42)
+	other.AddPR(42)
+	d.UpdatePRStatus(42, "merged", "aaa", "primary PR", "alice")
+	other.UpdatePRStatus(42, "open", "", "other PR", "bob")
+	d.UpdateBranchLanded(42, "master")
+	other.UpdateBranchLanded(42, "main")
+	d.AddCommit("abc123", "primary commit")
+	other.AddCommit("abc123", "other commit")
+	other.UpdateCommitBranchLanded("abc123", "main")
+
+	pr, err := d.GetPR(42)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Repo != "" || pr.Title != "primary PR" || len(pr.Branches) != 1 || pr.Branches[0].Branch != "master" {
+		t.Errorf("primary PR = %+v, want primary PR landed in master", pr)
+	}
+	pr, err = other.GetPR(42)
+	if err != nil {
+		t.Fatalf("other GetPR: %v", err)
+	}
+	if pr.Repo != "owner/other" || pr.Title != "other PR" || len(pr.Branches) != 1 || pr.Branches[0].Branch != "main" {
+		t.Errorf("other PR = %+v, want other PR landed in main", pr)
+	}
+	if _, err := other.GetPRByMergeCommit("aaa"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other GetPRByMergeCommit(primary's commit) = %v, want ErrNotFound", err)
+	}
+
+	c, err := d.GetCommit("abc123")
+	if err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	if c.Title != "primary commit" || len(c.Branches) != 0 {
+		t.Errorf("primary commit = %+v, want no branches", c)
+	}
+
+	// Removing a PR from one repo leaves the other's alone.
+	if err := other.RemovePR(42); err != nil {
+		t.Fatalf("other RemovePR: %v", err)
+	}
+	if prs, _ := other.ListPRs(); len(prs) != 0 {
+		t.Errorf("other ListPRs after remove = %+v, want none", prs)
+	}
+	prs, err := d.ListPRs()
+	if err != nil {
+		t.Fatalf("ListPRs: %v", err)
+	}
+	if len(prs) != 1 || prs[0].Title != "primary PR" || len(prs[0].Branches) != 1 {
+		t.Errorf("primary ListPRs = %+v, want the primary PR with its branch", prs)
+	}
+
+	// Events are one log across repos, each tagged with its repo.
+	other.AddEvent(EventRecord{Repo: "owner/other", Type: "pr_added", PRNumber: 42, CreatedAt: time.Now()})
+	events, err := d.ListEvents(10)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].Repo != "owner/other" {
+		t.Errorf("ListEvents = %+v, want one owner/other event", events)
 	}
 }
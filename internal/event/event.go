@@ -1,8 +1,11 @@
 package event
 
 import (
+	"context"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type Type string
@@ -11,33 +14,125 @@ const (
 	PRAdded        Type = "pr_added"
 	PRRemoved      Type = "pr_removed"
 	PRMerged       Type = "pr_merged"
+	PRReopened     Type = "pr_reopened"
 	PRLandedBranch Type = "pr_landed_branch"
+	PRChecksPassed Type = "pr_checks_passed"
+	PRFullyLanded  Type = "pr_fully_landed"
+	PRError        Type = "pr_error"
+
+	CommitLandedBranch Type = "commit_landed_branch"
+	CommitRemoved      Type = "commit_removed"
+
+	RateLimited Type = "rate_limited"
+
+	// DailySummary is the digest published once a day with
+	// NPT_SUMMARY_AT. It isn't about a single PR; see Event.Summary.
+	DailySummary Type = "daily_summary"
+
+	// NotificationFailed is published when a notifier gives up on an event.
+	// It is never delivered back to the notifier that failed.
+	NotificationFailed Type = "notification_failed"
 )
 
+// Types lists every event type the bus can publish.
+var Types = []Type{
+	PRAdded,
+	PRRemoved,
+	PRMerged,
+	PRReopened,
+	PRLandedBranch,
+	PRChecksPassed,
+	PRFullyLanded,
+	PRError,
+	CommitLandedBranch,
+	CommitRemoved,
+	RateLimited,
+	DailySummary,
+	NotificationFailed,
+}
+
 type Event struct {
 	Type      Type
+	Repo      string // "owner/name" of an NPT_REPOS repository; empty for NPT_GITHUB_REPO
 	PRNumber  int
 	Title     string
 	Author    string
 	Branch    string
+	Commit    string
+	Branches  []string  // every branch landed in, for PRFullyLanded, or in one poll, for a grouped PRLandedBranch
+	ResetAt   time.Time // when polling resumes, for RateLimited
+	Error     string    // the last failure, for PRError and NotificationFailed
+	Notifier  string    // the notifier that gave up, for NotificationFailed
+	Body      string    // the PR description as an Excerpt, with NPT_NOTIFY_INCLUDE_BODY
+	Summary   *Summary  // the digest, for DailySummary
 	Timestamp time.Time
 }
 
+// Summary is the content of a DailySummary event.
+type Summary struct {
+	Landed  []int // PRs that landed in a branch in the last day
+	Pending []int // merged PRs not yet landed in every target branch
+
+	// OldestPending is the pending PR merged longest ago, and PendingSince
+	// when it was merged; zero when nothing is pending.
+	OldestPending int
+	PendingSince  time.Time
+}
+
+// MaxBodyLen is the most characters of a PR description Excerpt keeps.
+const MaxBodyLen = 500
+
+// Excerpt trims a PR description to at most MaxBodyLen characters, ending
+// it with "…" if anything was cut. It is idempotent.
+func Excerpt(body string) string {
+	body = strings.TrimSpace(body)
+	if utf8.RuneCountInString(body) <= MaxBodyLen {
+		return body
+	}
+	r := []rune(body)
+	return strings.TrimSpace(string(r[:MaxBodyLen-1])) + "…"
+}
+
 type Handler func(Event)
 
 type Bus struct {
 	mu       sync.RWMutex
 	handlers []Handler
+
+	// async buses deliver through a queue per handler, each drained by a
+	// goroutine counted in running.
+	async   bool
+	queues  []*queue
+	running sync.WaitGroup
 }
 
+// New returns a synchronous bus: Publish calls each handler in turn and
+// returns once they all have.
 func New() *Bus {
 	return &Bus{}
 }
 
+// NewAsync returns a bus that delivers to each handler from a goroutine of
+// its own, in publish order, so Publish returns at once and a slow handler
+// holds up neither the publisher nor the other handlers. Close drains it.
+func NewAsync() *Bus {
+	return &Bus{async: true}
+}
+
 func (b *Bus) Subscribe(h Handler) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.handlers = append(b.handlers, h)
+	if !b.async {
+		b.handlers = append(b.handlers, h)
+		return
+	}
+	q := newQueue()
+	b.queues = append(b.queues, q)
+	b.running.Add(1)
+	go func() {
+		defer b.running.Done()
+		q.run(h)
+	}()
 }
 
 func (b *Bus) Publish(e Event) {
@@ -46,4 +141,78 @@ func (b *Bus) Publish(e Event) {
 	for _, h := range b.handlers {
 		h(e)
 	}
+	for _, q := range b.queues {
+		q.push(e)
+	}
+}
+
+// Close waits for an async bus to deliver the events already published,
+// returning ctx.Err() if ctx ends first. Handlers may still publish while it
+// drains; events published once it has returned are dropped. It is a no-op
+// for a synchronous bus.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.RLock()
+	for _, q := range b.queues {
+		q.close()
+	}
+	b.mu.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// queue is an unbounded FIFO of events for one handler of an async bus.
+// Unbounded so a handler that publishes never blocks on its own queue.
+type queue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	events []Event
+	closed bool
+}
+
+func newQueue() *queue {
+	q := &queue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *queue) push(e Event) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+func (q *queue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// run hands queued events to h until the queue is closed and empty.
+func (q *queue) run(h Handler) {
+	for {
+		q.mu.Lock()
+		for len(q.events) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.events) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		e := q.events[0]
+		q.events = q.events[1:]
+		q.mu.Unlock()
+		h(e)
+	}
 }
@@ -1,12 +1,48 @@
 package event
 
 import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
+func TestPRReopenedType(t *testing.T) {
+	b, err := json.Marshal(PRReopened)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(b) != `"pr_reopened"` {
+		t.Errorf("PRReopened = %s, want \"pr_reopened\"", b)
+	}
+	if !slices.Contains(Types, PRReopened) {
+		t.Error("Types is missing PRReopened")
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if got := Excerpt("  Short description.\n"); got != "Short description." {
+		t.Errorf("Excerpt(short) = %q, want it trimmed but otherwise intact", got)
+	}
+
+	long := strings.Repeat("ü", MaxBodyLen+100)
+	got := Excerpt(long)
+	if n := utf8.RuneCountInString(got); n != MaxBodyLen {
+		t.Errorf("Excerpt(long) has %d characters, want %d", n, MaxBodyLen)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("Excerpt(long) = %q..., want a trailing ellipsis", got[:20])
+	}
+	if again := Excerpt(got); again != got {
+		t.Error("Excerpt is not idempotent")
+	}
+}
+
 func TestPublishNoSubscribers(t *testing.T) {
 	bus := New()
 	// Should not panic
@@ -80,3 +116,81 @@ func TestConcurrentPublish(t *testing.T) {
 		t.Errorf("count = %d, want 100", count.Load())
 	}
 }
+
+func TestSyncPublishWaitsForHandlers(t *testing.T) {
+	bus := New()
+
+	var done atomic.Bool
+	bus.Subscribe(func(e Event) {
+		time.Sleep(50 * time.Millisecond)
+		done.Store(true)
+	})
+	bus.Publish(Event{Type: PRAdded, PRNumber: 1})
+
+	if !done.Load() {
+		t.Error("Publish returned before the handler finished")
+	}
+}
+
+func TestAsyncPublishReturnsImmediately(t *testing.T) {
+	bus := NewAsync()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []int
+	bus.Subscribe(func(e Event) {
+		<-release
+		mu.Lock()
+		got = append(got, e.PRNumber)
+		mu.Unlock()
+	})
+	var other atomic.Int64
+	bus.Subscribe(func(e Event) { other.Add(1) })
+
+	published := make(chan struct{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			bus.Publish(Event{Type: PRAdded, PRNumber: i})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a slow handler")
+	}
+
+	// The slow handler holds up nobody else.
+	deadline := time.Now().Add(2 * time.Second)
+	for other.Load() != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := other.Load(); n != 3 {
+		t.Errorf("other handler got %d events, want 3", n)
+	}
+
+	close(release)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("slow handler got %v, want [1 2 3] after Close", got)
+	}
+}
+
+func TestAsyncCloseDeadline(t *testing.T) {
+	bus := NewAsync()
+
+	release := make(chan struct{})
+	defer close(release)
+	bus.Subscribe(func(e Event) { <-release })
+	bus.Publish(Event{Type: PRAdded, PRNumber: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close = %v, want context.DeadlineExceeded", err)
+	}
+}
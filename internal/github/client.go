@@ -1,12 +1,19 @@
 package github
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -20,35 +27,234 @@ func (e *RateLimitError) Error() string {
 	return fmt.Sprintf("GitHub API rate limited, resets at %s", e.RetryAfter.Format(time.RFC3339))
 }
 
+// ErrNotFound is wrapped by errors for GitHub 404 responses.
+var ErrNotFound = errors.New("not found")
+
+// ErrTransient is wrapped by errors that are worth retrying as is, such as a
+// response body cut off when the connection dropped.
+var ErrTransient = errors.New("transient failure")
+
+// decodeError wraps a JSON decode error of what, marking a truncated body as
+// ErrTransient rather than malformed JSON.
+func decodeError(what string, err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("decoding %s: %w: %w", what, ErrTransient, err)
+	}
+	return fmt.Errorf("decoding %s: %w", what, err)
+}
+
 type PRInfo struct {
 	Number      int
 	Title       string
 	Author      string
 	State       string // "open", "closed"
 	Merged      bool
+	MergedAt    time.Time // zero unless merged
 	MergeCommit string
+	HeadSHA     string
+	BaseRef     string // branch the PR targets, e.g. "master" or "staging"
+	Body        string // the PR description, empty if there is none
+	Locked      bool   // conversation locked; only collaborators can comment
+}
+
+// CheckRun is a single CI check run reported for a commit.
+type CheckRun struct {
+	Name       string
+	Status     string // "queued", "in_progress", "completed"
+	Conclusion string // set once completed: "success", "failure", "neutral", "skipped", ...
+}
+
+// AllChecksPassed reports whether runs is non-empty and every run has
+// completed with a success, neutral or skipped conclusion.
+func AllChecksPassed(runs []CheckRun) bool {
+	if len(runs) == 0 {
+		return false
+	}
+	for _, r := range runs {
+		if r.Status != "completed" {
+			return false
+		}
+		switch r.Conclusion {
+		case "success", "neutral", "skipped":
+		default:
+			return false
+		}
+	}
+	return true
 }
 
+// DefaultAPIVersion is the REST API version sent in X-GitHub-Api-Version
+// unless WithAPIVersion is given.
+const DefaultAPIVersion = "2022-11-28"
+
+// DefaultRepo is the repository a Client targets unless WithRepo is given.
+const DefaultRepo = "NixOS/nixpkgs"
+
+// DefaultLandedStatuses are the compare statuses IsCommitInBranch counts as
+// landed unless WithLandedStatuses is given.
+var DefaultLandedStatuses = []string{"behind", "identical"}
+
 type Client struct {
-	httpClient *http.Client
-	token      string
-	BaseURL    string
+	httpClient     *http.Client
+	token          string
+	proxyURL       *url.URL
+	transport      http.RoundTripper
+	repo           string // "owner/name"
+	apiVersion     string
+	landedStatuses map[string]bool
+	BaseURL        string
+
+	// rate is shared with the clients ForRepo returns, since they spend
+	// the same token's rate limit.
+	rate *rateState
+}
+
+type rateState struct {
+	mu   sync.Mutex // guards last and seen
+	last RateLimit  // as reported by the latest response
+	seen bool
+}
+
+// RateLimit is the state of the rate limit GitHub reports with each
+// response.
+type RateLimit struct {
+	Remaining int       // X-RateLimit-Remaining
+	Reset     time.Time // X-RateLimit-Reset, when Remaining is restored
 }
 
-func New(token string) *Client {
-	return &Client{
-		httpClient: &http.Client{},
+// Option configures optional Client behavior.
+type Option func(*Client)
+
+// WithProxy routes all requests through proxyURL, overriding the proxy
+// derived from HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(c *Client) {
+		c.proxyURL = proxyURL
+	}
+}
+
+// WithTransport sends every request through rt instead of a transport
+// built from the environment, e.g. to add tracing or caching. WithProxy has
+// no effect then; rt is responsible for any proxy.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// WithRepo targets repo, given as "owner/name", instead of DefaultRepo, e.g.
+// a fork that mirrors the nixpkgs branch layout.
+func WithRepo(repo string) Option {
+	return func(c *Client) {
+		c.repo = repo
+	}
+}
+
+// WithAPIVersion pins the REST API version sent with every request instead
+// of DefaultAPIVersion.
+func WithAPIVersion(version string) Option {
+	return func(c *Client) {
+		c.apiVersion = version
+	}
+}
+
+// WithLandedStatuses replaces the compare statuses IsCommitInBranch treats
+// as landed. Adding "diverged" helps with branches fed by squash merges,
+// where the merge commit itself never appears, at the risk of reporting
+// commits that genuinely diverged as landed.
+func WithLandedStatuses(statuses []string) Option {
+	return func(c *Client) {
+		c.landedStatuses = make(map[string]bool, len(statuses))
+		for _, st := range statuses {
+			c.landedStatuses[st] = true
+		}
+	}
+}
+
+func New(token string, opts ...Option) *Client {
+	c := &Client{
+		rate:       &rateState{},
 		token:      token,
+		repo:       DefaultRepo,
+		apiVersion: DefaultAPIVersion,
 		BaseURL:    "https://api.github.com",
 	}
+	WithLandedStatuses(DefaultLandedStatuses)(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	transport := c.transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = http.ProxyFromEnvironment
+		if c.proxyURL != nil {
+			t.Proxy = http.ProxyURL(c.proxyURL)
+		}
+		transport = t
+	}
+	c.httpClient = &http.Client{Transport: transport, CheckRedirect: c.checkRedirect}
+	return c
+}
+
+// RateLimit returns the rate limit reported by the latest response that
+// carried one, and false if none has yet. It is kept in memory only.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	c.rate.mu.Lock()
+	defer c.rate.mu.Unlock()
+	return c.rate.last, c.rate.seen
+}
+
+// Repo returns the "owner/name" repository the client targets.
+func (c *Client) Repo() string {
+	return c.repo
+}
+
+// ForRepo returns a client for repo, given as "owner/name", that shares c's
+// connections, token, settings and rate limit. Set BaseURL on c before
+// calling it; the returned client keeps the value it had.
+func (c *Client) ForRepo(repo string) *Client {
+	rc := *c
+	rc.repo = repo
+	return &rc
+}
+
+// maxRedirects matches net/http's default redirect limit.
+const maxRedirects = 10
+
+// checkRedirect follows GitHub's 301/302/307 redirects for renamed or
+// transferred repositories. The token is re-sent only when the redirect stays
+// on the original API host; it is never forwarded to another host.
+func (c *Client) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	log.Printf("github: %s redirected to %s", via[len(via)-1].URL.Redacted(), req.URL.Redacted())
+	if req.URL.Host == via[0].URL.Host && c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+func (c *Client) doRequest(ctx context.Context, reqURL string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, reqURL, nil)
 }
 
-func (c *Client) doRequest(ctx context.Context, url string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// send makes an API request with the client's headers and token, sending
+// body as JSON when it is non-nil. A 403 or 429 with no requests remaining
+// is returned as a *RateLimitError.
+func (c *Client) send(ctx context.Context, method, reqURL string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
 		return nil, err
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", c.apiVersion)
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
@@ -58,6 +264,15 @@ func (c *Client) doRequest(ctx context.Context, url string) (*http.Response, err
 	}
 	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
 		log.Printf("GitHub API rate limit: %s remaining", remaining)
+		if n, err := strconv.Atoi(remaining); err == nil {
+			rate := RateLimit{Remaining: n}
+			if epoch, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				rate.Reset = time.Unix(epoch, 0)
+			}
+			c.rate.mu.Lock()
+			c.rate.last, c.rate.seen = rate, true
+			c.rate.mu.Unlock()
+		}
 	}
 	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
 		if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining == "0" {
@@ -74,53 +289,264 @@ func (c *Client) doRequest(ctx context.Context, url string) (*http.Response, err
 	return resp, nil
 }
 
+// maxPages bounds how many pages getAllPages follows for a single listing.
+const maxPages = 10
+
+// maxFilePages covers every page of a PR's files: GitHub lists at most 3000,
+// and a treewide PR can reach that.
+const maxFilePages = 30
+
+// errStopPaging is returned by a getAllPages decode function that has found
+// what it was looking for, so the remaining pages are not fetched.
+var errStopPaging = errors.New("stop paging")
+
+// getAllPages fetches reqURL and then each page named by the rel="next" link
+// in the Link header, up to limit pages, handing every response body to
+// decode. Next links must stay on the host of reqURL so the token is never
+// sent elsewhere. what describes the listing in error messages.
+func (c *Client) getAllPages(ctx context.Context, reqURL, what string, limit int, decode func(io.Reader) error) error {
+	first, err := url.Parse(reqURL)
+	if err != nil {
+		return err
+	}
+	for page := 1; reqURL != ""; page++ {
+		if page > limit {
+			log.Printf("github: %s has more than %d pages, ignoring the rest", what, limit)
+			return nil
+		}
+		resp, err := c.doRequest(ctx, reqURL)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("GitHub API returned %d for %s", resp.StatusCode, what)
+		}
+		err = decode(resp.Body)
+		resp.Body.Close()
+		if errors.Is(err, errStopPaging) {
+			return nil
+		}
+		if err != nil {
+			return decodeError(what, err)
+		}
+
+		reqURL = nextPageURL(resp.Header)
+		if reqURL != "" {
+			next, err := url.Parse(reqURL)
+			if err != nil {
+				return fmt.Errorf("invalid next page link for %s: %w", what, err)
+			}
+			if next.Host != first.Host {
+				return fmt.Errorf("next page link for %s points to another host %q", what, next.Host)
+			}
+		}
+	}
+	return nil
+}
+
+// nextPageURL returns the rel="next" URL from a GitHub Link header, or "" on
+// the last page. The header looks like:
+//
+//	<https://api.github.com/...&page=2>; rel="next", <https://api.github.com/...&page=5>; rel="last"
+func nextPageURL(h http.Header) string {
+	for _, link := range strings.Split(h.Get("Link"), ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		target = strings.TrimSpace(target)
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
+
 func (c *Client) GetPR(ctx context.Context, prNumber int) (*PRInfo, error) {
-	url := fmt.Sprintf("%s/repos/NixOS/nixpkgs/pulls/%d", c.BaseURL, prNumber)
-	resp, err := c.doRequest(ctx, url)
+	if prNumber <= 0 {
+		return nil, fmt.Errorf("invalid PR number %d", prNumber)
+	}
+	reqURL := fmt.Sprintf("%s/repos/%s/pulls/%d", c.BaseURL, c.repo, prNumber)
+	resp, err := c.doRequest(ctx, reqURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetching PR %d: %w", prNumber, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("GitHub API returned 404 for PR %d: %w", prNumber, ErrNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("GitHub API returned %d for PR %d", resp.StatusCode, prNumber)
 	}
 
-	var data struct {
-		Number int    `json:"number"`
-		Title  string `json:"title"`
-		User   struct {
-			Login string `json:"login"`
-		} `json:"user"`
-		State          string `json:"state"`
-		Merged         bool   `json:"merged"`
-		MergeCommitSHA string `json:"merge_commit_sha"`
+	var data pullRequest
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, decodeError(fmt.Sprintf("PR %d response", prNumber), err)
 	}
+	return data.info(), nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("decoding PR %d response: %w", prNumber, err)
+// GetPRForCommit returns the PR associated with sha. A commit can belong to
+// several PRs, e.g. a backport's cherry-pick source; the merged one is
+// preferred, then the first GitHub lists. It returns an error wrapping
+// ErrNotFound when GitHub knows no PR for the commit, or doesn't know the
+// commit.
+func (c *Client) GetPRForCommit(ctx context.Context, sha string) (*PRInfo, error) {
+	if sha == "" {
+		return nil, fmt.Errorf("looking up PR for commit: sha must be non-empty")
+	}
+	reqURL := fmt.Sprintf("%s/repos/%s/commits/%s/pulls", c.BaseURL, c.repo, url.PathEscape(sha))
+	resp, err := c.doRequest(ctx, reqURL)
+	if err != nil {
+		return nil, fmt.Errorf("looking up PR for commit %s: %w", sha, err)
 	}
+	defer resp.Body.Close()
 
+	// An unknown SHA is a 422 rather than a 404.
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity {
+		return nil, fmt.Errorf("GitHub API returned %d for commit %s: %w", resp.StatusCode, sha, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub API returned %d for commit %s", resp.StatusCode, sha)
+	}
+
+	var prs []pullRequest
+	if err := json.NewDecoder(resp.Body).Decode(&prs); err != nil {
+		return nil, decodeError(fmt.Sprintf("pulls of commit %s", sha), err)
+	}
+	if len(prs) == 0 {
+		return nil, fmt.Errorf("no PR for commit %s: %w", sha, ErrNotFound)
+	}
+	for _, pr := range prs {
+		if info := pr.info(); info.Merged {
+			return info, nil
+		}
+	}
+	return prs[0].info(), nil
+}
+
+// pullRequest is a pull request as the REST API and webhook payloads encode
+// it.
+type pullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	User   struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	State          string `json:"state"`
+	Locked         bool   `json:"locked"`
+	Merged         bool   `json:"merged"`
+	MergedAt       string `json:"merged_at"`
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	Head           struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (d pullRequest) info() *PRInfo {
+	mergedAt, _ := time.Parse(time.RFC3339, d.MergedAt)
 	return &PRInfo{
-		Number:      data.Number,
-		Title:       data.Title,
-		Author:      data.User.Login,
-		State:       data.State,
-		Merged:      data.Merged,
-		MergeCommit: data.MergeCommitSHA,
+		Number:      d.Number,
+		Title:       d.Title,
+		Author:      d.User.Login,
+		State:       d.State,
+		Merged:      d.Merged || d.MergedAt != "",
+		MergedAt:    mergedAt,
+		MergeCommit: d.MergeCommitSHA,
+		HeadSHA:     d.Head.SHA,
+		BaseRef:     d.Base.Ref,
+		Body:        d.Body,
+		Locked:      d.Locked,
+	}
+}
+
+// PullRequestEvent is the payload of a "pull_request" webhook delivery.
+type PullRequestEvent struct {
+	Action string // e.g. "opened", "closed", "reopened"
+	Repo   string // "owner/name" of the repository the PR belongs to
+	PR     *PRInfo
+}
+
+// ParsePullRequestEvent decodes the body of a "pull_request" webhook
+// delivery.
+func ParsePullRequestEvent(body []byte) (*PullRequestEvent, error) {
+	var data struct {
+		Action      string      `json:"action"`
+		PullRequest pullRequest `json:"pull_request"`
+		Repository  struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("decoding pull_request event: %w", err)
+	}
+	if data.PullRequest.Number <= 0 {
+		return nil, fmt.Errorf("pull_request event without a PR number")
+	}
+	return &PullRequestEvent{
+		Action: data.Action,
+		Repo:   data.Repository.FullName,
+		PR:     data.PullRequest.info(),
 	}, nil
 }
 
+// IsCommitInBranch reports whether sha is reachable from branch, i.e. whether
+// CompareStatus returns one of the landed statuses.
 func (c *Client) IsCommitInBranch(ctx context.Context, sha string, branch string) (bool, error) {
-	url := fmt.Sprintf("%s/repos/NixOS/nixpkgs/compare/%s...%s", c.BaseURL, branch, sha)
-	resp, err := c.doRequest(ctx, url)
+	status, err := c.CompareStatus(ctx, sha, branch)
 	if err != nil {
-		return false, fmt.Errorf("comparing %s to %s: %w", sha, branch, err)
+		return false, err
+	}
+	return c.IsLandedStatus(status), nil
+}
+
+// IsLandedStatus reports whether a compare status counts as landed (see
+// WithLandedStatuses).
+func (c *Client) IsLandedStatus(status string) bool {
+	return c.landedStatuses[status]
+}
+
+// CompareStatus returns GitHub's compare status of sha against branch:
+// "behind" means branch contains sha and has moved past it, "identical" means
+// they point to the same commit, and "ahead" and "diverged" mean sha has
+// commits branch lacks. branch may be a branch name or any ref GitHub's
+// compare API accepts, such as "refs/heads/staging-next" or
+// "refs/tags/24.11"; it is escaped into a single path segment.
+//
+// The compare runs as base=branch, head=sha. GitHub lists the commits and
+// files from the merge base to head, and once sha has landed the merge base
+// is sha itself, so a landed commit yields an empty diff however far the
+// branch has moved on. The reverse direction (sha...branch) would list every
+// commit the branch gained since sha, which is the large response to avoid.
+// Only status is read, so per_page=1 also keeps the commit list of a
+// not-yet-landed sha down to a single entry.
+func (c *Client) CompareStatus(ctx context.Context, sha string, branch string) (string, error) {
+	if sha == "" || branch == "" {
+		return "", fmt.Errorf("comparing %q to %q: sha and branch must be non-empty", sha, branch)
+	}
+	reqURL := fmt.Sprintf("%s/repos/%s/compare/%s...%s?per_page=1", c.BaseURL, c.repo, url.PathEscape(branch), url.PathEscape(sha))
+	resp, err := c.doRequest(ctx, reqURL)
+	if err != nil {
+		return "", fmt.Errorf("comparing %s to %s: %w", sha, branch, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("comparing %s to %s: %w", sha, branch, ErrNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("GitHub API returned %d for compare", resp.StatusCode)
+		return "", fmt.Errorf("GitHub API returned %d for compare", resp.StatusCode)
 	}
 
 	var data struct {
@@ -128,10 +554,208 @@ func (c *Client) IsCommitInBranch(ctx context.Context, sha string, branch string
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return false, fmt.Errorf("decoding compare response: %w", err)
+		return "", decodeError(fmt.Sprintf("compare response for %s in %s", sha, branch), err)
+	}
+	return data.Status, nil
+}
+
+// ChannelRevision fetches the commit a nixpkgs channel was built from, e.g.
+// https://channels.nixos.org/nixos-unstable/git-revision, whose body is the
+// bare SHA. The request goes through the client's proxy settings but never
+// carries the GitHub token. A 404 (no channel for that branch) wraps
+// ErrNotFound.
+func (c *Client) ChannelRevision(ctx context.Context, revisionURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, revisionURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching channel revision: %w", err)
 	}
+	defer resp.Body.Close()
 
-	// "behind" means sha is behind branch (i.e., branch contains sha)
-	// "identical" means they point to the same commit
-	return data.Status == "behind" || data.Status == "identical", nil
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("fetching channel revision %s: %w", revisionURL, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("channel revision %s returned %d", revisionURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("reading channel revision: %w", err)
+	}
+	rev := strings.TrimSpace(string(body))
+	if rev == "" || strings.ContainsAny(rev, " \t\n/") {
+		return "", fmt.Errorf("channel revision %s: unexpected body %q", revisionURL, rev)
+	}
+	return rev, nil
+}
+
+// BranchExists reports whether branch exists in the repository. branch may be a
+// branch name or a fully-qualified ref such as "refs/tags/24.11".
+func (c *Client) BranchExists(ctx context.Context, branch string) (bool, error) {
+	if branch == "" {
+		return false, fmt.Errorf("checking branch: branch must be non-empty")
+	}
+	var reqURL string
+	if rest, ok := strings.CutPrefix(branch, "refs/"); ok {
+		segments := strings.Split(rest, "/")
+		for i, seg := range segments {
+			segments[i] = url.PathEscape(seg)
+		}
+		reqURL = fmt.Sprintf("%s/repos/%s/git/ref/%s", c.BaseURL, c.repo, strings.Join(segments, "/"))
+	} else {
+		reqURL = fmt.Sprintf("%s/repos/%s/branches/%s", c.BaseURL, c.repo, url.PathEscape(branch))
+	}
+	resp, err := c.doRequest(ctx, reqURL)
+	if err != nil {
+		return false, fmt.Errorf("checking branch %s: %w", branch, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("GitHub API returned %d for branch %s", resp.StatusCode, branch)
+	}
+}
+
+// GetCheckRuns returns the check runs reported for sha, following pagination
+// when there are more than 100.
+func (c *Client) GetCheckRuns(ctx context.Context, sha string) ([]CheckRun, error) {
+	if sha == "" {
+		return nil, fmt.Errorf("listing check runs: sha must be non-empty")
+	}
+	reqURL := fmt.Sprintf("%s/repos/%s/commits/%s/check-runs?per_page=100", c.BaseURL, c.repo, url.PathEscape(sha))
+
+	var runs []CheckRun
+	err := c.getAllPages(ctx, reqURL, "check runs of "+sha, maxPages, func(body io.Reader) error {
+		var data struct {
+			CheckRuns []struct {
+				Name       string `json:"name"`
+				Status     string `json:"status"`
+				Conclusion string `json:"conclusion"`
+			} `json:"check_runs"`
+		}
+		if err := json.NewDecoder(body).Decode(&data); err != nil {
+			return err
+		}
+		for _, r := range data.CheckRuns {
+			runs = append(runs, CheckRun{Name: r.Name, Status: r.Status, Conclusion: r.Conclusion})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing check runs for %s: %w", sha, err)
+	}
+	return runs, nil
+}
+
+// GetPRFiles returns the paths of the files a PR changes, following
+// pagination when there are more than 100, up to the 3000 GitHub lists.
+func (c *Client) GetPRFiles(ctx context.Context, prNumber int) ([]string, error) {
+	if prNumber <= 0 {
+		return nil, fmt.Errorf("invalid PR number %d", prNumber)
+	}
+	reqURL := fmt.Sprintf("%s/repos/%s/pulls/%d/files?per_page=100", c.BaseURL, c.repo, prNumber)
+
+	var files []string
+	err := c.getAllPages(ctx, reqURL, fmt.Sprintf("files of PR %d", prNumber), maxFilePages, func(body io.Reader) error {
+		var data []struct {
+			Filename string `json:"filename"`
+		}
+		if err := json.NewDecoder(body).Decode(&data); err != nil {
+			return err
+		}
+		for _, f := range data {
+			files = append(files, f.Filename)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing files of PR %d: %w", prNumber, err)
+	}
+	return files, nil
+}
+
+// CommentOnPR posts body as a comment on a PR's conversation. The client's
+// token must be allowed to write to the repository's pull requests.
+func (c *Client) CommentOnPR(ctx context.Context, prNumber int, body string) error {
+	if prNumber <= 0 {
+		return fmt.Errorf("invalid PR number %d", prNumber)
+	}
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+	// PR conversation comments go through the issues API.
+	reqURL := fmt.Sprintf("%s/repos/%s/issues/%d/comments", c.BaseURL, c.repo, prNumber)
+	resp, err := c.send(ctx, http.MethodPost, reqURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("commenting on PR %d: %w", prNumber, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("GitHub API returned %d for comment on PR %d", resp.StatusCode, prNumber)
+	}
+	return nil
+}
+
+// mergeTimeSkew is how far a squash commit's committer date may be from the
+// PR's merged_at for IsPRInBranchHistory to match it by title.
+const mergeTimeSkew = time.Minute
+
+// IsPRInBranchHistory reports whether a commit on branch since the PR merged
+// looks like the squashed form of the PR: its subject ends with the "(#N)"
+// suffix GitHub appends to squash merges, or it starts with the PR title and
+// was committed when the PR merged. This is a heuristic for branches where
+// the merge commit SHA never appears. Without mergedAt only the suffix
+// counts, and the search starts at the newest commits.
+func (c *Client) IsPRInBranchHistory(ctx context.Context, prNumber int, title string, mergedAt time.Time, branch string) (bool, error) {
+	if branch == "" {
+		return false, fmt.Errorf("listing commits: branch must be non-empty")
+	}
+	reqURL := fmt.Sprintf("%s/repos/%s/commits?sha=%s&per_page=100", c.BaseURL, c.repo, url.QueryEscape(branch))
+	if !mergedAt.IsZero() {
+		reqURL += "&since=" + url.QueryEscape(mergedAt.Add(-mergeTimeSkew).UTC().Format(time.RFC3339))
+	}
+
+	prRef := fmt.Sprintf("(#%d)", prNumber)
+	found := false
+	err := c.getAllPages(ctx, reqURL, "commits in "+branch, maxPages, func(body io.Reader) error {
+		var data []struct {
+			Commit struct {
+				Message   string `json:"message"`
+				Committer struct {
+					Date time.Time `json:"date"`
+				} `json:"committer"`
+			} `json:"commit"`
+		}
+		if err := json.NewDecoder(body).Decode(&data); err != nil {
+			return err
+		}
+		for _, item := range data {
+			subject, _, _ := strings.Cut(item.Commit.Message, "\n")
+			subject = strings.TrimSpace(subject)
+			if strings.HasSuffix(subject, prRef) {
+				found = true
+			} else if title != "" && !mergedAt.IsZero() && strings.HasPrefix(subject, title) {
+				skew := item.Commit.Committer.Date.Sub(mergedAt)
+				found = skew >= -mergeTimeSkew && skew <= mergeTimeSkew
+			}
+			if found {
+				return errStopPaging
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("listing commits in %s: %w", branch, err)
+	}
+	return found, nil
 }
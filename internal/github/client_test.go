@@ -5,8 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,6 +26,38 @@ func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *httptest.S
 	return c, srv
 }
 
+// recordingTransport records each request before passing it on to
+// http.DefaultTransport.
+type recordingTransport struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.urls = append(rt.urls, req.Method+" "+req.URL.Path)
+	rt.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"number": 42, "state": "open"})
+	}))
+	t.Cleanup(srv.Close)
+
+	rt := &recordingTransport{}
+	c := New("", WithTransport(rt))
+	c.BaseURL = srv.URL
+
+	if _, err := c.GetPR(context.Background(), 42); err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if strings.Join(rt.urls, ",") != "GET /repos/NixOS/nixpkgs/pulls/42" {
+		t.Errorf("transport saw %q, want the GetPR request", rt.urls)
+	}
+}
+
 func TestGetPRMerged(t *testing.T) {
 	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{
@@ -53,6 +91,64 @@ func TestGetPRMerged(t *testing.T) {
 	}
 }
 
+func TestGetPRBaseRef(t *testing.T) {
+	tests := []struct {
+		name string
+		base any
+		want string
+	}{
+		{"master", map[string]any{"ref": "master", "sha": "b1"}, "master"},
+		{"staging", map[string]any{"ref": "staging", "sha": "b2"}, "staging"},
+		{"release", map[string]any{"ref": "release-24.11", "sha": "b3"}, "release-24.11"},
+		{"missing", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body := map[string]any{"number": 5, "state": "open"}
+				if tt.base != nil {
+					body["base"] = tt.base
+				}
+				json.NewEncoder(w).Encode(body)
+			})
+
+			pr, err := c.GetPR(context.Background(), 5)
+			if err != nil {
+				t.Fatalf("GetPR: %v", err)
+			}
+			if pr.BaseRef != tt.want {
+				t.Errorf("BaseRef = %q, want %q", pr.BaseRef, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPRBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body any
+		want string
+	}{
+		{"text", "Fixes #1.\r\n\r\nChangelog: https://example.com", "Fixes #1.\r\n\r\nChangelog: https://example.com"},
+		{"null", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]any{"number": 5, "state": "open", "body": tt.body})
+			})
+
+			pr, err := c.GetPR(context.Background(), 5)
+			if err != nil {
+				t.Fatalf("GetPR: %v", err)
+			}
+			if pr.Body != tt.want {
+				t.Errorf("Body = %q, want %q", pr.Body, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetPROpen(t *testing.T) {
 	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{
@@ -100,6 +196,59 @@ func TestGetPRWithToken(t *testing.T) {
 	}
 }
 
+func TestRedirectSameHostKeepsToken(t *testing.T) {
+	var gotAuth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/NixOS/nixpkgs/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/repositories/4542716/pulls/1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/repositories/4542716/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 1,
+			"user":   map[string]any{"login": "x"},
+			"state":  "open",
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := New("ghp_secret")
+	c.BaseURL = srv.URL
+
+	pr, err := c.GetPR(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Number != 1 {
+		t.Errorf("Number = %d, want 1", pr.Number)
+	}
+	if gotAuth != "Bearer ghp_secret" {
+		t.Errorf("Authorization after redirect = %q, want %q", gotAuth, "Bearer ghp_secret")
+	}
+}
+
+func TestRedirectCrossHostDropsToken(t *testing.T) {
+	gotAuth := "unset"
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	}))
+	defer other.Close()
+
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+r.URL.Path, http.StatusFound)
+	})
+	c.token = "ghp_secret"
+
+	if _, err := c.IsCommitInBranch(context.Background(), "abc123", "nixos-unstable"); err != nil {
+		t.Fatalf("IsCommitInBranch: %v", err)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization sent to other host = %q, want empty", gotAuth)
+	}
+}
+
 func TestGetPRWithoutToken(t *testing.T) {
 	var gotAuth string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -124,6 +273,30 @@ func TestGetPRWithoutToken(t *testing.T) {
 	}
 }
 
+func TestRateLimit(t *testing.T) {
+	remaining := ""
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if remaining != "" {
+			w.Header().Set("X-RateLimit-Remaining", remaining)
+			w.Header().Set("X-RateLimit-Reset", "1714564800")
+		}
+		json.NewEncoder(w).Encode(map[string]any{"number": 1, "state": "open"})
+	})
+
+	if _, ok := c.RateLimit(); ok {
+		t.Error("RateLimit() ok before any response")
+	}
+	remaining = "4321"
+	c.GetPR(context.Background(), 1)
+	// A response without the headers keeps the last values.
+	remaining = ""
+	c.GetPR(context.Background(), 1)
+	rl, ok := c.RateLimit()
+	if !ok || rl.Remaining != 4321 || !rl.Reset.Equal(time.Unix(1714564800, 0)) {
+		t.Errorf("RateLimit() = %+v, %v, want 4321 remaining until 1714564800", rl, ok)
+	}
+}
+
 func TestGetPR404(t *testing.T) {
 	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -133,6 +306,9 @@ func TestGetPR404(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for 404")
 	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want it to wrap ErrNotFound", err)
+	}
 }
 
 func TestGetPRInvalidJSON(t *testing.T) {
@@ -160,6 +336,23 @@ func TestIsCommitInBranchBehind(t *testing.T) {
 	}
 }
 
+func TestCompareStatus(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "diverged"})
+	})
+
+	status, err := c.CompareStatus(context.Background(), "abc123", "nixos-unstable")
+	if err != nil {
+		t.Fatalf("CompareStatus: %v", err)
+	}
+	if status != "diverged" {
+		t.Errorf("status = %q, want %q", status, "diverged")
+	}
+	if c.IsLandedStatus(status) {
+		t.Error("IsLandedStatus(diverged) = true, want false")
+	}
+}
+
 func TestIsCommitInBranchIdentical(t *testing.T) {
 	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{"status": "identical"})
@@ -202,6 +395,361 @@ func TestIsCommitInBranchDiverged(t *testing.T) {
 	}
 }
 
+func TestWithLandedStatuses(t *testing.T) {
+	tests := []struct {
+		status   string
+		statuses []string
+		want     bool
+	}{
+		{"diverged", nil, false},
+		{"behind", nil, true},
+		{"diverged", []string{"behind", "identical", "diverged"}, true},
+		{"behind", []string{"behind", "identical", "diverged"}, true},
+		{"ahead", []string{"behind", "identical", "diverged"}, false},
+		{"behind", []string{"identical"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s in %v", tt.status, tt.statuses), func(t *testing.T) {
+			_, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]any{"status": tt.status})
+			})
+			var opts []Option
+			if tt.statuses != nil {
+				opts = append(opts, WithLandedStatuses(tt.statuses))
+			}
+			c := New("", opts...)
+			c.BaseURL = srv.URL
+
+			in, err := c.IsCommitInBranch(context.Background(), "abc123", "nixos-unstable")
+			if err != nil {
+				t.Fatalf("IsCommitInBranch: %v", err)
+			}
+			if in != tt.want {
+				t.Errorf("IsCommitInBranch = %v, want %v", in, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCommitInBranchMinimalQuery(t *testing.T) {
+	var query url.Values
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "behind", "behind_by": 3, "total_commits": 0,
+			"commits": []any{}, "files": []any{},
+		})
+	})
+
+	in, err := c.IsCommitInBranch(context.Background(), "abc123", "nixos-unstable")
+	if err != nil {
+		t.Fatalf("IsCommitInBranch: %v", err)
+	}
+	if !in {
+		t.Error("expected true for 'behind' status")
+	}
+	if got := query.Get("per_page"); got != "1" {
+		t.Errorf("per_page = %q, want %q", got, "1")
+	}
+}
+
+func TestChannelRevision(t *testing.T) {
+	var auth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nixos-unstable/git-revision", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprintln(w, "0123abcd")
+	})
+	mux.HandleFunc("/", http.NotFound)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	c := New("secret")
+	rev, err := c.ChannelRevision(context.Background(), srv.URL+"/nixos-unstable/git-revision")
+	if err != nil {
+		t.Fatalf("ChannelRevision: %v", err)
+	}
+	if rev != "0123abcd" {
+		t.Errorf("revision = %q, want %q", rev, "0123abcd")
+	}
+	if auth != "" {
+		t.Errorf("Authorization = %q, want the token withheld", auth)
+	}
+
+	if _, err := c.ChannelRevision(context.Background(), srv.URL+"/staging/git-revision"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing channel: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTruncatedResponseIsTransient(t *testing.T) {
+	// Promise more than is sent, so the server closes the connection
+	// mid-body.
+	truncated := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "200")
+			io.WriteString(w, body)
+		}
+	}
+
+	c, _ := newTestClient(t, truncated(`{"number": 5, "title": "Half a`))
+	_, err := c.GetPR(context.Background(), 5)
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("GetPR: err = %v, want ErrTransient", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "PR 5") {
+		t.Errorf("GetPR: err = %q, want it to name the PR", err)
+	}
+
+	c, _ = newTestClient(t, truncated(`{"status": "beh`))
+	_, err = c.IsCommitInBranch(context.Background(), "abc123", "nixos-unstable")
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("IsCommitInBranch: err = %v, want ErrTransient", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "nixos-unstable") {
+		t.Errorf("IsCommitInBranch: err = %q, want it to name the branch", err)
+	}
+
+	// Malformed but complete JSON is not transient.
+	c, _ = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"number": "five"}`)
+	})
+	if _, err := c.GetPR(context.Background(), 5); err == nil || errors.Is(err, ErrTransient) {
+		t.Errorf("GetPR with bad JSON: err = %v, want a non-transient error", err)
+	}
+}
+
+func TestWithRepo(t *testing.T) {
+	var paths []string
+	c, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch {
+		case strings.Contains(r.URL.Path, "/pulls/"):
+			json.NewEncoder(w).Encode(map[string]any{"number": 7, "state": "open"})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+		}
+	})
+	c = New("", WithRepo("example/nixpkgs-fork"))
+	c.BaseURL = srv.URL
+
+	if c.Repo() != "example/nixpkgs-fork" {
+		t.Errorf("Repo() = %q", c.Repo())
+	}
+	if _, err := c.GetPR(context.Background(), 7); err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if _, err := c.IsCommitInBranch(context.Background(), "abc123", "main"); err != nil {
+		t.Fatalf("IsCommitInBranch: %v", err)
+	}
+	want := []string{"/repos/example/nixpkgs-fork/pulls/7", "/repos/example/nixpkgs-fork/compare/main...abc123"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if New("").Repo() != DefaultRepo {
+		t.Errorf("default Repo() = %q, want %q", New("").Repo(), DefaultRepo)
+	}
+}
+
+func TestForRepo(t *testing.T) {
+	var paths []string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		json.NewEncoder(w).Encode(map[string]any{"number": 7, "state": "open"})
+	})
+	fork := c.ForRepo("example/nixpkgs-fork")
+
+	if _, err := fork.GetPR(context.Background(), 7); err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if _, err := c.GetPR(context.Background(), 7); err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	want := []string{"/repos/example/nixpkgs-fork/pulls/7", "/repos/NixOS/nixpkgs/pulls/7"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if fork.Repo() != "example/nixpkgs-fork" || c.Repo() != DefaultRepo {
+		t.Errorf("Repo() = %q and %q, want the fork and the default", fork.Repo(), c.Repo())
+	}
+	// Both spend the same token, so they share the rate limit.
+	c.rate.mu.Lock()
+	c.rate.last.Remaining = 99
+	c.rate.mu.Unlock()
+	if rl, _ := fork.RateLimit(); rl.Remaining != 99 {
+		t.Errorf("fork RateLimit().Remaining = %d, want the shared 99", rl.Remaining)
+	}
+}
+
+func TestAPIVersionHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"default", nil, DefaultAPIVersion},
+		{"configured", []Option{WithAPIVersion("2026-03-10")}, "2026-03-10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			_, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("X-GitHub-Api-Version")
+				json.NewEncoder(w).Encode(map[string]any{"number": 1, "state": "open"})
+			})
+			c := New("", tt.opts...)
+			c.BaseURL = srv.URL
+
+			if _, err := c.GetPR(context.Background(), 1); err != nil {
+				t.Fatalf("GetPR: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("X-GitHub-Api-Version = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIsCommitInBranchDirection pins the compare direction (base=branch,
+// head=sha) together with how each status maps to landed, using the shape of
+// GitHub's responses for that direction.
+func TestIsCommitInBranchDirection(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		aheadBy  int
+		behindBy int
+		commits  int
+		want     bool
+	}{
+		{"landed long ago", "behind", 0, 5000, 0, true},
+		{"branch at sha", "identical", 0, 0, 0, true},
+		{"not yet landed", "ahead", 12, 0, 12, false},
+		{"on another line", "diverged", 3, 40, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				json.NewEncoder(w).Encode(map[string]any{
+					"status":    tt.status,
+					"ahead_by":  tt.aheadBy,
+					"behind_by": tt.behindBy,
+					"commits":   make([]map[string]any, tt.commits),
+					"files":     []map[string]any{},
+				})
+			})
+
+			in, err := c.IsCommitInBranch(context.Background(), "abc123", "nixos-unstable")
+			if err != nil {
+				t.Fatalf("IsCommitInBranch: %v", err)
+			}
+			if in != tt.want {
+				t.Errorf("IsCommitInBranch = %v, want %v", in, tt.want)
+			}
+			if want := "/repos/NixOS/nixpkgs/compare/nixos-unstable...abc123"; path != want {
+				t.Errorf("path = %q, want base=branch head=sha %q", path, want)
+			}
+		})
+	}
+}
+
+func TestIsCommitInBranchFullRef(t *testing.T) {
+	var escapedPath, path string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		escapedPath = r.URL.EscapedPath()
+		path = r.URL.Path
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	in, err := c.IsCommitInBranch(context.Background(), "abc123", "refs/heads/staging-next")
+	if err != nil {
+		t.Fatalf("IsCommitInBranch: %v", err)
+	}
+	if !in {
+		t.Error("expected true for 'behind' status")
+	}
+	if want := "/repos/NixOS/nixpkgs/compare/refs%2Fheads%2Fstaging-next...abc123"; escapedPath != want {
+		t.Errorf("escaped path = %q, want %q", escapedPath, want)
+	}
+	if want := "/repos/NixOS/nixpkgs/compare/refs/heads/staging-next...abc123"; path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+}
+
+func TestIsCommitInBranchEscaping(t *testing.T) {
+	tests := []struct {
+		name   string
+		branch string
+		sha    string
+	}{
+		{"hash", "fix#1", "abc123"},
+		{"query", "what?x=1", "abc123"},
+		{"percent", "100%", "abc123"},
+		{"space", "my branch", "abc123"},
+		{"sha with slash", "nixos-unstable", "abc/../def"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, rawQuery string
+			c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				rawQuery = r.URL.RawQuery
+				json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+			})
+
+			if _, err := c.IsCommitInBranch(context.Background(), tt.sha, tt.branch); err != nil {
+				t.Fatalf("IsCommitInBranch: %v", err)
+			}
+			if want := "/repos/NixOS/nixpkgs/compare/" + tt.branch + "..." + tt.sha; path != want {
+				t.Errorf("path = %q, want %q", path, want)
+			}
+			if rawQuery != "per_page=1" {
+				t.Errorf("query = %q, want only per_page=1", rawQuery)
+			}
+		})
+	}
+}
+
+func TestInvalidInputsRejected(t *testing.T) {
+	var calls int
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	})
+	ctx := context.Background()
+
+	if _, err := c.GetPR(ctx, 0); err == nil {
+		t.Error("GetPR(0): expected error")
+	}
+	if _, err := c.GetPR(ctx, -5); err == nil {
+		t.Error("GetPR(-5): expected error")
+	}
+	if _, err := c.IsCommitInBranch(ctx, "", "nixos-unstable"); err == nil {
+		t.Error("IsCommitInBranch with empty sha: expected error")
+	}
+	if _, err := c.IsCommitInBranch(ctx, "abc123", ""); err == nil {
+		t.Error("IsCommitInBranch with empty branch: expected error")
+	}
+	if _, err := c.IsPRInBranchHistory(ctx, 1, "title", time.Time{}, ""); err == nil {
+		t.Error("IsPRInBranchHistory with empty branch: expected error")
+	}
+	if calls != 0 {
+		t.Errorf("server received %d requests, want 0", calls)
+	}
+}
+
+func TestIsCommitInBranchNotFound(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	_, err := c.IsCommitInBranch(context.Background(), "abc123", "nixos-unstabel")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("error = %v, want ErrNotFound", err)
+	}
+}
+
 func TestIsCommitInBranchHTTPError(t *testing.T) {
 	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -283,3 +831,429 @@ func TestNonRateLimited403(t *testing.T) {
 		t.Fatal("expected regular error, not RateLimitError, for 403 without rate limit headers")
 	}
 }
+
+func TestWithProxyRoutesThroughProxy(t *testing.T) {
+	var proxiedHost, proxiedPath string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute request URI.
+		proxiedHost = r.URL.Host
+		proxiedPath = r.URL.Path
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 7,
+			"user":   map[string]any{"login": "x"},
+			"state":  "open",
+		})
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	c := New("", WithProxy(proxyURL))
+	c.BaseURL = "http://api.github.invalid"
+
+	if _, err := c.GetPR(context.Background(), 7); err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if proxiedHost != "api.github.invalid" {
+		t.Errorf("proxied host = %q, want %q", proxiedHost, "api.github.invalid")
+	}
+	if proxiedPath != "/repos/NixOS/nixpkgs/pulls/7" {
+		t.Errorf("proxied path = %q, want %q", proxiedPath, "/repos/NixOS/nixpkgs/pulls/7")
+	}
+}
+
+func TestIsPRInBranchHistory(t *testing.T) {
+	mergedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var gotSHA, gotSince string
+	var pages atomic.Int32
+	var srvURL string
+	c, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		pages.Add(1)
+		gotSHA = r.URL.Query().Get("sha")
+		gotSince = r.URL.Query().Get("since")
+		if r.URL.Query().Get("page") != "2" {
+			w.Header().Set("Link", `<`+srvURL+r.URL.Path+`?`+r.URL.RawQuery+`&page=2>; rel="next"`)
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"commit": map[string]any{"message": "revert (#123) of something\n\nbody", "committer": map[string]any{"date": "2025-03-01T12:30:00Z"}}},
+				{"commit": map[string]any{"message": "world: init at 2.0", "committer": map[string]any{"date": "2025-03-01T13:00:00Z"}}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"commit": map[string]any{"message": "hello: 1.0 -> 1.1 (#123)\n\nbody", "committer": map[string]any{"date": "2025-03-01T12:00:00Z"}}},
+			{"commit": map[string]any{"message": "foo: 1.0 -> 2.0", "committer": map[string]any{"date": "2025-03-01T12:00:20Z"}}},
+		})
+	})
+	srvURL = srv.URL
+
+	tests := []struct {
+		name      string
+		prNumber  int
+		title     string
+		mergedAt  time.Time
+		want      bool
+		wantPages int32
+	}{
+		{"matches PR number suffix on a later page", 123, "something else", mergedAt, true, 2},
+		{"PR number mid-subject does not match", 124, "", mergedAt, false, 2},
+		{"matches title at merge time", 999, "foo: 1.0 -> 2.0", mergedAt, true, 2},
+		{"title committed at another time does not match", 999, "world: init at 2.0", mergedAt, false, 2},
+		{"title needs a merge time", 999, "foo: 1.0 -> 2.0", time.Time{}, false, 2},
+		{"empty title does not match everything", 456, "", mergedAt, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages.Store(0)
+			got, err := c.IsPRInBranchHistory(context.Background(), tt.prNumber, tt.title, tt.mergedAt, "nixos-unstable")
+			if err != nil {
+				t.Fatalf("IsPRInBranchHistory: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsPRInBranchHistory = %v, want %v", got, tt.want)
+			}
+			if n := pages.Load(); n != tt.wantPages {
+				t.Errorf("fetched %d pages, want %d", n, tt.wantPages)
+			}
+		})
+	}
+	if gotSHA != "nixos-unstable" {
+		t.Errorf("sha query = %q, want %q", gotSHA, "nixos-unstable")
+	}
+
+	// The search starts just before the merge.
+	pages.Store(0)
+	if _, err := c.IsPRInBranchHistory(context.Background(), 123, "", mergedAt, "nixos-unstable"); err != nil {
+		t.Fatalf("IsPRInBranchHistory: %v", err)
+	}
+	if want := "2025-03-01T11:59:00Z"; gotSince != want {
+		t.Errorf("since query = %q, want %q", gotSince, want)
+	}
+	if _, err := c.IsPRInBranchHistory(context.Background(), 123, "", time.Time{}, "nixos-unstable"); err != nil {
+		t.Fatalf("IsPRInBranchHistory: %v", err)
+	}
+	if gotSince != "" {
+		t.Errorf("since query without a merge time = %q, want none", gotSince)
+	}
+}
+
+func TestCommentOnPR(t *testing.T) {
+	var method, path, contentType string
+	var got map[string]string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		method, path, contentType = r.Method, r.URL.Path, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"id": 1})
+	})
+
+	if err := c.CommentOnPR(context.Background(), 42, "Landed in nixos-unstable as of 2024-05-01."); err != nil {
+		t.Fatalf("CommentOnPR: %v", err)
+	}
+	if method != http.MethodPost || path != "/repos/NixOS/nixpkgs/issues/42/comments" {
+		t.Errorf("request = %s %s, want POST /repos/NixOS/nixpkgs/issues/42/comments", method, path)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if got["body"] != "Landed in nixos-unstable as of 2024-05-01." {
+		t.Errorf("body = %q", got["body"])
+	}
+}
+
+func TestCommentOnPRForbidden(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	if err := c.CommentOnPR(context.Background(), 42, "hi"); err == nil {
+		t.Fatal("CommentOnPR: want an error for 403")
+	}
+}
+
+func TestGetPRForCommit(t *testing.T) {
+	var path string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if strings.Contains(path, "/commits/unknown/") {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		if strings.Contains(path, "/commits/orphan/") {
+			w.Write([]byte("[]"))
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"number": 42, "title": "foo: 1.0 -> 1.1", "state": "closed", "merge_commit_sha": "abc123", "user": map[string]any{"login": "alice"}},
+		})
+	})
+
+	info, err := c.GetPRForCommit(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetPRForCommit: %v", err)
+	}
+	if path != "/repos/NixOS/nixpkgs/commits/abc123/pulls" {
+		t.Errorf("path = %q", path)
+	}
+	if info.Number != 42 || info.Title != "foo: 1.0 -> 1.1" || info.Author != "alice" {
+		t.Errorf("info = %+v", info)
+	}
+
+	for _, sha := range []string{"unknown", "orphan"} {
+		if _, err := c.GetPRForCommit(context.Background(), sha); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: err = %v, want it to wrap ErrNotFound", sha, err)
+		}
+	}
+}
+
+func TestGetPRForCommitPrefersMerged(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"number": 41, "title": "foo: 1.1 (abandoned)", "state": "closed", "merged_at": nil},
+			{"number": 42, "title": "foo: 1.0 -> 1.1", "state": "closed", "merged_at": "2025-01-02T03:04:05Z", "merge_commit_sha": "abc123"},
+			{"number": 43, "title": "[24.11] foo: 1.0 -> 1.1", "state": "open", "merged_at": nil},
+		})
+	})
+
+	info, err := c.GetPRForCommit(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("GetPRForCommit: %v", err)
+	}
+	if info.Number != 42 || !info.Merged || info.MergeCommit != "abc123" {
+		t.Errorf("info = %+v, want merged PR 42", info)
+	}
+}
+
+func TestGetPRFiles(t *testing.T) {
+	var path string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"filename": "pkgs/by-name/fo/foo/package.nix", "status": "modified", "additions": 2, "deletions": 1},
+			{"filename": "nixos/tests/foo.nix", "status": "added", "additions": 30, "deletions": 0},
+		})
+	})
+
+	files, err := c.GetPRFiles(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetPRFiles: %v", err)
+	}
+	if path != "/repos/NixOS/nixpkgs/pulls/42/files" {
+		t.Errorf("path = %q", path)
+	}
+	if strings.Join(files, ",") != "pkgs/by-name/fo/foo/package.nix,nixos/tests/foo.nix" {
+		t.Errorf("files = %q", files)
+	}
+}
+
+func TestGetPRFilesPaginated(t *testing.T) {
+	var pages []string
+	var srv *httptest.Server
+	c, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		n, _ := strconv.Atoi(page)
+		if n == 0 {
+			n = 1
+		}
+		// Fifteen pages: more than other listings follow, as a treewide
+		// PR's files can be.
+		if n < 15 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?per_page=100&page=%d>; rel="next", <%s%s?per_page=100&page=15>; rel="last"`,
+				srv.URL, r.URL.Path, n+1, srv.URL, r.URL.Path))
+		}
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"filename": fmt.Sprintf("pkgs/by-name/p%d/a.nix", n)},
+			{"filename": fmt.Sprintf("pkgs/by-name/p%d/b.nix", n)},
+		})
+	})
+
+	files, err := c.GetPRFiles(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetPRFiles: %v", err)
+	}
+	if len(pages) != 15 {
+		t.Errorf("fetched %d pages, want 15", len(pages))
+	}
+	if len(files) != 30 {
+		t.Fatalf("got %d files, want 30", len(files))
+	}
+	if files[0] != "pkgs/by-name/p1/a.nix" || files[29] != "pkgs/by-name/p15/b.nix" {
+		t.Errorf("files = %q, want p1/a.nix first and p15/b.nix last", files)
+	}
+}
+
+func TestGetCheckRuns(t *testing.T) {
+	var path string
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewEncoder(w).Encode(map[string]any{
+			"total_count": 3,
+			"check_runs": []map[string]any{
+				{"name": "eval", "status": "completed", "conclusion": "success"},
+				{"name": "lint", "status": "completed", "conclusion": "failure"},
+				{"name": "build", "status": "in_progress", "conclusion": nil},
+			},
+		})
+	})
+
+	runs, err := c.GetCheckRuns(context.Background(), "head123")
+	if err != nil {
+		t.Fatalf("GetCheckRuns: %v", err)
+	}
+	if path != "/repos/NixOS/nixpkgs/commits/head123/check-runs" {
+		t.Errorf("path = %q", path)
+	}
+	want := []CheckRun{
+		{Name: "eval", Status: "completed", Conclusion: "success"},
+		{Name: "lint", Status: "completed", Conclusion: "failure"},
+		{Name: "build", Status: "in_progress", Conclusion: ""},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("len(runs) = %d, want %d", len(runs), len(want))
+	}
+	for i := range want {
+		if runs[i] != want[i] {
+			t.Errorf("runs[%d] = %+v, want %+v", i, runs[i], want[i])
+		}
+	}
+}
+
+func TestGetCheckRunsPaginated(t *testing.T) {
+	var pages []string
+	var srv *httptest.Server
+	c, srv := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		switch page {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?per_page=100&page=2>; rel="next", <%s%s?per_page=100&page=2>; rel="last"`,
+				srv.URL, r.URL.Path, srv.URL, r.URL.Path))
+			json.NewEncoder(w).Encode(map[string]any{"check_runs": []map[string]any{
+				{"name": "eval", "status": "completed", "conclusion": "success"},
+			}})
+		case "2":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?per_page=100&page=1>; rel="first", <%s%s?per_page=100&page=1>; rel="prev"`,
+				srv.URL, r.URL.Path, srv.URL, r.URL.Path))
+			json.NewEncoder(w).Encode(map[string]any{"check_runs": []map[string]any{
+				{"name": "build", "status": "completed", "conclusion": "failure"},
+			}})
+		default:
+			t.Errorf("unexpected page %q", page)
+		}
+	})
+
+	runs, err := c.GetCheckRuns(context.Background(), "head123")
+	if err != nil {
+		t.Fatalf("GetCheckRuns: %v", err)
+	}
+	if len(pages) != 2 {
+		t.Errorf("fetched pages %q, want 2 pages", pages)
+	}
+	if len(runs) != 2 || runs[0].Name != "eval" || runs[1].Name != "build" {
+		t.Errorf("runs = %+v, want eval then build", runs)
+	}
+}
+
+func TestGetCheckRunsRejectsCrossHostNextLink(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<https://evil.example.com/steal?page=2>; rel="next"`)
+		json.NewEncoder(w).Encode(map[string]any{"check_runs": []map[string]any{}})
+	})
+
+	if _, err := c.GetCheckRuns(context.Background(), "head123"); err == nil {
+		t.Fatal("expected error for a next link on another host")
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"none", "", ""},
+		{"next and last", `<https://api.github.com/x?page=2>; rel="next", <https://api.github.com/x?page=5>; rel="last"`, "https://api.github.com/x?page=2"},
+		{"next not first", `<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next"`, "https://api.github.com/x?page=3"},
+		{"last page", `<https://api.github.com/x?page=1>; rel="first", <https://api.github.com/x?page=4>; rel="prev"`, ""},
+		{"malformed", `https://api.github.com/x?page=2; rel="next"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.link != "" {
+				h.Set("Link", tt.link)
+			}
+			if got := nextPageURL(h); got != tt.want {
+				t.Errorf("nextPageURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllChecksPassed(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []CheckRun
+		want bool
+	}{
+		{"none", nil, false},
+		{"all success", []CheckRun{{Status: "completed", Conclusion: "success"}, {Status: "completed", Conclusion: "skipped"}}, true},
+		{"neutral", []CheckRun{{Status: "completed", Conclusion: "neutral"}}, true},
+		{"one failure", []CheckRun{{Status: "completed", Conclusion: "success"}, {Status: "completed", Conclusion: "failure"}}, false},
+		{"pending", []CheckRun{{Status: "completed", Conclusion: "success"}, {Status: "queued"}}, false},
+		{"cancelled", []CheckRun{{Status: "completed", Conclusion: "cancelled"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AllChecksPassed(tt.runs); got != tt.want {
+				t.Errorf("AllChecksPassed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBranchExists(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/NixOS/nixpkgs/branches/nixos-unstable", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"name": "nixos-unstable"})
+	})
+	mux.HandleFunc("/repos/NixOS/nixpkgs/git/ref/tags/24.11", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"ref": "refs/tags/24.11"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := New("")
+	c.BaseURL = srv.URL
+
+	tests := []struct {
+		branch string
+		want   bool
+	}{
+		{"nixos-unstable", true},
+		{"nixos-unstabel", false},
+		{"refs/tags/24.11", true},
+		{"refs/heads/nope", false},
+	}
+	for _, tt := range tests {
+		got, err := c.BranchExists(context.Background(), tt.branch)
+		if err != nil {
+			t.Errorf("BranchExists(%q): %v", tt.branch, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("BranchExists(%q) = %v, want %v", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestBranchExistsHTTPError(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	if _, err := c.BranchExists(context.Background(), "master"); err == nil {
+		t.Fatal("expected error for 500")
+	}
+}
@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+// Desktop shows native desktop notifications by shelling out to notify-send
+// (Linux/BSD) or osascript (macOS).
+type Desktop struct {
+	goos     string
+	instance string
+	run      func(ctx context.Context, name string, args ...string) error
+
+	mu      sync.Mutex
+	missing bool // the notification tool isn't installed; already reported
+}
+
+// NewDesktop creates a desktop notifier. A non-empty instance is prefixed to
+// every notification title, e.g. "[laptop] PR #42 merged".
+func NewDesktop(instance string) *Desktop {
+	return &Desktop{
+		goos:     runtime.GOOS,
+		instance: instance,
+		run: func(ctx context.Context, name string, args ...string) error {
+			return exec.CommandContext(ctx, name, args...).Run()
+		},
+	}
+}
+
+func (d *Desktop) Name() string {
+	return "desktop"
+}
+
+func (d *Desktop) Notify(ctx context.Context, e event.Event) error {
+	d.mu.Lock()
+	missing := d.missing
+	d.mu.Unlock()
+	if missing {
+		return nil
+	}
+
+	title, body := describe(e)
+	if e.Repo != "" {
+		title = e.Repo + ": " + title
+	}
+	if d.instance != "" {
+		title = "[" + d.instance + "] " + title
+	}
+	var name string
+	var args []string
+	if d.goos == "darwin" {
+		name = "osascript"
+		args = []string{"-e", fmt.Sprintf("display notification %s with title %s", strconv.Quote(body), strconv.Quote(title))}
+	} else {
+		name = "notify-send"
+		args = []string{"--app-name=nixpkgs-pr-tracker", title, body}
+	}
+
+	if err := d.run(ctx, name, args...); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			// Report once; every later event would fail the same way.
+			d.mu.Lock()
+			d.missing = true
+			d.mu.Unlock()
+			return fmt.Errorf("%s not found in PATH, desktop notifications disabled", name)
+		}
+		return fmt.Errorf("running %s: %w", name, err)
+	}
+	return nil
+}
+
+// describe renders a short human-readable title and body for an event.
+func describe(e event.Event) (title, body string) {
+	body = e.Title
+	switch e.Type {
+	case event.PRAdded:
+		title = fmt.Sprintf("Tracking PR #%d", e.PRNumber)
+	case event.PRMerged:
+		title = fmt.Sprintf("PR #%d merged", e.PRNumber)
+	case event.PRReopened:
+		title = fmt.Sprintf("PR #%d reopened", e.PRNumber)
+	case event.PRLandedBranch:
+		branch := e.Branch
+		if len(e.Branches) > 0 {
+			branch = strings.Join(e.Branches, ", ")
+		}
+		title = fmt.Sprintf("PR #%d landed in %s", e.PRNumber, branch)
+	case event.PRChecksPassed:
+		title = fmt.Sprintf("PR #%d checks passed", e.PRNumber)
+	case event.PRFullyLanded:
+		title = fmt.Sprintf("PR #%d landed in all %d branches", e.PRNumber, len(e.Branches))
+	case event.PRRemoved:
+		title = fmt.Sprintf("PR #%d removed", e.PRNumber)
+	case event.CommitLandedBranch:
+		title = fmt.Sprintf("Commit %s landed in %s", shortSHA(e.Commit), e.Branch)
+	case event.CommitRemoved:
+		title = fmt.Sprintf("Commit %s removed", shortSHA(e.Commit))
+	case event.PRError:
+		title = fmt.Sprintf("PR #%d keeps failing to poll", e.PRNumber)
+		body = e.Error
+	case event.RateLimited:
+		title = "GitHub rate limit reached"
+		body = "Polling paused until " + e.ResetAt.Local().Format("15:04")
+	case event.DailySummary:
+		title = "Daily summary"
+		if s := e.Summary; s != nil {
+			body = fmt.Sprintf("%d PRs landed, %d pending", len(s.Landed), len(s.Pending))
+			if s.OldestPending != 0 {
+				body += fmt.Sprintf("; #%d pending since %s", s.OldestPending, s.PendingSince.Local().Format("2006-01-02"))
+			}
+		}
+	case event.NotificationFailed:
+		title = fmt.Sprintf("%s notifications failing", e.Notifier)
+		body = e.Error
+	default:
+		title = string(e.Type)
+	}
+	return title, body
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,153 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+type fakeRunner struct {
+	err   error
+	calls [][]string
+}
+
+func (f *fakeRunner) run(ctx context.Context, name string, args ...string) error {
+	f.calls = append(f.calls, append([]string{name}, args...))
+	return f.err
+}
+
+func TestDesktopName(t *testing.T) {
+	if n := NewDesktop("").Name(); n != "desktop" {
+		t.Errorf("Name() = %q, want %q", n, "desktop")
+	}
+}
+
+func TestDesktopNotifyLinux(t *testing.T) {
+	runner := &fakeRunner{}
+	d := &Desktop{goos: "linux", run: runner.run}
+
+	err := d.Notify(context.Background(), event.Event{
+		Type:     event.PRLandedBranch,
+		PRNumber: 42,
+		Title:    "foo: 1.0 -> 2.0",
+		Branch:   "nixos-unstable",
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if len(runner.calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(runner.calls))
+	}
+	want := []string{"notify-send", "--app-name=nixpkgs-pr-tracker", "PR #42 landed in nixos-unstable", "foo: 1.0 -> 2.0"}
+	if strings.Join(runner.calls[0], "|") != strings.Join(want, "|") {
+		t.Errorf("command = %q, want %q", runner.calls[0], want)
+	}
+}
+
+func TestDesktopNotifyGroupedLanding(t *testing.T) {
+	runner := &fakeRunner{}
+	d := &Desktop{goos: "linux", run: runner.run}
+
+	err := d.Notify(context.Background(), event.Event{
+		Type:     event.PRLandedBranch,
+		PRNumber: 42,
+		Title:    "foo: 1.0 -> 2.0",
+		Branch:   "nixos-24.11",
+		Branches: []string{"nixos-unstable-small", "nixos-24.11"},
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(runner.calls) != 1 || runner.calls[0][2] != "PR #42 landed in nixos-unstable-small, nixos-24.11" {
+		t.Errorf("calls = %q, want a title listing both branches", runner.calls)
+	}
+}
+
+func TestDesktopNotifyDailySummary(t *testing.T) {
+	runner := &fakeRunner{}
+	d := &Desktop{goos: "linux", run: runner.run}
+
+	err := d.Notify(context.Background(), event.Event{
+		Type: event.DailySummary,
+		Summary: &event.Summary{
+			Landed:        []int{1, 4},
+			Pending:       []int{1, 2, 3},
+			OldestPending: 2,
+			PendingSince:  time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	want := []string{"notify-send", "--app-name=nixpkgs-pr-tracker", "Daily summary", "2 PRs landed, 3 pending; #2 pending since 2025-03-01"}
+	if len(runner.calls) != 1 || strings.Join(runner.calls[0], "|") != strings.Join(want, "|") {
+		t.Errorf("calls = %q, want %q", runner.calls, want)
+	}
+}
+
+func TestDesktopNotifyInstancePrefix(t *testing.T) {
+	runner := &fakeRunner{}
+	d := &Desktop{goos: "linux", instance: "work-laptop", run: runner.run}
+
+	if err := d.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 7, Title: "bar"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(runner.calls))
+	}
+	if got := runner.calls[0][2]; got != "[work-laptop] PR #7 merged" {
+		t.Errorf("title = %q, want %q", got, "[work-laptop] PR #7 merged")
+	}
+}
+
+func TestDesktopNotifyRepoPrefix(t *testing.T) {
+	runner := &fakeRunner{}
+	d := &Desktop{goos: "linux", instance: "work-laptop", run: runner.run}
+
+	if err := d.Notify(context.Background(), event.Event{Type: event.PRMerged, Repo: "owner/other", PRNumber: 7, Title: "bar"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got, want := runner.calls[0][2], "[work-laptop] owner/other: PR #7 merged"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+}
+
+func TestDesktopNotifyDarwin(t *testing.T) {
+	runner := &fakeRunner{}
+	d := &Desktop{goos: "darwin", run: runner.run}
+
+	if err := d.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 7, Title: `say "hi"`}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	call := runner.calls[0]
+	if call[0] != "osascript" || call[1] != "-e" {
+		t.Fatalf("command = %q, want osascript -e ...", call)
+	}
+	want := `display notification "say \"hi\"" with title "PR #7 merged"`
+	if call[2] != want {
+		t.Errorf("script = %s, want %s", call[2], want)
+	}
+}
+
+func TestDesktopMissingToolReportedOnce(t *testing.T) {
+	runner := &fakeRunner{err: fmt.Errorf("exec: %w", exec.ErrNotFound)}
+	d := &Desktop{goos: "linux", run: runner.run}
+
+	err := d.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1})
+	if err == nil || !strings.Contains(err.Error(), "notify-send not found") {
+		t.Fatalf("first Notify error = %v, want notify-send not found", err)
+	}
+	if err := d.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 2}); err != nil {
+		t.Errorf("second Notify error = %v, want nil (already reported)", err)
+	}
+	if len(runner.calls) != 1 {
+		t.Errorf("calls = %d, want 1 (tool should not be retried)", len(runner.calls))
+	}
+}
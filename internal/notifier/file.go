@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+// File appends every event as one JSON object per line (JSONL) to a local
+// file, using the same fields as the flat webhook payload.
+type File struct {
+	path     string
+	maxSize  int64
+	instance string
+
+	mu sync.Mutex // serializes writes and rotation
+}
+
+// FileOption configures optional File behavior.
+type FileOption func(*File)
+
+// WithMaxSize rotates the file once appending would grow it past n bytes:
+// the current file is renamed to path+".1", replacing any earlier one, and
+// a fresh file is started. Zero or less never rotates.
+func WithMaxSize(n int64) FileOption {
+	return func(f *File) {
+		f.maxSize = n
+	}
+}
+
+// WithFileInstance adds an "instance" field to every line.
+func WithFileInstance(name string) FileOption {
+	return func(f *File) {
+		f.instance = name
+	}
+}
+
+func NewFile(path string, opts ...FileOption) *File {
+	f := &File{path: path}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+func (f *File) Name() string {
+	return "file"
+}
+
+// Notify appends e to the file. The file is opened in append mode for each
+// event, so it may be moved away (e.g. by logrotate) between writes.
+func (f *File) Notify(ctx context.Context, e event.Event) error {
+	line, err := json.Marshal(flatPayload(e, f.instance))
+	if err != nil {
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 {
+		if fi, err := os.Stat(f.path); err == nil && fi.Size() > 0 && fi.Size()+int64(len(line)) > f.maxSize {
+			if err := os.Rename(f.path, f.path+".1"); err != nil {
+				return fmt.Errorf("rotating event file: %w", err)
+			}
+		}
+	}
+
+	out, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening event file: %w", err)
+	}
+	if _, err := out.Write(line); err != nil {
+		out.Close()
+		return fmt.Errorf("writing event file: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+func readJSONL(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return lines
+}
+
+func TestFileNotifyAppendsJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f := NewFile(path)
+	if f.Name() != "file" {
+		t.Errorf("Name() = %q, want %q", f.Name(), "file")
+	}
+
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []event.Event{
+		{Type: event.PRAdded, PRNumber: 1, Title: "one", Timestamp: ts},
+		{Type: event.PRMerged, PRNumber: 1, Title: "one", Timestamp: ts},
+		{Type: event.PRLandedBranch, PRNumber: 1, Title: "one", Branch: "nixos-unstable", Timestamp: ts},
+	}
+	for _, e := range events {
+		if err := f.Notify(context.Background(), e); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+
+	// A second notifier on the same path appends rather than truncates.
+	if err := NewFile(path).Notify(context.Background(), event.Event{Type: event.PRRemoved, PRNumber: 1, Timestamp: ts}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	lines := readJSONL(t, path)
+	want := []string{"pr_added", "pr_merged", "pr_landed_branch", "pr_removed"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for i, w := range want {
+		if lines[i]["event"] != w {
+			t.Errorf("line %d event = %v, want %s", i, lines[i]["event"], w)
+		}
+	}
+	if lines[2]["branch"] != "nixos-unstable" || lines[2]["timestamp"] != "2025-01-01T12:00:00Z" {
+		t.Errorf("line 2 = %v", lines[2])
+	}
+}
+
+func TestFileNotifyRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f := NewFile(path, WithMaxSize(300))
+
+	for i := 1; i <= 3; i++ {
+		if err := f.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: i}); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+
+	current := readJSONL(t, path)
+	rotated := readJSONL(t, path+".1")
+	if len(current) == 0 || len(rotated) == 0 || len(current)+len(rotated) != 3 {
+		t.Fatalf("current has %d lines, rotated %d; want 3 split across both", len(current), len(rotated))
+	}
+	if last := current[len(current)-1]["pr_number"]; last != float64(3) {
+		t.Errorf("last line pr_number = %v, want 3", last)
+	}
+	if fi, _ := os.Stat(path); fi.Size() > 300 {
+		t.Errorf("current file is %d bytes, want <= 300", fi.Size())
+	}
+}
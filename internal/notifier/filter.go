@@ -0,0 +1,82 @@
+package notifier
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+// Filter wraps a Notifier and only forwards events whose type is allowed.
+type Filter struct {
+	next    Notifier
+	allowed map[event.Type]bool
+}
+
+func NewFilter(next Notifier, allowed []event.Type) *Filter {
+	set := make(map[event.Type]bool, len(allowed))
+	for _, t := range allowed {
+		set[t] = true
+	}
+	return &Filter{next: next, allowed: set}
+}
+
+func (f *Filter) Name() string {
+	return f.next.Name()
+}
+
+func (f *Filter) Notify(ctx context.Context, e event.Event) error {
+	if !f.allowed[e.Type] {
+		return nil
+	}
+	return f.next.Notify(ctx, e)
+}
+
+func (f *Filter) Flush(ctx context.Context) error {
+	return flush(ctx, f.next)
+}
+
+func (f *Filter) OnFailure(fn func(err error)) {
+	onFailure(f.next, fn)
+}
+
+// AuthorFilter wraps a Notifier and drops events of the given types for PRs
+// by one author, e.g. to skip pings about PRs you opened yourself. Events
+// without an author always pass.
+type AuthorFilter struct {
+	next       Notifier
+	author     string
+	suppressed map[event.Type]bool
+}
+
+// NewAuthorFilter suppresses types (all types if empty) for PRs whose author
+// matches author, compared case-insensitively like GitHub logins.
+func NewAuthorFilter(next Notifier, author string, types []event.Type) *AuthorFilter {
+	if len(types) == 0 {
+		types = event.Types
+	}
+	set := make(map[event.Type]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return &AuthorFilter{next: next, author: author, suppressed: set}
+}
+
+func (f *AuthorFilter) Name() string {
+	return f.next.Name()
+}
+
+func (f *AuthorFilter) Notify(ctx context.Context, e event.Event) error {
+	if e.Author != "" && strings.EqualFold(e.Author, f.author) && f.suppressed[e.Type] {
+		return nil
+	}
+	return f.next.Notify(ctx, e)
+}
+
+func (f *AuthorFilter) Flush(ctx context.Context) error {
+	return flush(ctx, f.next)
+}
+
+func (f *AuthorFilter) OnFailure(fn func(err error)) {
+	onFailure(f.next, fn)
+}
@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+func TestFilterName(t *testing.T) {
+	f := NewFilter(NewWebhook("http://example.com"), event.Types)
+	if f.Name() != "webhook" {
+		t.Errorf("Name() = %q, want %q", f.Name(), "webhook")
+	}
+}
+
+func TestFilterDropsDisallowedTypes(t *testing.T) {
+	var mu sync.Mutex
+	var delivered []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]any
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		delivered = append(delivered, payload["event"].(string))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	// Everything except PRAdded, as when NPT_NOTIFY_ON_ADD=false.
+	var allowed []event.Type
+	for _, typ := range event.Types {
+		if typ != event.PRAdded {
+			allowed = append(allowed, typ)
+		}
+	}
+	f := NewFilter(NewWebhook(srv.URL), allowed)
+
+	for _, typ := range []event.Type{event.PRAdded, event.PRLandedBranch} {
+		if err := f.Notify(context.Background(), event.Event{Type: typ, PRNumber: 1}); err != nil {
+			t.Fatalf("Notify(%s): %v", typ, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 1 || delivered[0] != "pr_landed_branch" {
+		t.Errorf("delivered = %v, want [pr_landed_branch]", delivered)
+	}
+}
+
+// recorder is a Notifier that remembers what it was asked to deliver.
+type recorder struct {
+	got []event.Event
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Notify(ctx context.Context, e event.Event) error {
+	r.got = append(r.got, e)
+	return nil
+}
+
+func TestAuthorFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []event.Type
+		e     event.Event
+		want  bool
+	}{
+		{"suppressed type", []event.Type{event.PRAdded}, event.Event{Type: event.PRAdded, Author: "alice"}, false},
+		{"login case differs", []event.Type{event.PRAdded}, event.Event{Type: event.PRAdded, Author: "Alice"}, false},
+		{"other type", []event.Type{event.PRAdded}, event.Event{Type: event.PRFullyLanded, Author: "alice"}, true},
+		{"other author", []event.Type{event.PRAdded}, event.Event{Type: event.PRAdded, Author: "bob"}, true},
+		{"no author", nil, event.Event{Type: event.RateLimited}, true},
+		{"all types by default", nil, event.Event{Type: event.PRFullyLanded, Author: "alice"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			f := NewAuthorFilter(rec, "alice", tt.types)
+			if err := f.Notify(context.Background(), tt.e); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			if got := len(rec.got) == 1; got != tt.want {
+				t.Errorf("delivered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
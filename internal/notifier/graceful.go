@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+// ErrShuttingDown is returned for events that arrive after Shutdown.
+var ErrShuttingDown = errors.New("notifier is shutting down")
+
+// Graceful wraps a Notifier so that Shutdown can wait for in-flight
+// notifications instead of cutting them off when the process exits.
+type Graceful struct {
+	next Notifier
+
+	// abort is canceled when the grace period runs out, cancelling the
+	// context of every in-flight notification.
+	abort       context.Context
+	cancelAbort context.CancelFunc
+
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+func NewGraceful(next Notifier) *Graceful {
+	abort, cancel := context.WithCancel(context.Background())
+	return &Graceful{next: next, abort: abort, cancelAbort: cancel}
+}
+
+func (g *Graceful) Name() string {
+	return g.next.Name()
+}
+
+func (g *Graceful) Notify(ctx context.Context, e event.Event) error {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return ErrShuttingDown
+	}
+	g.inflight.Add(1)
+	g.mu.Unlock()
+	defer g.inflight.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(g.abort, cancel)
+	defer stop()
+
+	return g.next.Notify(ctx, e)
+}
+
+// OnFailure reports failures the notifier hits after Notify has returned to
+// fn (see FailureReporter).
+func (g *Graceful) OnFailure(fn func(err error)) {
+	onFailure(g.next, fn)
+}
+
+// Shutdown stops accepting events and waits for in-flight notifications to
+// finish, then flushes any events the notifier holds back (see Flusher). If
+// ctx ends first, their contexts are canceled and Shutdown returns ctx.Err()
+// once they have returned.
+func (g *Graceful) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		err := flush(ctx, g.next)
+		g.cancelAbort()
+		return err
+	case <-ctx.Done():
+		g.cancelAbort()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,95 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+// blockingNotifier signals started, then returns once release is closed or
+// its context ends.
+type blockingNotifier struct {
+	started chan struct{}
+	release chan struct{}
+	err     chan error
+}
+
+func newBlockingNotifier() *blockingNotifier {
+	return &blockingNotifier{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		err:     make(chan error, 1),
+	}
+}
+
+func (b *blockingNotifier) Name() string { return "blocking" }
+
+func (b *blockingNotifier) Notify(ctx context.Context, e event.Event) error {
+	close(b.started)
+	var err error
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	b.err <- err
+	return err
+}
+
+func TestGracefulShutdownWaitsForInflight(t *testing.T) {
+	b := newBlockingNotifier()
+	g := NewGraceful(b)
+
+	go g.Notify(context.Background(), event.Event{Type: event.PRMerged})
+	<-b.started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- g.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v before the in-flight notification finished", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(b.release)
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-b.err; err != nil {
+		t.Errorf("in-flight notification failed: %v, want it to complete", err)
+	}
+}
+
+func TestGracefulShutdownGracePeriodExpires(t *testing.T) {
+	b := newBlockingNotifier()
+	g := NewGraceful(b)
+
+	go g.Notify(context.Background(), event.Event{Type: event.PRMerged})
+	<-b.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+	if err := <-b.err; !errors.Is(err, context.Canceled) {
+		t.Errorf("in-flight notification error = %v, want context.Canceled", err)
+	}
+}
+
+func TestGracefulRejectsAfterShutdown(t *testing.T) {
+	g := NewGraceful(newBlockingNotifier())
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := g.Notify(context.Background(), event.Event{Type: event.PRAdded}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Notify after Shutdown = %v, want ErrShuttingDown", err)
+	}
+	if g.Name() != "blocking" {
+		t.Errorf("Name() = %q, want %q", g.Name(), "blocking")
+	}
+}
@@ -0,0 +1,225 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+// natsTimeout bounds connecting and each publish round trip when the context
+// has no earlier deadline.
+const natsTimeout = 10 * time.Second
+
+// NATS publishes every event as a JSON message, with the same fields as the
+// flat webhook payload, to a subject on a NATS server. It speaks the plain
+// text client protocol itself and follows each PUB with a PING, so a
+// publish only succeeds once the server has answered. A broken connection is
+// re-dialed once per event before giving up.
+type NATS struct {
+	url      string
+	subject  string
+	instance string
+
+	mu   sync.Mutex // serializes publishes and guards conn and r
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NATSOption configures optional NATS behavior.
+type NATSOption func(*NATS)
+
+// WithNATSInstance adds an "instance" field to every message.
+func WithNATSInstance(name string) NATSOption {
+	return func(n *NATS) {
+		n.instance = name
+	}
+}
+
+// NewNATS publishes to subject on the server at natsURL, e.g.
+// "nats://localhost:4222". Credentials in the URL are sent as user and
+// password, or as an auth token when there is no password. TLS is not
+// supported. Nothing is dialed until the first event.
+func NewNATS(natsURL, subject string, opts ...NATSOption) *NATS {
+	n := &NATS{url: natsURL, subject: subject}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *NATS) Name() string {
+	return "nats"
+}
+
+func (n *NATS) Notify(ctx context.Context, e event.Event) error {
+	payload, err := json.Marshal(flatPayload(e, n.instance))
+	if err != nil {
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn != nil {
+		err := n.publish(ctx, payload)
+		if err == nil {
+			return nil
+		}
+		n.close()
+		if ctx.Err() != nil {
+			return fmt.Errorf("publishing to NATS: %w", err)
+		}
+		// Reconnect and try once more below.
+	}
+	if err := n.connect(ctx); err != nil {
+		return fmt.Errorf("connecting to NATS: %w", err)
+	}
+	if err := n.publish(ctx, payload); err != nil {
+		n.close()
+		return fmt.Errorf("publishing to NATS: %w", err)
+	}
+	return nil
+}
+
+// Close drops the server connection, if any.
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.close()
+	return nil
+}
+
+func (n *NATS) close() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn, n.r = nil, nil
+	}
+}
+
+func (n *NATS) connect(ctx context.Context) error {
+	u, err := url.Parse(n.url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "nats" {
+		return fmt.Errorf("unsupported URL scheme %q, want nats://", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	var d net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, natsTimeout)
+	defer cancel()
+	conn, err := d.DialContext(dialCtx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	n.conn, n.r = conn, bufio.NewReader(conn)
+	n.setDeadline(ctx)
+
+	line, err := n.readLine()
+	if err != nil {
+		n.close()
+		return fmt.Errorf("reading server INFO: %w", err)
+	}
+	info, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		n.close()
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	var serverInfo struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(info), &serverInfo)
+	if serverInfo.TLSRequired {
+		n.close()
+		return errors.New("server requires TLS, which is not supported")
+	}
+
+	opts := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "nixpkgs-pr-tracker",
+		"lang":     "go",
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts["user"], opts["pass"] = u.User.Username(), pass
+		} else {
+			opts["auth_token"] = u.User.Username()
+		}
+	}
+	connect, err := json.Marshal(opts)
+	if err != nil {
+		n.close()
+		return err
+	}
+	if _, err := fmt.Fprintf(n.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		n.close()
+		return err
+	}
+	if err := n.awaitPong(); err != nil {
+		n.close()
+		return err
+	}
+	return nil
+}
+
+// publish sends payload and waits for the PONG to the PING that follows it.
+func (n *NATS) publish(ctx context.Context, payload []byte) error {
+	n.setDeadline(ctx)
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", n.subject, len(payload), payload)
+	if _, err := n.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return n.awaitPong()
+}
+
+// awaitPong reads until the server's PONG, answering its PINGs and failing
+// on -ERR.
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := n.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates need no reply.
+	}
+}
+
+func (n *NATS) readLine() (string, error) {
+	line, err := n.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (n *NATS) setDeadline(ctx context.Context) {
+	deadline := time.Now().Add(natsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	n.conn.SetDeadline(deadline)
+}
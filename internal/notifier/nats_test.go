@@ -0,0 +1,190 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+)
+
+type natsMsg struct {
+	subject string
+	data    []byte
+}
+
+// mockNATS is a minimal NATS server: it greets with INFO, answers PING and
+// reports every PUB on msgs.
+type mockNATS struct {
+	ln       net.Listener
+	msgs     chan natsMsg
+	connects chan map[string]any
+	accepted atomic.Int32
+	// dropAfterPub closes each connection right after acknowledging its
+	// first publish, as if the server restarted.
+	dropAfterPub atomic.Bool
+}
+
+func newMockNATS(t *testing.T) *mockNATS {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	m := &mockNATS{ln: ln, msgs: make(chan natsMsg, 10), connects: make(chan map[string]any, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			m.accepted.Add(1)
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+func (m *mockNATS) URL() string {
+	return "nats://" + m.ln.Addr().String()
+}
+
+func (m *mockNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"mock\"}\r\n")
+	published := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			var opts map[string]any
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &opts)
+			m.connects <- opts
+		case line == "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+			if published && m.dropAfterPub.Load() {
+				return
+			}
+		case strings.HasPrefix(line, "PUB "):
+			var subject string
+			var size int
+			fmt.Sscanf(line, "PUB %s %d", &subject, &size)
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			m.msgs <- natsMsg{subject: subject, data: data[:size]}
+			published = true
+		}
+	}
+}
+
+func (m *mockNATS) next(t *testing.T) natsMsg {
+	t.Helper()
+	select {
+	case msg := <-m.msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message published")
+		return natsMsg{}
+	}
+}
+
+func TestNATSPublishesToSubject(t *testing.T) {
+	srv := newMockNATS(t)
+	n := NewNATS(srv.URL(), "nixpkgs.events", WithNATSInstance("laptop"))
+	t.Cleanup(func() { n.Close() })
+
+	if n.Name() != "nats" {
+		t.Errorf("Name() = %q, want %q", n.Name(), "nats")
+	}
+
+	e := event.Event{Type: event.PRLandedBranch, PRNumber: 42, Title: "hello", Branch: "nixos-unstable", Timestamp: time.Now()}
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	msg := srv.next(t)
+	if msg.subject != "nixpkgs.events" {
+		t.Errorf("subject = %q, want %q", msg.subject, "nixpkgs.events")
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(msg.data, &payload); err != nil {
+		t.Fatalf("message is not JSON: %v", err)
+	}
+	if payload["event"] != "pr_landed_branch" || payload["pr_number"] != float64(42) || payload["branch"] != "nixos-unstable" || payload["instance"] != "laptop" {
+		t.Errorf("payload = %v", payload)
+	}
+}
+
+func TestNATSReconnects(t *testing.T) {
+	srv := newMockNATS(t)
+	srv.dropAfterPub.Store(true)
+	n := NewNATS(srv.URL(), "events")
+	t.Cleanup(func() { n.Close() })
+
+	for i := 1; i <= 2; i++ {
+		if err := n.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: i}); err != nil {
+			t.Fatalf("Notify %d: %v", i, err)
+		}
+		srv.next(t)
+	}
+	if got := srv.accepted.Load(); got != 2 {
+		t.Errorf("connections = %d, want 2 (one reconnect)", got)
+	}
+}
+
+func TestNATSCredentials(t *testing.T) {
+	srv := newMockNATS(t)
+	addr := srv.ln.Addr().String()
+
+	tests := []struct {
+		url  string
+		want map[string]string
+	}{
+		{"nats://alice:s3cret@" + addr, map[string]string{"user": "alice", "pass": "s3cret"}},
+		{"nats://tok3n@" + addr, map[string]string{"auth_token": "tok3n"}},
+	}
+	for _, tt := range tests {
+		n := NewNATS(tt.url, "events")
+		if err := n.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1}); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+		srv.next(t)
+		n.Close()
+
+		opts := <-srv.connects
+		for k, v := range tt.want {
+			if opts[k] != v {
+				t.Errorf("CONNECT %s = %v, want %q", k, opts[k], v)
+			}
+		}
+	}
+}
+
+func TestNATSUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	n := NewNATS("nats://"+addr, "events")
+	if err := n.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1}); err == nil {
+		t.Error("Notify to a closed port succeeded, want error")
+	}
+}
@@ -10,3 +10,32 @@ type Notifier interface {
 	Name() string
 	Notify(ctx context.Context, e event.Event) error
 }
+
+// Flusher is implemented by notifiers that hold events back, such as a
+// batching Webhook. Flush sends whatever is held.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// flush flushes n if it holds events back.
+func flush(ctx context.Context, n Notifier) error {
+	if f, ok := n.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// FailureReporter is implemented by notifiers that can fail after Notify
+// has returned, such as a batching Webhook whose timer sends the batch.
+// OnFailure sets the function those failures are reported to; without one
+// they are only logged.
+type FailureReporter interface {
+	OnFailure(fn func(err error))
+}
+
+// onFailure sets fn on n if it reports failures after Notify returns.
+func onFailure(n Notifier, fn func(err error)) {
+	if r, ok := n.(FailureReporter); ok {
+		r.OnFailure(fn)
+	}
+}
@@ -3,24 +3,184 @@ package notifier
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
 )
 
+// WebhookFormat selects the JSON shape of webhook request bodies.
+type WebhookFormat string
+
+const (
+	// FormatFlat is a flat object with the event fields at the top level.
+	FormatFlat WebhookFormat = "flat"
+	// FormatCloudEvents is a CloudEvents 1.0 structured-mode envelope with
+	// the event fields under "data".
+	FormatCloudEvents WebhookFormat = "cloudevents"
+)
+
+// cloudEventTypePrefix namespaces event types in CloudEvents envelopes,
+// e.g. "dev.nixpkgs-pr-tracker.pr_landed_branch".
+const cloudEventTypePrefix = "dev.nixpkgs-pr-tracker."
+
 type Webhook struct {
-	url    string
-	client *http.Client
+	url      string
+	proxyURL *url.URL
+	format   WebhookFormat
+	instance string
+	timeout  time.Duration
+	secret   string
+	retries  int
+	insecure bool
+	client   *http.Client
+
+	transport http.RoundTripper // replaces the default transport when set
+
+	// batchInterval, if set, holds events back and sends them together.
+	// batchMu guards batch, the payloads waiting, and batchTimer, which
+	// flushes them once batchInterval after the first arrived. A timer
+	// flush that fails is reported to batchFailed, if set by OnFailure.
+	batchInterval time.Duration
+	batchMu       sync.Mutex
+	batch         []any
+	batchTimer    *time.Timer
+	batchFailed   func(err error)
+}
+
+// retryDelay is the wait before the first webhook retry; it doubles for each
+// one after that.
+var retryDelay = time.Second
+
+// WebhookOption configures optional Webhook behavior.
+type WebhookOption func(*Webhook)
+
+// WithProxy routes webhook requests through proxyURL, overriding the proxy
+// derived from HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+func WithProxy(proxyURL *url.URL) WebhookOption {
+	return func(w *Webhook) {
+		w.proxyURL = proxyURL
+	}
+}
+
+// WithFormat sets the request body format. The default is FormatFlat.
+func WithFormat(format WebhookFormat) WebhookOption {
+	return func(w *Webhook) {
+		w.format = format
+	}
+}
+
+// WithInstance tags every payload with the name of this tracker instance:
+// an "instance" field in the flat format, and the "source" attribute in
+// CloudEvents.
+func WithInstance(name string) WebhookOption {
+	return func(w *Webhook) {
+		w.instance = name
+	}
+}
+
+// WithTimeout bounds each webhook request, including reading the response.
+// The default is 10s.
+func WithTimeout(d time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.timeout = d
+	}
+}
+
+// WithSecret signs every request so receivers can verify it came from this
+// tracker and reject replays. The X-Timestamp header carries the send time
+// in Unix seconds, and X-Signature is "sha256=" followed by the hex
+// HMAC-SHA256, keyed with secret, of the string
+//
+//	<X-Timestamp> + "." + <request body>
+func WithSecret(secret string) WebhookOption {
+	return func(w *Webhook) {
+		w.secret = secret
+	}
+}
+
+// WithRetries retries a failed request up to n more times, waiting 1s, 2s,
+// 4s, ... in between. Only connection errors, 429 and 5xx responses are
+// retried. The default is no retries.
+func WithRetries(n int) WebhookOption {
+	return func(w *Webhook) {
+		w.retries = n
+	}
+}
+
+// WithInsecureSkipVerify accepts any TLS certificate from the receiver, for
+// internal receivers with self-signed certificates. It leaves requests open
+// to interception, so only use it on networks you trust.
+func WithInsecureSkipVerify() WebhookOption {
+	return func(w *Webhook) {
+		w.insecure = true
+	}
+}
+
+// WithTransport sends every request through rt instead of a transport
+// built from the environment, e.g. to add tracing. WithProxy and
+// WithInsecureSkipVerify have no effect then; WithTimeout still applies.
+func WithTransport(rt http.RoundTripper) WebhookOption {
+	return func(w *Webhook) {
+		w.transport = rt
+	}
+}
+
+// WithBatch holds events back for up to interval after the first one and
+// then sends them all in one request, as {"events": [...]} with each element
+// the payload a single event would have had. The request is
+// application/json in both formats: a CloudEvents batch is this wrapper
+// around structured-mode envelopes, not an application/cloudevents-batch+json
+// array. Call Flush before exiting to send what is still held. Notify can't
+// report a failed batch, since the events it carries were already accepted;
+// see OnFailure.
+func WithBatch(interval time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		w.batchInterval = interval
+	}
+}
+
+// signature returns the X-Signature value for body sent at timestamp.
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
 }
 
-func NewWebhook(url string) *Webhook {
-	return &Webhook{
-		url:    url,
-		client: &http.Client{Timeout: 10 * time.Second},
+func NewWebhook(webhookURL string, opts ...WebhookOption) *Webhook {
+	w := &Webhook{url: webhookURL, format: FormatFlat, timeout: 10 * time.Second}
+	for _, opt := range opts {
+		opt(w)
 	}
+
+	transport := w.transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = http.ProxyFromEnvironment
+		if w.proxyURL != nil {
+			t.Proxy = http.ProxyURL(w.proxyURL)
+		}
+		if w.insecure {
+			t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		transport = t
+	}
+	w.client = &http.Client{Timeout: w.timeout, Transport: transport}
+	return w
 }
 
 func (w *Webhook) Name() string {
@@ -28,35 +188,243 @@ func (w *Webhook) Name() string {
 }
 
 func (w *Webhook) Notify(ctx context.Context, e event.Event) error {
-	payload := map[string]any{
-		"event":     string(e.Type),
-		"pr_number": e.PRNumber,
-		"title":     e.Title,
-		"author":    e.Author,
-		"branch":    e.Branch,
-		"timestamp": e.Timestamp.Format(time.RFC3339),
+	contentType := "application/json"
+	flat := flatPayload(e, w.instance)
+	var payload any = flat
+	if w.format == FormatCloudEvents {
+		ce, err := cloudEvent(e, w.instance)
+		if err != nil {
+			return err
+		}
+		payload = ce
+		contentType = "application/cloudevents+json"
+	}
+
+	if w.batchInterval > 0 {
+		w.hold(payload)
+		return nil
 	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("marshaling webhook payload: %w", err)
 	}
+	return w.post(ctx, body, contentType)
+}
 
+// hold adds payload to the batch, starting the flush timer for a new one.
+func (w *Webhook) hold(payload any) {
+	w.batchMu.Lock()
+	defer w.batchMu.Unlock()
+	w.batch = append(w.batch, payload)
+	if w.batchTimer == nil {
+		w.batchTimer = time.AfterFunc(w.batchInterval, func() {
+			if err := w.Flush(context.Background()); err != nil {
+				w.batchMu.Lock()
+				failed := w.batchFailed
+				w.batchMu.Unlock()
+				if failed == nil {
+					log.Printf("webhook: %v", err)
+					return
+				}
+				failed(err)
+			}
+		})
+	}
+}
+
+// OnFailure reports batches that fail to send once WithBatch's interval is
+// up to fn instead of logging them. Failures of an explicit Flush are
+// returned as usual.
+func (w *Webhook) OnFailure(fn func(err error)) {
+	w.batchMu.Lock()
+	defer w.batchMu.Unlock()
+	w.batchFailed = fn
+}
+
+// Flush sends the events held back by WithBatch, if any, in one request.
+func (w *Webhook) Flush(ctx context.Context) error {
+	w.batchMu.Lock()
+	batch := w.batch
+	w.batch = nil
+	if w.batchTimer != nil {
+		w.batchTimer.Stop()
+		w.batchTimer = nil
+	}
+	w.batchMu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]any{"events": batch})
+	if err != nil {
+		return fmt.Errorf("marshaling webhook batch: %w", err)
+	}
+	if err := w.post(ctx, body, "application/json"); err != nil {
+		return fmt.Errorf("sending batch of %d events: %w", len(batch), err)
+	}
+	return nil
+}
+
+// post sends body, retrying as configured by WithRetries.
+func (w *Webhook) post(ctx context.Context, body []byte, contentType string) error {
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.send(ctx, body, contentType)
+		if err == nil || !retryable || attempt == w.retries {
+			if err != nil && attempt > 0 {
+				return fmt.Errorf("%w (gave up after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+		timer := time.NewTimer(retryDelay << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one webhook request and reports whether a failure is worth
+// retrying: connection errors, 429 and 5xx responses are, anything else the
+// receiver rejected is not.
+func (w *Webhook) send(ctx context.Context, body []byte, contentType string) (retryable bool, err error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("creating webhook request: %w", err)
+		return false, fmt.Errorf("creating webhook request: %w", withoutURL(err))
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", signature(w.secret, timestamp, body))
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("sending webhook: %w", err)
+		return ctx.Err() == nil, fmt.Errorf("sending webhook: %w", withoutURL(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retryable, fmt.Errorf("webhook returned status %d", resp.StatusCode)
 	}
 
-	return nil
+	return false, nil
+}
+
+// withoutURL strips the URL a *url.Error quotes from err. Webhook URLs often
+// carry their secret in the path, and failures end up in logs and in
+// notification_failed events.
+func withoutURL(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return fmt.Errorf("%s: %w", ue.Op, ue.Err)
+	}
+	return err
+}
+
+// flatPayload is the flat JSON object for e: the event fields at the top level,
+// plus the instance name if any.
+func flatPayload(e event.Event, instance string) map[string]any {
+	flat := map[string]any{
+		"event":     string(e.Type),
+		"pr_number": e.PRNumber,
+		"title":     e.Title,
+		"author":    e.Author,
+		"branch":    e.Branch,
+		"commit":    e.Commit,
+		"timestamp": e.Timestamp.Format(time.RFC3339),
+	}
+	if e.Repo != "" {
+		flat["repo"] = e.Repo
+	}
+	if len(e.Branches) > 0 {
+		flat["branches"] = e.Branches
+	}
+	if !e.ResetAt.IsZero() {
+		flat["reset_at"] = e.ResetAt.Format(time.RFC3339)
+	}
+	if e.Error != "" {
+		flat["error"] = e.Error
+	}
+	if e.Notifier != "" {
+		flat["notifier"] = e.Notifier
+	}
+	if e.Body != "" {
+		flat["body"] = event.Excerpt(e.Body)
+	}
+	if e.Summary != nil {
+		flat["summary"] = summaryPayload(e.Summary)
+	}
+	if instance != "" {
+		flat["instance"] = instance
+	}
+	return flat
+}
+
+// summaryPayload is the JSON object for a DailySummary's digest. The PR
+// lists are always arrays, empty rather than null.
+func summaryPayload(s *event.Summary) map[string]any {
+	summary := map[string]any{
+		"landed":  append([]int{}, s.Landed...),
+		"pending": append([]int{}, s.Pending...),
+	}
+	if s.OldestPending != 0 {
+		summary["oldest_pending"] = s.OldestPending
+		summary["pending_since"] = s.PendingSince.Format(time.RFC3339)
+	}
+	return summary
+}
+
+// cloudEvent wraps e in a CloudEvents 1.0 structured-mode envelope. The
+// instance name, if any, is appended to the source.
+func cloudEvent(e event.Event, instance string) (map[string]any, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("generating CloudEvents id: %w", err)
+	}
+	source := "nixpkgs-pr-tracker"
+	if instance != "" {
+		source += "/" + url.PathEscape(instance)
+	}
+	data := map[string]any{
+		"pr_number": e.PRNumber,
+		"title":     e.Title,
+		"author":    e.Author,
+		"branch":    e.Branch,
+		"commit":    e.Commit,
+	}
+	if e.Repo != "" {
+		data["repo"] = e.Repo
+	}
+	if len(e.Branches) > 0 {
+		data["branches"] = e.Branches
+	}
+	if !e.ResetAt.IsZero() {
+		data["reset_at"] = e.ResetAt.Format(time.RFC3339)
+	}
+	if e.Error != "" {
+		data["error"] = e.Error
+	}
+	if e.Notifier != "" {
+		data["notifier"] = e.Notifier
+	}
+	if e.Body != "" {
+		data["body"] = event.Excerpt(e.Body)
+	}
+	if e.Summary != nil {
+		data["summary"] = summaryPayload(e.Summary)
+	}
+	return map[string]any{
+		"specversion":     "1.0",
+		"type":            cloudEventTypePrefix + string(e.Type),
+		"source":          source,
+		"id":              hex.EncodeToString(id[:]),
+		"time":            e.Timestamp.Format(time.RFC3339),
+		"datacontenttype": "application/json",
+		"data":            data,
+	}, nil
 }
@@ -2,12 +2,23 @@ package notifier
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
 )
@@ -61,6 +72,353 @@ func TestWebhookNotifySuccess(t *testing.T) {
 	}
 }
 
+func TestWebhookTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		wantErr bool
+	}{
+		{"within timeout", 0, false},
+		{"past timeout", 200 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			err := NewWebhook(srv.URL, WithTimeout(50*time.Millisecond)).Notify(context.Background(), event.Event{Type: event.PRAdded})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var netErr net.Error
+			if tt.wantErr && !(errors.As(err, &netErr) && netErr.Timeout()) {
+				t.Errorf("error = %v, want a timeout", err)
+			}
+		})
+	}
+}
+
+func TestWebhookCloudEvents(t *testing.T) {
+	var receivedBody map[string]any
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &receivedBody)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL, WithFormat(FormatCloudEvents))
+	ts := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	err := w.Notify(context.Background(), event.Event{
+		Type:      event.PRLandedBranch,
+		PRNumber:  42,
+		Title:     "test",
+		Author:    "user1",
+		Branch:    "nixos-unstable",
+		Timestamp: ts,
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if contentType != "application/cloudevents+json" {
+		t.Errorf("Content-Type = %q, want application/cloudevents+json", contentType)
+	}
+	want := map[string]string{
+		"specversion":     "1.0",
+		"type":            "dev.nixpkgs-pr-tracker.pr_landed_branch",
+		"source":          "nixpkgs-pr-tracker",
+		"time":            "2025-01-01T12:30:00Z",
+		"datacontenttype": "application/json",
+	}
+	for k, v := range want {
+		if receivedBody[k] != v {
+			t.Errorf("%s = %v, want %q", k, receivedBody[k], v)
+		}
+	}
+	if id, _ := receivedBody["id"].(string); id == "" {
+		t.Error("id is empty")
+	}
+	if _, ok := receivedBody["event"]; ok {
+		t.Error("flat field 'event' present in CloudEvents envelope")
+	}
+
+	data, ok := receivedBody["data"].(map[string]any)
+	if !ok {
+		t.Fatalf("data = %v, want object", receivedBody["data"])
+	}
+	if int(data["pr_number"].(float64)) != 42 {
+		t.Errorf("data.pr_number = %v, want 42", data["pr_number"])
+	}
+	if data["branch"] != "nixos-unstable" {
+		t.Errorf("data.branch = %v, want nixos-unstable", data["branch"])
+	}
+}
+
+func TestWebhookInstance(t *testing.T) {
+	tests := []struct {
+		format WebhookFormat
+		field  string
+		want   string
+	}{
+		{FormatFlat, "instance", "homelab"},
+		{FormatCloudEvents, "source", "nixpkgs-pr-tracker/homelab"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var receivedBody map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&receivedBody)
+			}))
+			defer srv.Close()
+
+			w := NewWebhook(srv.URL, WithFormat(tt.format), WithInstance("homelab"))
+			if err := w.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			if receivedBody[tt.field] != tt.want {
+				t.Errorf("%s = %v, want %q", tt.field, receivedBody[tt.field], tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookRepo(t *testing.T) {
+	for _, format := range []WebhookFormat{FormatFlat, FormatCloudEvents} {
+		t.Run(string(format), func(t *testing.T) {
+			var receivedBody map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&receivedBody)
+			}))
+			defer srv.Close()
+
+			w := NewWebhook(srv.URL, WithFormat(format))
+			if err := w.Notify(context.Background(), event.Event{Type: event.PRMerged, Repo: "owner/other", PRNumber: 1}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			fields := receivedBody
+			if format == FormatCloudEvents {
+				fields, _ = receivedBody["data"].(map[string]any)
+			}
+			if fields["repo"] != "owner/other" {
+				t.Errorf("repo = %v, want owner/other", fields["repo"])
+			}
+
+			receivedBody = nil
+			if err := w.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			fields = receivedBody
+			if format == FormatCloudEvents {
+				fields, _ = receivedBody["data"].(map[string]any)
+			}
+			if _, ok := fields["repo"]; ok {
+				t.Errorf("repo = %v for the primary repo, want it omitted", fields["repo"])
+			}
+		})
+	}
+}
+
+func TestWebhookFullyLandedBranches(t *testing.T) {
+	var receivedBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedBody)
+	}))
+	defer srv.Close()
+
+	err := NewWebhook(srv.URL).Notify(context.Background(), event.Event{
+		Type:     event.PRFullyLanded,
+		PRNumber: 42,
+		Branches: []string{"master", "nixos-unstable"},
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	branches, ok := receivedBody["branches"].([]any)
+	if !ok || len(branches) != 2 || branches[0] != "master" || branches[1] != "nixos-unstable" {
+		t.Errorf("branches = %v, want [master nixos-unstable]", receivedBody["branches"])
+	}
+}
+
+func TestWebhookReopened(t *testing.T) {
+	var receivedBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedBody)
+	}))
+	defer srv.Close()
+
+	// The default filter, as without NPT_NOTIFY_EVENTS, lets reopens through.
+	f := NewFilter(NewWebhook(srv.URL), event.Types)
+	if err := f.Notify(context.Background(), event.Event{Type: event.PRReopened, PRNumber: 42, Title: "Back again"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if receivedBody["event"] != "pr_reopened" || receivedBody["pr_number"] != float64(42) {
+		t.Errorf("body = %v, want pr_reopened for PR 42", receivedBody)
+	}
+}
+
+func TestWebhookBody(t *testing.T) {
+	long := "Changelog: https://example.com/releases\n\n" + strings.Repeat("x", event.MaxBodyLen)
+	for _, format := range []WebhookFormat{FormatFlat, FormatCloudEvents} {
+		t.Run(string(format), func(t *testing.T) {
+			var receivedBody map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&receivedBody)
+			}))
+			defer srv.Close()
+
+			wh := NewWebhook(srv.URL, WithFormat(format))
+			if err := wh.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 42, Body: long}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			fields := receivedBody
+			if format == FormatCloudEvents {
+				fields, _ = receivedBody["data"].(map[string]any)
+			}
+			body, _ := fields["body"].(string)
+			if !strings.HasPrefix(body, "Changelog: https://example.com/releases") || !strings.HasSuffix(body, "…") {
+				t.Errorf("body = %q, want the description truncated with an ellipsis", body)
+			}
+			if n := utf8.RuneCountInString(body); n != event.MaxBodyLen {
+				t.Errorf("body has %d characters, want %d", n, event.MaxBodyLen)
+			}
+
+			receivedBody = nil
+			if err := wh.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 42}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			fields = receivedBody
+			if format == FormatCloudEvents {
+				fields, _ = receivedBody["data"].(map[string]any)
+			}
+			if _, ok := fields["body"]; ok {
+				t.Errorf("body = %v, want it omitted without a description", fields["body"])
+			}
+		})
+	}
+}
+
+func TestWebhookRateLimitedResetAt(t *testing.T) {
+	var receivedBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedBody)
+	}))
+	defer srv.Close()
+
+	reset := time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)
+	if err := NewWebhook(srv.URL).Notify(context.Background(), event.Event{Type: event.RateLimited, ResetAt: reset}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if receivedBody["event"] != "rate_limited" || receivedBody["reset_at"] != "2025-01-01T13:00:00Z" {
+		t.Errorf("body = %v, want rate_limited with reset_at 2025-01-01T13:00:00Z", receivedBody)
+	}
+}
+
+func TestWebhookDailySummary(t *testing.T) {
+	var receivedBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedBody)
+	}))
+	defer srv.Close()
+
+	summary := &event.Summary{Landed: []int{1, 4}}
+	if err := NewWebhook(srv.URL).Notify(context.Background(), event.Event{Type: event.DailySummary, Summary: summary}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	got, _ := json.Marshal(receivedBody["summary"])
+	if receivedBody["event"] != "daily_summary" || string(got) != `{"landed":[1,4],"pending":[]}` {
+		t.Errorf("body = %v, want daily_summary with landed [1 4] and no pending PRs", receivedBody)
+	}
+}
+
+func TestWebhookNoInstanceOmitsField(t *testing.T) {
+	var receivedBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedBody)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook(srv.URL).Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if _, ok := receivedBody["instance"]; ok {
+		t.Errorf("instance = %v, want field omitted", receivedBody["instance"])
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	e := event.Event{Type: event.PRMerged, PRNumber: 1}
+	if err := NewWebhook(srv.URL, WithSecret("s3cret")).Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	ts := header.Get("X-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		t.Fatalf("X-Timestamp = %q, want Unix seconds", ts)
+	}
+	if d := time.Since(time.Unix(sec, 0)); d < -time.Second || d > time.Minute {
+		t.Errorf("X-Timestamp %s is %s away from now", ts, d)
+	}
+
+	sign := func(msg string) string {
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(msg))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	got := header.Get("X-Signature")
+	if want := sign(ts + "." + string(body)); got != want {
+		t.Errorf("X-Signature = %q, want HMAC of timestamp.body %q", got, want)
+	}
+	if got == sign(string(body)) {
+		t.Error("X-Signature covers only the body, want the timestamp included")
+	}
+
+	// Without a secret nothing is signed.
+	if err := NewWebhook(srv.URL).Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if header.Get("X-Signature") != "" || header.Get("X-Timestamp") != "" {
+		t.Errorf("unsigned webhook sent X-Signature %q, X-Timestamp %q", header.Get("X-Signature"), header.Get("X-Timestamp"))
+	}
+}
+
+func TestWebhookCloudEventsUniqueIDs(t *testing.T) {
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		ids = append(ids, body["id"].(string))
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL, WithFormat(FormatCloudEvents))
+	for i := 0; i < 2; i++ {
+		if err := w.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1}); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Errorf("ids = %v, want two distinct ids", ids)
+	}
+}
+
 func TestWebhookNotifyServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -74,6 +432,63 @@ func TestWebhookNotifyServerError(t *testing.T) {
 	}
 }
 
+func TestWebhookRetries(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	tests := []struct {
+		name     string
+		status   int
+		retries  int
+		wantHits int
+	}{
+		{"server error retried", http.StatusInternalServerError, 2, 3},
+		{"rate limit retried", http.StatusTooManyRequests, 1, 2},
+		{"client error not retried", http.StatusBadRequest, 2, 1},
+		{"no retries by default", http.StatusInternalServerError, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			w := NewWebhook(srv.URL, WithRetries(tt.retries))
+			if err := w.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1}); err == nil {
+				t.Fatal("expected error")
+			}
+			if hits != tt.wantHits {
+				t.Errorf("requests = %d, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestWebhookRetrySucceeds(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL, WithRetries(3))
+	if err := w.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("requests = %d, want 2", hits)
+	}
+}
+
 func TestWebhookNotifyConnectionRefused(t *testing.T) {
 	w := NewWebhook("http://127.0.0.1:1") // port 1 — nothing listening
 	err := w.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1})
@@ -97,3 +512,164 @@ func TestWebhookNotifyCancelledContext(t *testing.T) {
 		t.Fatal("expected error for cancelled context")
 	}
 }
+
+func TestWebhookWithProxy(t *testing.T) {
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	w := NewWebhook("http://hooks.example.invalid/inlet", WithProxy(proxyURL))
+	if err := w.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if proxiedURL != "http://hooks.example.invalid/inlet" {
+		t.Errorf("proxied URL = %q, want %q", proxiedURL, "http://hooks.example.invalid/inlet")
+	}
+}
+
+func TestWebhookInsecureSkipVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	e := event.Event{Type: event.PRAdded, PRNumber: 1}
+	if err := NewWebhook(srv.URL).Notify(context.Background(), e); err == nil {
+		t.Error("expected a TLS error for a self-signed certificate by default")
+	}
+	if err := NewWebhook(srv.URL, WithInsecureSkipVerify()).Notify(context.Background(), e); err != nil {
+		t.Errorf("Notify with skip-verify: %v", err)
+	}
+}
+
+// recordingTransport records each request before passing it on to
+// http.DefaultTransport.
+type recordingTransport struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.urls = append(rt.urls, req.Method+" "+req.URL.Path)
+	rt.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWebhookWithTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	rt := &recordingTransport{}
+	wh := NewWebhook(srv.URL+"/hook", WithTransport(rt))
+	if err := wh.Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 1}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if strings.Join(rt.urls, ",") != "POST /hook" {
+		t.Errorf("transport saw %q, want POST /hook", rt.urls)
+	}
+}
+
+// batchReceiver records the events of each batch request it gets.
+func batchReceiver(t *testing.T) (*httptest.Server, func() [][]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var batches [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Events []struct {
+				Event    string `json:"event"`
+				PRNumber int    `json:"pr_number"`
+			} `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding batch: %v", err)
+		}
+		var batch []string
+		for _, e := range body.Events {
+			batch = append(batch, e.Event+":"+strconv.Itoa(e.PRNumber))
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(batches)
+	}
+}
+
+func TestWebhookBatch(t *testing.T) {
+	srv, batches := batchReceiver(t)
+
+	w := NewWebhook(srv.URL, WithBatch(50*time.Millisecond))
+	for _, e := range []event.Event{
+		{Type: event.PRMerged, PRNumber: 1},
+		{Type: event.PRLandedBranch, PRNumber: 1, Branch: "master"},
+		{Type: event.PRMerged, PRNumber: 2},
+	} {
+		if err := w.Notify(context.Background(), e); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if got := batches(); len(got) != 0 {
+		t.Fatalf("sent %v before the batch interval ended", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(batches()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond) // catch any stray second request
+	got := batches()
+	if len(got) != 1 || strings.Join(got[0], ",") != "pr_merged:1,pr_landed_branch:1,pr_merged:2" {
+		t.Errorf("batches = %v, want one with pr_merged:1, pr_landed_branch:1, pr_merged:2", got)
+	}
+}
+
+func TestWebhookBatchFlushOnShutdown(t *testing.T) {
+	srv, batches := batchReceiver(t)
+
+	g := NewGraceful(NewFilter(NewWebhook(srv.URL, WithBatch(time.Hour)), event.Types))
+	g.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1})
+	g.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 2})
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	got := batches()
+	if len(got) != 1 || strings.Join(got[0], ",") != "pr_merged:1,pr_merged:2" {
+		t.Errorf("batches = %v, want the two held events sent on shutdown", got)
+	}
+}
+
+func TestWebhookBatchOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	failed := make(chan error, 1)
+	g := NewGraceful(NewFilter(NewWebhook(srv.URL, WithBatch(10*time.Millisecond)), event.Types))
+	g.OnFailure(func(err error) { failed <- err })
+	if err := g.Notify(context.Background(), event.Event{Type: event.PRMerged, PRNumber: 1}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	select {
+	case err := <-failed:
+		if !strings.Contains(err.Error(), "batch of 1 events") || !strings.Contains(err.Error(), "502") {
+			t.Errorf("failure = %v, want the batch's 502", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("failed batch was never reported")
+	}
+}
@@ -3,7 +3,13 @@ package poller
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/db"
@@ -12,6 +18,17 @@ import (
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/topology"
 )
 
+// stallFactor is how many poll intervals may pass without a completed poll
+// cycle before Health reports the poller as stalled.
+const stallFactor = 3
+
+// listPRsAttempts bounds how many times a poll cycle tries to read the
+// tracked PRs before giving up until the next tick. listPRsRetryDelay is the
+// base backoff between attempts and is a variable so tests can shorten it.
+const listPRsAttempts = 3
+
+var listPRsRetryDelay = time.Second
+
 type Poller struct {
 	db                   *db.DB
 	gh                   *github.Client
@@ -19,47 +36,589 @@ type Poller struct {
 	interval             time.Duration
 	notificationBranches []string
 	targetBranches       []string
+	stages               []string // ordered refs a landing moves through
+	landingFallbackAfter time.Duration
+	channelRevisionURL   string
+	eventRetention       time.Duration
+	pollTimeout          time.Duration
+	compareTimeout       time.Duration
+	branchConcurrency    int
+	errorBudget          int
+	rateReserve          int
+	deferFirstPoll       bool
+	jitter               float64 // fraction of the interval, e.g. 0.1 for ±10%
+	pruneClosedAfter     time.Duration
+	reopenCheckEvery     time.Duration
+	landedRetention      time.Duration
+	notifyChecks         bool
+	includeBody          bool
+	commentOnLand        bool
+	groupLandings        bool
+	failureThreshold     int
+	removeOnFailure      bool
+	dailySummary         bool
+	summaryHour          int // local time of the daily summary
+	summaryMinute        int
+
+	// reset wakes the Start loop after SetInterval so the ticker picks up
+	// the new interval.
+	reset chan struct{}
+
+	// resumePRs holds the PRs a cycle didn't get to before its deadline or
+	// error budget ran out, so the next cycle polls them first. Only the
+	// Start loop touches it.
+	resumePRs map[int]bool
+
+	// cycleErrors counts failed PR and commit polls in the current cycle
+	// against errorBudget. Only the Start loop touches it.
+	cycleErrors int
+
+	// rateLimitedUntil is the reset time of the last RateLimited event, so
+	// repeated rate limits within the same window publish only once. Only
+	// the Start loop touches it.
+	rateLimitedUntil time.Time
+
+	// trigger asks the Start loop to run a cycle now (see RunNow).
+	trigger chan struct{}
+
+	now func() time.Time
+
+	// rand returns a number in [0, 1) for jitter; it is rand.Float64
+	// outside of tests. Only the Start loop uses it.
+	rand func() float64
+
+	// listPRs reads the tracked PRs; it is db.ListPRs outside of tests.
+	listPRs func() ([]db.TrackedPR, error)
+
+	// mu guards interval, the branch lists, paused, manualRun,
+	// lastTriggered, inflight, started, lastSuccess, rateReset, failures,
+	// checksPassed and reopenChecked.
+	// failures counts consecutive failed polls per PR.
+	// rateReset is the rate-limit reset the poller last held off cycles
+	// for, after hitting the limit or skipping a cycle short of it.
+	// inflight holds a done channel per PR currently being polled, so the
+	// scheduled poll and manual refreshes never process the same PR at once.
+	// checksPassed records the head SHA each open PR last announced green
+	// checks for, so PRChecksPassed fires once per push.
+	// reopenChecked records when each closed PR was last fetched to look
+	// for a reopen.
+	mu            sync.Mutex
+	paused        bool
+	manualRun     bool // a RunNow cycle is queued or running
+	lastTriggered time.Time
+	inflight      map[int]chan struct{}
+	started       time.Time
+	lastSuccess   time.Time
+	rateReset     time.Time
+	failures      map[int]int
+	checksPassed  map[int]string
+	reopenChecked map[int]time.Time
+}
+
+// Option configures optional Poller behavior.
+type Option func(*Poller)
+
+// WithLandingFallback enables the commit-message landing heuristic for
+// branches where a merged PR's merge commit still hasn't appeared after the
+// given duration (e.g. channels populated by squash merges).
+func WithLandingFallback(after time.Duration) Option {
+	return func(p *Poller) {
+		p.landingFallbackAfter = after
+	}
+}
+
+// WithStages orders branches into a pipeline of stages, e.g. a release's
+// staging-24.11, staging-next-24.11, release-24.11 and nixos-24.11. Like the
+// built-in topology, once a stage has landed the stages before it are no
+// longer checked, so each stage gets its PRLandedBranch as the landing
+// progresses and none is announced after a later one.
+func WithStages(stages []string) Option {
+	return func(p *Poller) {
+		p.stages = stages
+	}
+}
+
+// WithChannelRevision confirms landings against the commit a channel was
+// built from instead of the branch head. urlTemplate is fetched with
+// "{branch}" replaced by the branch name, e.g.
+// "https://channels.nixos.org/{branch}/git-revision". Branches without a
+// published revision (404) are compared against the branch as usual.
+func WithChannelRevision(urlTemplate string) Option {
+	return func(p *Poller) {
+		p.channelRevisionURL = urlTemplate
+	}
+}
+
+// WithEventRetention prunes events older than retention from the events log
+// on every poll cycle. Zero disables pruning.
+func WithEventRetention(retention time.Duration) Option {
+	return func(p *Poller) {
+		p.eventRetention = retention
+	}
+}
+
+// WithClosedPruning makes Start remove PRs that have been closed (without
+// merging) for longer than after, once, before its first cycle. Zero
+// disables the sweep.
+func WithClosedPruning(after time.Duration) Option {
+	return func(p *Poller) {
+		p.pruneClosedAfter = after
+	}
+}
+
+// WithReopenCheck makes the poller fetch closed PRs from GitHub again at
+// most once per every, and move those that were reopened back to "open"
+// with a PRReopened event. Zero (the default) leaves closed PRs alone.
+func WithReopenCheck(every time.Duration) Option {
+	return func(p *Poller) {
+		p.reopenCheckEvery = every
+	}
+}
+
+// WithLandedRetention keeps a PR that has landed in every target branch for
+// the given duration, with status "landed", instead of removing it at once.
+// Landed PRs are not polled; the first cycle after the window removes them.
+func WithLandedRetention(d time.Duration) Option {
+	return func(p *Poller) {
+		p.landedRetention = d
+	}
+}
+
+// WithPollTimeout bounds how long a single poll cycle may run. PRs the cycle
+// doesn't reach in time are polled first in the next one. Zero (the default)
+// uses the poll interval.
+func WithPollTimeout(d time.Duration) Option {
+	return func(p *Poller) {
+		p.pollTimeout = d
+	}
+}
+
+// WithCompareTimeout bounds each compare of a merge commit or tracked commit
+// against a branch. A compare that times out is skipped for this cycle and
+// the remaining branches are still checked, so one hung request doesn't use
+// up the whole cycle. Zero (the default) bounds compares only by the cycle.
+func WithCompareTimeout(d time.Duration) Option {
+	return func(p *Poller) {
+		p.compareTimeout = d
+	}
+}
+
+// WithBranchConcurrency checks up to n branches of one merged PR at once.
+// The default is 1, checking them one after another.
+func WithBranchConcurrency(n int) Option {
+	return func(p *Poller) {
+		if n > 0 {
+			p.branchConcurrency = n
+		}
+	}
+}
+
+// WithErrorBudget ends a poll cycle early once n PR or commit polls in it
+// have failed, so a broad GitHub outage doesn't have the poller work through
+// every tracked item only to fail each one. The rest wait for the next
+// cycle, which polls the skipped PRs first. Zero disables the budget.
+func WithErrorBudget(n int) Option {
+	return func(p *Poller) {
+		p.errorBudget = n
+	}
+}
+
+// WithRateReserve keeps the last n requests of the GitHub rate limit for
+// open PRs: once fewer remain, a cycle stops before the merged and closed
+// PRs and the tracked commits, which are polled after the open PRs, and
+// leaves them for the next.
+// Catching a merge matters more than confirming a landing a cycle sooner.
+// Zero disables the reserve.
+func WithRateReserve(n int) Option {
+	return func(p *Poller) {
+		p.rateReserve = n
+	}
+}
+
+// WithDeferredFirstPoll makes Start wait for the first tick before polling
+// instead of running a cycle right away, so a restart with many tracked PRs
+// doesn't burst requests at GitHub.
+func WithDeferredFirstPoll() Option {
+	return func(p *Poller) {
+		p.deferFirstPoll = true
+	}
+}
+
+// WithJitter spreads poll cycles out by waiting a random interval within
+// ±percent of the poll interval between them, so trackers started together
+// don't hit GitHub in lockstep. Zero keeps the interval exact.
+func WithJitter(percent int) Option {
+	return func(p *Poller) {
+		p.jitter = float64(percent) / 100
+	}
+}
+
+// WithChecksNotification makes the poller fetch check runs for open PRs and
+// publish PRChecksPassed once all of them succeed for the current head commit.
+func WithChecksNotification() Option {
+	return func(p *Poller) {
+		p.notifyChecks = true
+	}
 }
 
-func New(database *db.DB, gh *github.Client, bus *event.Bus, interval time.Duration, notificationBranches []string, targetBranches []string) *Poller {
-	return &Poller{
+// WithPRBody keeps an excerpt of each polled PR's description and attaches
+// it to the PR's events, for notifications that include it.
+func WithPRBody() Option {
+	return func(p *Poller) {
+		p.includeBody = true
+	}
+}
+
+// WithCommentOnLand comments on a PR each time it lands in a branch, e.g.
+// "Landed in nixos-unstable as of 2024-05-01." The GitHub client's token
+// must be allowed to write to pull requests.
+func WithCommentOnLand() Option {
+	return func(p *Poller) {
+		p.commentOnLand = true
+	}
+}
+
+// WithGroupedLandings publishes one PRLandedBranch per PR and poll for the
+// branches it landed in, listed in Branches, instead of one per branch.
+func WithGroupedLandings() Option {
+	return func(p *Poller) {
+		p.groupLandings = true
+	}
+}
+
+// WithFailureThreshold publishes PRError once a PR has failed to poll n
+// cycles in a row (e.g. it keeps returning 404), and with remove set also
+// stops tracking it. Zero disables the check.
+func WithFailureThreshold(n int, remove bool) Option {
+	return func(p *Poller) {
+		p.failureThreshold = n
+		p.removeOnFailure = remove
+	}
+}
+
+// WithDailySummary publishes a DailySummary event every day at hour:minute
+// local time, digesting what landed over the past day and what is still
+// waiting to land.
+func WithDailySummary(hour, minute int) Option {
+	return func(p *Poller) {
+		p.dailySummary = true
+		p.summaryHour = hour
+		p.summaryMinute = minute
+	}
+}
+
+func New(database *db.DB, gh *github.Client, bus *event.Bus, interval time.Duration, notificationBranches []string, targetBranches []string, opts ...Option) *Poller {
+	p := &Poller{
 		db:                   database,
 		gh:                   gh,
 		bus:                  bus,
 		interval:             interval,
 		notificationBranches: notificationBranches,
 		targetBranches:       targetBranches,
+		reset:                make(chan struct{}, 1),
+		trigger:              make(chan struct{}, 1),
+		inflight:             make(map[int]chan struct{}),
+		checksPassed:         make(map[int]string),
+		reopenChecked:        make(map[int]time.Time),
+		failures:             make(map[int]int),
+		resumePRs:            make(map[int]bool),
+		branchConcurrency:    1,
+		now:                  time.Now,
+		rand:                 rand.Float64,
+		listPRs:              database.ListPRs,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.started = p.now()
+	return p
 }
 
 func (p *Poller) Start(ctx context.Context) {
 	go func() {
-		p.runPollCycle(ctx)
-		ticker := time.NewTicker(p.interval)
-		defer ticker.Stop()
+		p.pruneClosed()
+		if !p.deferFirstPoll {
+			p.runPollCycle(ctx)
+		}
+		// A timer re-armed as it fires keeps a ticker's cadence while
+		// letting every wait be jittered separately.
+		timer := time.NewTimer(p.nextInterval())
+		defer timer.Stop()
+		// The summary timer is re-armed for the next day's time rather
+		// than ticking every 24h, so it stays on time across DST changes.
+		var summary *time.Timer
+		var summaryC <-chan time.Time
+		if p.dailySummary {
+			summary = time.NewTimer(p.untilSummary())
+			defer summary.Stop()
+			summaryC = summary.C
+		}
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
+			case <-p.reset:
+				timer.Reset(p.nextInterval())
+			case <-summaryC:
+				summary.Reset(p.untilSummary())
+				p.publishSummary()
+			case <-timer.C:
+				timer.Reset(p.nextInterval())
+				p.runPollCycle(ctx)
+			case <-p.trigger:
 				p.runPollCycle(ctx)
+				p.mu.Lock()
+				p.manualRun = false
+				p.mu.Unlock()
 			}
 		}
 	}()
 }
 
+// RunNow queues a full poll cycle on the Start loop, so it never overlaps a
+// scheduled one, and returns immediately. It returns false without queueing
+// anything if a previous RunNow cycle hasn't finished yet.
+func (p *Poller) RunNow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.manualRun {
+		return false
+	}
+	p.manualRun = true
+	p.lastTriggered = p.now()
+	p.trigger <- struct{}{}
+	return true
+}
+
+// ManualRun reports whether a RunNow cycle is queued or running, and when
+// RunNow last queued one (zero if never).
+func (p *Poller) ManualRun() (running bool, lastTriggered time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.manualRun, p.lastTriggered
+}
+
+// SetInterval changes the poll interval. A running poller resets its ticker,
+// so the next cycle happens one new interval from now.
+func (p *Poller) SetInterval(d time.Duration) {
+	p.mu.Lock()
+	p.interval = d
+	p.mu.Unlock()
+	select {
+	case p.reset <- struct{}{}:
+	default:
+	}
+}
+
+// Interval returns the current poll interval.
+func (p *Poller) Interval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+// LandedRetention returns how long a fully landed PR is kept, or zero if it
+// is removed at once; see WithLandedRetention.
+func (p *Poller) LandedRetention() time.Duration {
+	return p.landedRetention
+}
+
+// nextInterval returns how long Start waits for the next cycle: the poll
+// interval, moved by up to ±jitter of itself.
+func (p *Poller) nextInterval() time.Duration {
+	d := p.Interval()
+	if p.jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + p.jitter*(2*p.rand()-1)))
+}
+
+// SetBranches replaces the notification and target branch sets. Tracked
+// merged PRs and commits are checked against the new notification branches
+// from their next poll on, including branches added after they merged.
+func (p *Poller) SetBranches(notificationBranches, targetBranches []string) {
+	p.mu.Lock()
+	p.notificationBranches = notificationBranches
+	p.targetBranches = targetBranches
+	p.mu.Unlock()
+}
+
+// branches returns the current notification and target branch sets.
+func (p *Poller) branches() (notificationBranches, targetBranches []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.notificationBranches, p.targetBranches
+}
+
+// Pause makes scheduled poll cycles do nothing until Resume is called.
+// Manual refreshes of a single PR still reach GitHub.
+func (p *Poller) Pause() {
+	p.mu.Lock()
+	p.paused = true
+	p.mu.Unlock()
+	log.Printf("poller: paused")
+}
+
+// Resume re-enables scheduled poll cycles. The next cycle runs at the next
+// tick.
+func (p *Poller) Resume() {
+	p.mu.Lock()
+	p.paused = false
+	p.mu.Unlock()
+	log.Printf("poller: resumed")
+}
+
+// Paused reports whether scheduled poll cycles are paused.
+func (p *Poller) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Health reports when the last poll cycle completed (zero if none has yet)
+// and whether the poller is healthy. It is unhealthy once more than
+// stallFactor intervals have passed since the last completed cycle, or since
+// the poller was created if no cycle has completed. A paused poller is
+// reported healthy: it is idle on purpose. So is one waiting for a rate
+// limit to reset, and the stall is counted from the reset.
+func (p *Poller) Health() (lastSuccess time.Time, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return p.lastSuccess, true
+	}
+	since := p.lastSuccess
+	if since.IsZero() {
+		since = p.started
+	}
+	if p.rateReset.After(since) {
+		since = p.rateReset
+	}
+	return p.lastSuccess, p.now().Sub(since) <= stallFactor*p.interval
+}
+
+// untilSummary returns how long until the next daily summary is due.
+func (p *Poller) untilSummary() time.Duration {
+	now := p.now()
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, p.summaryHour, p.summaryMinute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(y, m, d+1, p.summaryHour, p.summaryMinute, 0, 0, now.Location())
+	}
+	return next.Sub(now)
+}
+
+// maxSummaryEvents bounds how many pr_fully_landed events the daily summary
+// reads back from the events log.
+const maxSummaryEvents = 1000
+
+// publishSummary publishes a DailySummary of the tracked PRs. PRs that
+// finished landing have usually been removed by then, so landings are also
+// taken from the pr_fully_landed events in the events log.
+func (p *Poller) publishSummary() {
+	prs, err := p.listPRs()
+	if err != nil {
+		log.Printf("poller: listing PRs for the daily summary: %v", err)
+		return
+	}
+	now := p.now()
+	since := now.Add(-24 * time.Hour)
+
+	var summary event.Summary
+	landed := make(map[int]bool)
+	for _, pr := range prs {
+		for _, bs := range pr.Branches {
+			if bs.Landed && bs.LandedAt != nil && !bs.LandedAt.Before(since) {
+				landed[pr.PRNumber] = true
+			}
+		}
+		if pr.Status != "merged" {
+			continue
+		}
+		summary.Pending = append(summary.Pending, pr.PRNumber)
+		// Without a recorded merge time, fall back to the last update,
+		// which for most PRs is when they merged.
+		mergedAt := pr.MergedAt
+		if mergedAt.IsZero() {
+			mergedAt = pr.UpdatedAt
+		}
+		if summary.OldestPending == 0 || mergedAt.Before(summary.PendingSince) {
+			summary.OldestPending = pr.PRNumber
+			summary.PendingSince = mergedAt
+		}
+	}
+	events, err := p.db.ListEventsByType(maxSummaryEvents, string(event.PRFullyLanded))
+	if err != nil {
+		log.Printf("poller: listing landings for the daily summary: %v", err)
+	}
+	for _, e := range events {
+		if e.Repo == p.db.Repo() && !e.CreatedAt.Before(since) {
+			landed[e.PRNumber] = true
+		}
+	}
+	for n := range landed {
+		summary.Landed = append(summary.Landed, n)
+	}
+	slices.Sort(summary.Landed)
+	slices.Sort(summary.Pending)
+
+	log.Printf("poller: daily summary: %d PRs landed, %d pending", len(summary.Landed), len(summary.Pending))
+	p.publish(event.Event{
+		Type:      event.DailySummary,
+		Summary:   &summary,
+		Timestamp: now,
+	})
+}
+
+// publish publishes e tagged with the repository the poller tracks.
+func (p *Poller) publish(e event.Event) {
+	e.Repo = p.db.Repo()
+	p.bus.Publish(e)
+}
+
 // runPollCycle runs a poll and, if rate-limited, waits until the reset time
 // before returning so the next ticker tick doesn't fire too early.
 func (p *Poller) runPollCycle(ctx context.Context) {
-	rlErr := p.poll(ctx)
+	if p.Paused() {
+		log.Printf("poller: paused, skipping cycle")
+		return
+	}
+	p.pruneEvents()
+	timeout := p.pollTimeout
+	if timeout <= 0 {
+		timeout = p.Interval()
+	}
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	prs, listErr := p.listTrackedPRs(pollCtx)
+	if listErr == nil && p.rateTooLow(prs) {
+		cancel()
+		return
+	}
+	rlErr := p.pollListed(pollCtx, prs, listErr)
+	cycleErr := pollCtx.Err()
+	cancel()
 	if rlErr == nil {
+		if cycleErr == nil {
+			p.mu.Lock()
+			p.lastSuccess = p.now()
+			p.mu.Unlock()
+		}
 		return
 	}
-	wait := time.Until(rlErr.RetryAfter)
+	wait := rlErr.RetryAfter.Sub(p.now())
 	if wait <= 0 {
 		return
 	}
 	log.Printf("poller: waiting %s until rate limit resets", wait.Round(time.Second))
+	p.holdUntil(rlErr.RetryAfter)
+	if !p.now().Before(p.rateLimitedUntil) {
+		p.rateLimitedUntil = rlErr.RetryAfter
+		p.publish(event.Event{
+			Type:      event.RateLimited,
+			ResetAt:   rlErr.RetryAfter,
+			Timestamp: p.now(),
+		})
+	}
 	timer := time.NewTimer(wait)
 	defer timer.Stop()
 	select {
@@ -68,13 +627,115 @@ func (p *Poller) runPollCycle(ctx context.Context) {
 	}
 }
 
-func (p *Poller) poll(ctx context.Context) *github.RateLimitError {
+// rateTooLow reports whether the rate limit GitHub last reported leaves
+// fewer requests before it resets than prs, the cycle's tracked PRs, and the
+// tracked commits need, in which case the cycle is skipped rather than run
+// into the limit part way through. Each PR that isn't landed and each
+// commit needs at least one request; with a rate reserve only the open PRs
+// count, since the rest may wait.
+func (p *Poller) rateTooLow(prs []db.TrackedPR) bool {
+	rl, ok := p.gh.RateLimit()
+	if !ok || !p.now().Before(rl.Reset) {
+		return false
+	}
+	needed := 0
+	for _, pr := range prs {
+		if pr.Status == "open" || (p.rateReserve <= 0 && pr.Status != "landed") {
+			needed++
+		}
+	}
+	if p.rateReserve <= 0 {
+		commits, err := p.db.ListCommits()
+		if err != nil {
+			log.Printf("poller: listing commits: %v", err)
+		}
+		needed += len(commits)
+	}
+	if rl.Remaining >= needed {
+		return false
+	}
+	log.Printf("poller: %d GitHub requests left until the rate limit resets at %s, the tracked PRs and commits need at least %d; skipping cycle",
+		rl.Remaining, rl.Reset.Format("15:04:05"), needed)
+	p.holdUntil(rl.Reset)
+	return true
+}
+
+// holdUntil records that cycles are held off until the rate limit resets at
+// reset, for Health.
+func (p *Poller) holdUntil(reset time.Time) {
+	p.mu.Lock()
+	p.rateReset = reset
+	p.mu.Unlock()
+}
+
+func (p *Poller) pruneEvents() {
+	if p.eventRetention <= 0 {
+		return
+	}
+	n, err := p.db.PruneEvents(p.now().Add(-p.eventRetention))
+	if err != nil {
+		log.Printf("poller: pruning events: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("poller: pruned %d events older than %s", n, p.eventRetention)
+	}
+}
+
+// pruneClosed removes PRs whose closed status is older than
+// pruneClosedAfter and publishes PRRemoved for each. Closed PRs are at most
+// checked for a reopen, which leaves them untouched unless it moves them
+// back to open, so updated_at is when the poller saw them close.
+func (p *Poller) pruneClosed() {
+	if p.pruneClosedAfter <= 0 {
+		return
+	}
 	prs, err := p.db.ListPRs()
+	if err != nil {
+		log.Printf("poller: listing PRs to prune: %v", err)
+		return
+	}
+	cutoff := p.now().Add(-p.pruneClosedAfter)
+	for _, pr := range prs {
+		if pr.Status != "closed" || !pr.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if err := p.db.RemovePR(pr.PRNumber); err != nil {
+			p.prLogf(pr.PRNumber, "", "pruning closed PR: %v", err)
+			continue
+		}
+		p.Forget(pr.PRNumber)
+		p.prLogf(pr.PRNumber, "", "pruned, closed since %s", pr.UpdatedAt.Format(time.DateOnly))
+		p.publish(event.Event{
+			Type:      event.PRRemoved,
+			PRNumber:  pr.PRNumber,
+			Title:     pr.Title,
+			Author:    pr.Author,
+			Body:      p.eventBody(pr.Body),
+			Timestamp: p.now(),
+		})
+	}
+}
+
+func (p *Poller) poll(ctx context.Context) *github.RateLimitError {
+	prs, err := p.listTrackedPRs(ctx)
+	return p.pollListed(ctx, prs, err)
+}
+
+// pollListed polls prs, as returned by listTrackedPRs along with err, and
+// then the tracked commits. A listing error skips the PRs but not the
+// commits.
+func (p *Poller) pollListed(ctx context.Context, prs []db.TrackedPR, err error) *github.RateLimitError {
+	p.cycleErrors = 0
 	if err != nil {
 		log.Printf("poller: listing PRs: %v", err)
-		return nil
+	} else if rlErr := p.pollPRs(ctx, prs); rlErr != nil {
+		return rlErr
 	}
+	return p.pollCommits(ctx)
+}
 
+func (p *Poller) pollPRs(ctx context.Context, prs []db.TrackedPR) *github.RateLimitError {
 	if len(prs) == 0 {
 		log.Printf("poller: no PRs to check")
 		return nil
@@ -86,63 +747,319 @@ func (p *Poller) poll(ctx context.Context) *github.RateLimitError {
 	}
 	log.Printf("poller: checking %d PRs: %v", len(prs), prNumbers)
 
-	for _, pr := range prs {
+	// Open PRs go first, see WithRateReserve. Within each group, PRs cut
+	// off by the last cycle's deadline go first.
+	slices.SortStableFunc(prs, func(a, b db.TrackedPR) int {
+		switch {
+		case a.Status == "open" && b.Status != "open":
+			return -1
+		case a.Status != "open" && b.Status == "open":
+			return 1
+		case p.resumePRs[a.PRNumber] && !p.resumePRs[b.PRNumber]:
+			return -1
+		case !p.resumePRs[a.PRNumber] && p.resumePRs[b.PRNumber]:
+			return 1
+		}
+		return 0
+	})
+	clear(p.resumePRs)
+
+	for i, pr := range prs {
 		if ctx.Err() != nil {
+			p.deferPRs(ctx, prs[i:], len(prs))
 			return nil
 		}
-		if err := p.pollPR(ctx, pr); err != nil {
+		if p.budgetExhausted() {
+			log.Printf("poller: error budget of %d failures used up, skipping %d of %d PRs until next cycle", p.errorBudget, len(prs)-i, len(prs))
+			for _, pr := range prs[i:] {
+				p.resumePRs[pr.PRNumber] = true
+			}
+			return nil
+		}
+		if pr.Status != "open" && p.rateReserve > 0 {
+			if rl, ok := p.gh.RateLimit(); ok && rl.Remaining < p.rateReserve {
+				log.Printf("poller: %d GitHub requests left, below the reserve of %d; skipping %d of %d PRs that aren't open until next cycle", rl.Remaining, p.rateReserve, len(prs)-i, len(prs))
+				for _, pr := range prs[i:] {
+					p.resumePRs[pr.PRNumber] = true
+				}
+				return nil
+			}
+		}
+		if err := p.pollTracked(ctx, pr.PRNumber); err != nil {
 			var rlErr *github.RateLimitError
 			if errors.As(err, &rlErr) {
 				log.Printf("poller: rate limited, resets at %s, skipping remaining PRs", rlErr.RetryAfter.Format("15:04:05"))
 				return rlErr
 			}
+			if ctx.Err() != nil {
+				p.deferPRs(ctx, prs[i:], len(prs))
+				return nil
+			}
+			p.cycleErrors++
+			// A transient error (e.g. a truncated response) is simply
+			// retried next cycle and says nothing about the PR itself.
+			if !errors.Is(err, github.ErrTransient) && p.recordFailure(pr, err) {
+				continue
+			}
+		} else {
+			p.mu.Lock()
+			delete(p.failures, pr.PRNumber)
+			p.mu.Unlock()
 		}
 		if err := p.db.UpdateLastChecked(pr.PRNumber); err != nil {
-			log.Printf("poller: updating last_checked_at for PR #%d: %v", pr.PRNumber, err)
+			p.prLogf(pr.PRNumber, "", "updating last_checked_at: %v", err)
 		}
 	}
 	return nil
 }
 
+// Forget drops what the poller keeps between cycles about prNumber, once it
+// is no longer tracked.
+func (p *Poller) Forget(prNumber int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.reopenChecked, prNumber)
+	delete(p.checksPassed, prNumber)
+	delete(p.failures, prNumber)
+}
+
+// pollClosed fetches a closed PR once its reopen check is due and moves it
+// back to "open", publishing PRReopened, if it has been reopened. The open
+// PR is polled as usual from the next cycle on.
+func (p *Poller) pollClosed(ctx context.Context, pr db.TrackedPR) error {
+	if p.reopenCheckEvery <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	due := p.now().Sub(p.reopenChecked[pr.PRNumber]) >= p.reopenCheckEvery
+	p.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	info, err := p.gh.GetPR(ctx, pr.PRNumber)
+	if err != nil {
+		p.prLogf(pr.PRNumber, "", "checking closed PR for reopen: %v", err)
+		return err
+	}
+	p.mu.Lock()
+	p.reopenChecked[pr.PRNumber] = p.now()
+	p.mu.Unlock()
+	if info.State != "open" {
+		return nil
+	}
+	p.recordBody(&pr, info)
+
+	if err := p.db.UpdatePRStatus(pr.PRNumber, "open", "", info.Title, info.Author); err != nil {
+		p.prLogf(pr.PRNumber, "", "updating status: %v", err)
+		return nil
+	}
+	p.mu.Lock()
+	delete(p.reopenChecked, pr.PRNumber)
+	p.mu.Unlock()
+	p.prLogf(pr.PRNumber, "", "reopened")
+	p.publish(event.Event{
+		Type:      event.PRReopened,
+		PRNumber:  pr.PRNumber,
+		Title:     info.Title,
+		Author:    info.Author,
+		Body:      p.eventBody(pr.Body),
+		Timestamp: p.now(),
+	})
+	return nil
+}
+
+// budgetExhausted reports whether the cycle has seen as many failures as the
+// error budget allows.
+func (p *Poller) budgetExhausted() bool {
+	return p.errorBudget > 0 && p.cycleErrors >= p.errorBudget
+}
+
+// deferPRs notes the PRs left unpolled when the cycle's deadline passed so
+// the next cycle starts with them. A cancelled (shutting down) cycle is
+// left alone.
+func (p *Poller) deferPRs(ctx context.Context, rest []db.TrackedPR, total int) {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	log.Printf("poller: cycle deadline reached, skipped %d of %d PRs", len(rest), total)
+	for _, pr := range rest {
+		p.resumePRs[pr.PRNumber] = true
+	}
+}
+
+// recordFailure counts a failed poll of pr. When the count reaches the
+// failure threshold it publishes PRError and, if configured, removes the PR;
+// it reports whether the PR was removed.
+func (p *Poller) recordFailure(pr db.TrackedPR, err error) bool {
+	if p.failureThreshold <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	p.failures[pr.PRNumber]++
+	n := p.failures[pr.PRNumber]
+	p.mu.Unlock()
+	if n != p.failureThreshold {
+		return false
+	}
+
+	p.prLogf(pr.PRNumber, "", "failed %d polls in a row: %v", p.failureThreshold, err)
+	p.publish(event.Event{
+		Type:      event.PRError,
+		PRNumber:  pr.PRNumber,
+		Title:     pr.Title,
+		Author:    pr.Author,
+		Body:      p.eventBody(pr.Body),
+		Error:     err.Error(),
+		Timestamp: p.now(),
+	})
+	if !p.removeOnFailure {
+		return false
+	}
+
+	p.mu.Lock()
+	delete(p.failures, pr.PRNumber)
+	p.mu.Unlock()
+	if err := p.db.RemovePR(pr.PRNumber); err != nil {
+		p.prLogf(pr.PRNumber, "", "removing failing PR: %v", err)
+		return false
+	}
+	p.Forget(pr.PRNumber)
+	p.publish(event.Event{
+		Type:      event.PRRemoved,
+		PRNumber:  pr.PRNumber,
+		Title:     pr.Title,
+		Author:    pr.Author,
+		Body:      p.eventBody(pr.Body),
+		Timestamp: p.now(),
+	})
+	return true
+}
+
+// listTrackedPRs reads the tracked PRs, retrying with a linear backoff so a
+// transient DB error (e.g. a busy lock) doesn't skip a whole interval.
+func (p *Poller) listTrackedPRs(ctx context.Context) ([]db.TrackedPR, error) {
+	for attempt := 1; ; attempt++ {
+		prs, err := p.listPRs()
+		if err == nil || attempt == listPRsAttempts {
+			return prs, err
+		}
+		log.Printf("poller: listing PRs (attempt %d/%d): %v", attempt, listPRsAttempts, err)
+		timer := time.NewTimer(time.Duration(attempt) * listPRsRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Refresh polls a single tracked PR immediately. It shares the per-PR
+// in-flight guard with the scheduled poll: if the PR is already being
+// polled, Refresh waits for that run instead of starting another.
+func (p *Poller) Refresh(ctx context.Context, prNumber int) error {
+	if _, err := p.db.GetPR(prNumber); err != nil {
+		return err
+	}
+	return p.pollTracked(ctx, prNumber)
+}
+
+// pollTracked runs pollPR for prNumber unless a poll for the same PR is
+// already in flight, in which case it waits for that one to finish.
+func (p *Poller) pollTracked(ctx context.Context, prNumber int) error {
+	_, err := p.withPR(ctx, prNumber, func(pr db.TrackedPR) error {
+		return p.pollPR(ctx, pr)
+	})
+	return err
+}
+
+// withPR runs fn on tracked PR prNumber under the per-PR in-flight guard.
+// If the PR is already in flight it waits for that run to finish and
+// reports false without calling fn. The PR is re-read after acquiring the
+// guard so fn never acts on state another run has already updated.
+func (p *Poller) withPR(ctx context.Context, prNumber int, fn func(db.TrackedPR) error) (bool, error) {
+	p.mu.Lock()
+	if done, ok := p.inflight[prNumber]; ok {
+		p.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return false, nil
+	}
+	done := make(chan struct{})
+	p.inflight[prNumber] = done
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.inflight, prNumber)
+		p.mu.Unlock()
+		close(done)
+	}()
+
+	pr, err := p.db.GetPR(prNumber)
+	if err != nil {
+		// Removed since the cycle listed it.
+		return true, nil
+	}
+	return true, fn(*pr)
+}
+
+// ApplyPRUpdate records a PR's state pushed by a GitHub webhook, so a merge
+// or close shows up without waiting for the next poll. Only open tracked
+// PRs are updated; landings are still found by polling. It waits out a
+// poll of the same PR that is in flight and then applies info on top.
+func (p *Poller) ApplyPRUpdate(ctx context.Context, info *github.PRInfo) error {
+	if _, err := p.db.GetPR(info.Number); err != nil {
+		return err
+	}
+	for {
+		ran, err := p.withPR(ctx, info.Number, func(pr db.TrackedPR) error {
+			if pr.Status == "open" {
+				p.applyPRInfo(&pr, info)
+			}
+			return nil
+		})
+		if ran || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
 func (p *Poller) pollPR(ctx context.Context, pr db.TrackedPR) error {
+	if pr.Status == "landed" {
+		// Nothing left to check on GitHub; the PR only waits out the
+		// retention window. updated_at is when it was marked landed.
+		if p.now().Sub(pr.UpdatedAt) >= p.landedRetention {
+			p.prLogf(pr.PRNumber, "", "landed retention of %s over, removing", p.landedRetention)
+			p.removePR(pr)
+		}
+		return nil
+	}
+
+	if pr.Status == "closed" {
+		return p.pollClosed(ctx, pr)
+	}
+
 	if pr.Status == "open" {
 		info, err := p.gh.GetPR(ctx, pr.PRNumber)
 		if err != nil {
-			log.Printf("poller: fetching PR #%d: %v", pr.PRNumber, err)
+			p.prLogf(pr.PRNumber, "", "fetching PR: %v", err)
 			return err
 		}
 
-		if info.Merged {
-			if err := p.db.UpdatePRStatus(pr.PRNumber, "merged", info.MergeCommit, info.Title, info.Author); err != nil {
-				log.Printf("poller: updating PR #%d status: %v", pr.PRNumber, err)
-				return nil
-			}
-			p.bus.Publish(event.Event{
-				Type:      event.PRMerged,
-				PRNumber:  pr.PRNumber,
-				Title:     info.Title,
-				Author:    info.Author,
-				Timestamp: time.Now(),
-			})
-			pr.Status = "merged"
-			pr.MergeCommit = info.MergeCommit
-			pr.Title = info.Title
-			pr.Author = info.Author
-		} else if info.State == "closed" {
-			if err := p.db.UpdatePRStatus(pr.PRNumber, "closed", "", info.Title, info.Author); err != nil {
-				log.Printf("poller: updating PR #%d status: %v", pr.PRNumber, err)
-			}
-			return nil
-		} else {
-			// Still open, update title/author
-			if err := p.db.UpdatePRStatus(pr.PRNumber, "open", "", info.Title, info.Author); err != nil {
-				log.Printf("poller: updating PR #%d info: %v", pr.PRNumber, err)
+		if !p.applyPRInfo(&pr, info) {
+			if info.State == "open" && p.notifyChecks {
+				return p.pollChecks(ctx, info)
 			}
 			return nil
 		}
 	}
 
 	if pr.Status == "merged" && pr.MergeCommit != "" {
+		notificationBranches, targetBranches := p.branches()
 		landedBranches := make(map[string]bool)
 		for _, bs := range pr.Branches {
 			if bs.Landed {
@@ -150,71 +1067,657 @@ func (p *Poller) pollPR(ctx context.Context, pr db.TrackedPR) error {
 			}
 		}
 
-		for _, branch := range p.notificationBranches {
-			if landedBranches[branch] {
+		// Skip checking a branch if a downstream branch has already landed.
+		// e.g. if master landed, skip staging and staging-next.
+		var toCheck []string
+		for _, branch := range notificationBranches {
+			if !landedBranches[branch] && !p.downstreamLanded(branch, landedBranches) {
+				toCheck = append(toCheck, branch)
+			}
+		}
+		if len(toCheck) > 0 {
+			p.recordMergedAt(ctx, &pr)
+		}
+		checks := p.checkBranches(ctx, pr, toCheck)
+
+		// Apply the results in branch order, as a serial loop would have.
+		// timedOut holds the last compare that hit the compare timeout; the
+		// PR's poll still reports it once the other branches are checked.
+		var timedOut error
+		var grouped []string  // landings held back for one event with WithGroupedLandings
+		var commentable *bool // whether to comment on landings, fetched on the first one
+		for i, branch := range toCheck {
+			// A branch checked earlier in this loop may have landed
+			// downstream of this one.
+			if p.downstreamLanded(branch, landedBranches) {
 				continue
 			}
 
-			// Skip checking a branch if a downstream branch has already landed.
-			// e.g. if master landed, skip staging and staging-next.
-			skipUpstream := false
-			for downstream := range landedBranches {
-				if topology.IsUpstreamOf(branch, downstream) {
-					skipUpstream = true
-					break
+			c := checks[i]
+			if c.notYetIn != "" {
+				p.prLogf(pr.PRNumber, branch, "not checked, commit %s not yet in %s", pr.MergeCommit, c.notYetIn)
+				continue
+			}
+			if c.err != nil {
+				if p.compareTimedOut(ctx, c.err) {
+					p.prLogf(pr.PRNumber, branch, "compare of %s timed out after %s, moving on", pr.MergeCommit, p.compareTimeout)
+					timedOut = c.err
+					continue
 				}
+				if c.canceled(ctx) {
+					continue
+				}
+				if errors.Is(c.err, github.ErrNotFound) {
+					p.explainCompareNotFound(ctx, fmt.Sprintf("pr=%d branch=%s", pr.PRNumber, branch), pr.MergeCommit, branch)
+				} else {
+					p.prLogf(pr.PRNumber, branch, "checking commit %s: %v", pr.MergeCommit, c.err)
+				}
+				p.publishLandings(pr, grouped)
+				return c.err
 			}
-			if skipUpstream {
-				continue
+			if err := p.db.UpdateBranchLastStatus(pr.PRNumber, branch, c.status); err != nil {
+				p.prLogf(pr.PRNumber, branch, "recording compare status: %v", err)
 			}
-
-			inBranch, err := p.gh.IsCommitInBranch(ctx, pr.MergeCommit, branch)
-			if err != nil {
-				log.Printf("poller: checking PR #%d commit %s in %s: %v", pr.PRNumber, pr.MergeCommit, branch, err)
-				return err
+			if c.historyErr != nil {
+				if errors.Is(c.historyErr, context.Canceled) && ctx.Err() == nil {
+					continue
+				}
+				p.prLogf(pr.PRNumber, branch, "checking history: %v", c.historyErr)
+				p.publishLandings(pr, grouped)
+				return c.historyErr
+			}
+			if c.inHistory {
+				p.prLogf(pr.PRNumber, branch, "found in history by commit message")
 			}
 
-			if inBranch {
-				log.Printf("poller: PR #%d commit %s found in %s", pr.PRNumber, pr.MergeCommit, branch)
+			if c.inBranch || c.inHistory {
+				p.prLogf(pr.PRNumber, branch, "commit %s found", pr.MergeCommit)
 				if err := p.db.UpdateBranchLanded(pr.PRNumber, branch); err != nil {
-					log.Printf("poller: updating branch status for PR #%d: %v", pr.PRNumber, err)
+					p.prLogf(pr.PRNumber, branch, "updating branch status: %v", err)
 					continue
 				}
-				p.bus.Publish(event.Event{
-					Type:      event.PRLandedBranch,
-					PRNumber:  pr.PRNumber,
-					Title:     pr.Title,
-					Author:    pr.Author,
-					Branch:    branch,
-					Timestamp: time.Now(),
-				})
+				if p.groupLandings {
+					grouped = append(grouped, branch)
+				} else {
+					p.publishLandings(pr, []string{branch})
+				}
 				landedBranches[branch] = true
+				if p.commentOnLand {
+					if commentable == nil {
+						ok := p.commentable(ctx, pr.PRNumber)
+						commentable = &ok
+					}
+					if *commentable {
+						p.commentLanded(ctx, pr.PRNumber, branch)
+					}
+				}
 			} else {
-				log.Printf("poller: PR #%d commit %s not yet in %s", pr.PRNumber, pr.MergeCommit, branch)
+				p.prLogf(pr.PRNumber, branch, "commit %s not yet landed (%s)", pr.MergeCommit, c.status)
 			}
 		}
+		p.publishLandings(pr, grouped)
 
 		// Remove PR once it has landed in all target branches
 		allLanded := true
-		for _, branch := range p.targetBranches {
+		for _, branch := range targetBranches {
 			if !landedBranches[branch] {
 				allLanded = false
 				break
 			}
 		}
 		if allLanded {
-			log.Printf("PR #%d has landed in all branches, removing", pr.PRNumber)
-			if err := p.db.RemovePR(pr.PRNumber); err != nil {
-				log.Printf("poller: removing PR #%d: %v", pr.PRNumber, err)
+			if p.landedRetention > 0 {
+				p.prLogf(pr.PRNumber, "", "landed in all branches, keeping for %s", p.landedRetention)
+			} else {
+				p.prLogf(pr.PRNumber, "", "landed in all branches, removing")
 			}
-			p.bus.Publish(event.Event{
-				Type:      event.PRRemoved,
+			var landed []string
+			for _, branch := range notificationBranches {
+				if landedBranches[branch] {
+					landed = append(landed, branch)
+				}
+			}
+			p.publish(event.Event{
+				Type:      event.PRFullyLanded,
 				PRNumber:  pr.PRNumber,
 				Title:     pr.Title,
 				Author:    pr.Author,
-				Timestamp: time.Now(),
+				Body:      p.eventBody(pr.Body),
+				Branches:  landed,
+				Timestamp: p.now(),
+			})
+			if p.landedRetention > 0 {
+				if err := p.db.UpdatePRStatus(pr.PRNumber, "landed", pr.MergeCommit, pr.Title, pr.Author); err != nil {
+					p.prLogf(pr.PRNumber, "", "updating status: %v", err)
+				}
+				return nil
+			}
+			p.removePR(pr)
+			return nil
+		}
+		if timedOut != nil {
+			return fmt.Errorf("%w: %w", github.ErrTransient, timedOut)
+		}
+	}
+	return nil
+}
+
+// downstreamLanded reports whether a branch downstream of branch is among
+// the landed ones.
+func (p *Poller) downstreamLanded(branch string, landed map[string]bool) bool {
+	for downstream := range landed {
+		if p.isUpstreamOf(branch, downstream) {
+			return true
+		}
+	}
+	return false
+}
+
+// branchCheck is the outcome of looking for a merged PR's merge commit in
+// one branch.
+type branchCheck struct {
+	status     string
+	err        error  // from the compare
+	inBranch   bool   // status counts as landed
+	inHistory  bool   // found by the commit-message fallback instead
+	historyErr error  // from the commit-message fallback
+	notYetIn   string // the upstream branch still missing the PR, if not checked
+}
+
+// missing reports whether the check found the PR not landed, or wasn't run
+// because an upstream branch didn't have it either.
+func (c branchCheck) missing() bool {
+	return c.err == nil && c.historyErr == nil && !c.inBranch && !c.inHistory
+}
+
+// canceled reports whether the check was cut off because a sibling check
+// failed, rather than by ctx.
+func (c branchCheck) canceled(ctx context.Context) bool {
+	return errors.Is(c.err, context.Canceled) && ctx.Err() == nil
+}
+
+// checkBranches looks for pr's merge commit in each of branches, running up
+// to WithBranchConcurrency checks at once. A check waits for those of the
+// branches before it that gate it (see gates) and is skipped if one of them
+// doesn't have the PR yet, so no request is spent on a branch the PR can't
+// have reached. A failure other than a compare timeout, such as hitting the
+// rate limit, cancels the checks still running. Results are in the order of
+// branches so the caller can apply them deterministically; it does all the
+// database writes.
+func (p *Poller) checkBranches(ctx context.Context, pr db.TrackedPR, branches []string) []branchCheck {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	checks := make([]branchCheck, len(branches))
+	done := make([]chan struct{}, len(branches))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	sem := make(chan struct{}, p.branchConcurrency)
+	var wg sync.WaitGroup
+	for i, branch := range branches {
+		// Acquire before starting the goroutine so checks start in branch
+		// order, and a gating check always holds a slot before the checks
+		// waiting on it.
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer close(done[i])
+
+			c := &checks[i]
+			for j, upstream := range branches[:i] {
+				if !p.gates(pr, upstream, branch) {
+					continue
+				}
+				<-done[j]
+				if checks[j].missing() {
+					c.notYetIn = upstream
+					return
+				}
+			}
+			if c.err = ctx.Err(); c.err != nil {
+				return
+			}
+			c.status, c.err = p.compareStatus(ctx, pr.MergeCommit, branch)
+			if c.err != nil {
+				if !p.compareTimedOut(ctx, c.err) {
+					cancel()
+				}
+				return
+			}
+			c.inBranch = p.gh.IsLandedStatus(c.status)
+			if !c.inBranch && p.landingFallbackDue(pr) {
+				c.inHistory, c.historyErr = p.gh.IsPRInBranchHistory(ctx, pr.PRNumber, pr.Title, pr.MergedAt, branch)
+				if c.historyErr != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return checks
+}
+
+// landingFallbackDue reports whether pr has waited long enough for its
+// merge commit that WithLandingFallback searches branch history instead.
+func (p *Poller) landingFallbackDue(pr db.TrackedPR) bool {
+	return p.landingFallbackAfter > 0 && p.now().Sub(pr.UpdatedAt) >= p.landingFallbackAfter
+}
+
+// recordMergedAt fetches and records when pr was merged if the landing
+// fallback is about to search branch history for it and the time isn't
+// known yet, as for PRs tracked before merge times were kept. Failures are
+// only logged; the search then covers the branch's newest commits.
+func (p *Poller) recordMergedAt(ctx context.Context, pr *db.TrackedPR) {
+	if !pr.MergedAt.IsZero() || !p.landingFallbackDue(*pr) {
+		return
+	}
+	info, err := p.gh.GetPR(ctx, pr.PRNumber)
+	if err != nil {
+		p.prLogf(pr.PRNumber, "", "fetching merge time: %v", err)
+		return
+	}
+	if info.MergedAt.IsZero() {
+		return
+	}
+	if err := p.db.UpdatePRMergedAt(pr.PRNumber, info.MergedAt); err != nil {
+		p.prLogf(pr.PRNumber, "", "updating merge time: %v", err)
+	}
+	pr.MergedAt = info.MergedAt
+}
+
+// gates reports whether pr not having landed in upstream means it can't
+// have landed in branch yet. That holds for branches downstream of
+// upstream, as long as upstream is the PR's base or downstream of it: a PR
+// merged into master never passes through staging.
+func (p *Poller) gates(pr db.TrackedPR, upstream, branch string) bool {
+	if pr.BaseRef == "" || !p.isUpstreamOf(upstream, branch) {
+		return false
+	}
+	return upstream == pr.BaseRef || p.isUpstreamOf(pr.BaseRef, upstream)
+}
+
+// applyPRInfo records info fetched for an open PR: a changed base, title
+// or author, and a merge or close. A merge publishes PRMerged. It reports
+// whether pr is now merged and updated to match, so its landings can be
+// checked.
+func (p *Poller) applyPRInfo(pr *db.TrackedPR, info *github.PRInfo) bool {
+	if info.BaseRef != "" && info.BaseRef != pr.BaseRef {
+		if err := p.db.UpdatePRBase(pr.PRNumber, info.BaseRef); err != nil {
+			p.prLogf(pr.PRNumber, "", "updating base: %v", err)
+		}
+	}
+	p.recordBody(pr, info)
+
+	switch {
+	case info.Merged:
+		if topology.IsStagingBranch(info.BaseRef) {
+			p.prLogf(pr.PRNumber, "", "merged into %s; it reaches master only with the next staging-next merge", info.BaseRef)
+		}
+		if err := p.db.UpdatePRStatus(pr.PRNumber, "merged", info.MergeCommit, info.Title, info.Author); err != nil {
+			p.prLogf(pr.PRNumber, "", "updating status: %v", err)
+			return false
+		}
+		if !info.MergedAt.IsZero() {
+			if err := p.db.UpdatePRMergedAt(pr.PRNumber, info.MergedAt); err != nil {
+				p.prLogf(pr.PRNumber, "", "updating merge time: %v", err)
+			}
+			pr.MergedAt = info.MergedAt
+		}
+		p.publish(event.Event{
+			Type:      event.PRMerged,
+			PRNumber:  pr.PRNumber,
+			Title:     info.Title,
+			Author:    info.Author,
+			Body:      p.eventBody(pr.Body),
+			Timestamp: p.now(),
+		})
+		pr.Status = "merged"
+		pr.MergeCommit = info.MergeCommit
+		pr.Title = info.Title
+		pr.Author = info.Author
+		return true
+	case info.State == "closed":
+		if err := p.db.UpdatePRStatus(pr.PRNumber, "closed", "", info.Title, info.Author); err != nil {
+			p.prLogf(pr.PRNumber, "", "updating status: %v", err)
+		}
+	default:
+		// Still open; write only if the title or author changed so that
+		// updated_at reflects real changes.
+		if info.Title != pr.Title || info.Author != pr.Author {
+			if err := p.db.UpdatePRStatus(pr.PRNumber, "open", "", info.Title, info.Author); err != nil {
+				p.prLogf(pr.PRNumber, "", "updating info: %v", err)
+			}
+		}
+	}
+	return false
+}
+
+// publishLandings publishes PRLandedBranch for pr having landed in
+// branches. Branch is the last of them; Branches lists them all when there
+// is more than one.
+func (p *Poller) publishLandings(pr db.TrackedPR, branches []string) {
+	if len(branches) == 0 {
+		return
+	}
+	e := event.Event{
+		Type:      event.PRLandedBranch,
+		PRNumber:  pr.PRNumber,
+		Title:     pr.Title,
+		Author:    pr.Author,
+		Body:      p.eventBody(pr.Body),
+		Branch:    branches[len(branches)-1],
+		Timestamp: p.now(),
+	}
+	if len(branches) > 1 {
+		e.Branches = branches
+	}
+	p.publish(e)
+}
+
+// commentable reports whether landings may be commented on the PR, which
+// they may not once its conversation is locked. A failed fetch counts as
+// no, since the landing is already recorded either way.
+func (p *Poller) commentable(ctx context.Context, prNumber int) bool {
+	info, err := p.gh.GetPR(ctx, prNumber)
+	if err != nil {
+		p.prLogf(prNumber, "", "fetching PR before commenting: %v", err)
+		return false
+	}
+	if info.Locked {
+		p.prLogf(prNumber, "", "not commenting on landings: conversation is locked")
+		return false
+	}
+	return true
+}
+
+// commentLanded comments on the PR that it has landed in branch. Comment
+// failures are only logged: the landing is already recorded.
+func (p *Poller) commentLanded(ctx context.Context, prNumber int, branch string) {
+	body := fmt.Sprintf("Landed in %s as of %s.", branch, p.now().UTC().Format("2006-01-02"))
+	if err := p.gh.CommentOnPR(ctx, prNumber, body); err != nil {
+		p.prLogf(prNumber, branch, "commenting on landing: %v", err)
+	}
+}
+
+// recordBody stores an excerpt of the PR description from info when it has
+// changed and WithPRBody is set, and updates pr to match.
+func (p *Poller) recordBody(pr *db.TrackedPR, info *github.PRInfo) {
+	if !p.includeBody {
+		return
+	}
+	body := event.Excerpt(info.Body)
+	if body == pr.Body {
+		return
+	}
+	if err := p.db.UpdatePRBody(pr.PRNumber, body); err != nil {
+		p.prLogf(pr.PRNumber, "", "updating body: %v", err)
+		return
+	}
+	pr.Body = body
+}
+
+// eventBody returns body for an event's Body, or nothing without
+// WithPRBody.
+func (p *Poller) eventBody(body string) string {
+	if !p.includeBody {
+		return ""
+	}
+	return body
+}
+
+// isUpstreamOf reports whether a landing reaches candidate before branch,
+// either in the built-in topology or as an earlier configured stage.
+func (p *Poller) isUpstreamOf(candidate, branch string) bool {
+	if topology.IsUpstreamOf(candidate, branch) {
+		return true
+	}
+	i, j := slices.Index(p.stages, candidate), slices.Index(p.stages, branch)
+	return i >= 0 && j >= 0 && i < j
+}
+
+// removePR stops tracking a PR that is done and publishes PRRemoved.
+func (p *Poller) removePR(pr db.TrackedPR) {
+	if err := p.db.RemovePR(pr.PRNumber); err != nil {
+		p.prLogf(pr.PRNumber, "", "removing: %v", err)
+	}
+	p.Forget(pr.PRNumber)
+	p.publish(event.Event{
+		Type:      event.PRRemoved,
+		PRNumber:  pr.PRNumber,
+		Title:     pr.Title,
+		Author:    pr.Author,
+		Body:      p.eventBody(pr.Body),
+		Timestamp: p.now(),
+	})
+}
+
+// prLogf logs a line about one PR, prefixed with pr=<n> and, when set,
+// repo=<owner/name> and branch=<b>, so all of a PR's lines in a cycle can be
+// grepped for. The primary repository's lines carry no repo.
+func (p *Poller) prLogf(prNumber int, branch, format string, args ...any) {
+	prefix := "poller:"
+	if repo := p.db.Repo(); repo != "" {
+		prefix += " repo=" + repo
+	}
+	prefix += fmt.Sprintf(" pr=%d", prNumber)
+	if branch != "" {
+		prefix += " branch=" + branch
+	}
+	log.Print(prefix + ": " + fmt.Sprintf(format, args...))
+}
+
+// InBranch reports whether sha has landed in branch, either by comparing it
+// with the branch head or, with WithChannelRevision, with the branch's
+// channel revision.
+func (p *Poller) InBranch(ctx context.Context, sha, branch string) (bool, error) {
+	status, err := p.compareStatus(ctx, sha, branch)
+	if err != nil {
+		return false, err
+	}
+	return p.gh.IsLandedStatus(status), nil
+}
+
+// compareStatus returns the compare status of sha against branch, or against
+// the branch's channel revision with WithChannelRevision.
+func (p *Poller) compareStatus(ctx context.Context, sha, branch string) (string, error) {
+	if p.compareTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.compareTimeout)
+		defer cancel()
+	}
+	if p.channelRevisionURL != "" {
+		revURL := strings.ReplaceAll(p.channelRevisionURL, "{branch}", url.PathEscape(branch))
+		rev, err := p.gh.ChannelRevision(ctx, revURL)
+		if err == nil {
+			return p.gh.CompareStatus(ctx, sha, rev)
+		}
+		if !errors.Is(err, github.ErrNotFound) {
+			return "", err
+		}
+		// Not a channel (e.g. staging); fall through to the branch itself.
+	}
+	return p.gh.CompareStatus(ctx, sha, branch)
+}
+
+// compareTimedOut reports whether err is a compare cut off by the compare
+// timeout rather than by the cycle's own deadline or cancellation.
+func (p *Poller) compareTimedOut(ctx context.Context, err error) bool {
+	return p.compareTimeout > 0 && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded)
+}
+
+// explainCompareNotFound logs why a compare of sha against branch returned
+// 404, which GitHub uses both for a missing branch and an unknown commit.
+func (p *Poller) explainCompareNotFound(ctx context.Context, subject, sha, branch string) {
+	exists, err := p.gh.BranchExists(ctx, branch)
+	switch {
+	case err != nil:
+		log.Printf("poller: %s: compare of %s against %s returned 404 (could not check the branch: %v)", subject, sha, branch, err)
+	case !exists:
+		log.Printf("poller: %s: branch %s does not exist", subject, branch)
+	default:
+		log.Printf("poller: %s: commit %s not found", subject, sha)
+	}
+}
+
+// pollChecks publishes PRChecksPassed the first time every check run on the
+// open PR's head commit has succeeded. A new push (new head SHA) re-arms it.
+func (p *Poller) pollChecks(ctx context.Context, info *github.PRInfo) error {
+	if info.HeadSHA == "" {
+		return nil
+	}
+	p.mu.Lock()
+	notified := p.checksPassed[info.Number] == info.HeadSHA
+	p.mu.Unlock()
+	if notified {
+		return nil
+	}
+
+	runs, err := p.gh.GetCheckRuns(ctx, info.HeadSHA)
+	if err != nil {
+		p.prLogf(info.Number, "", "fetching check runs: %v", err)
+		return err
+	}
+	if !github.AllChecksPassed(runs) {
+		return nil
+	}
+
+	p.mu.Lock()
+	p.checksPassed[info.Number] = info.HeadSHA
+	p.mu.Unlock()
+	p.prLogf(info.Number, "", "all %d checks passed on %s", len(runs), info.HeadSHA)
+	p.publish(event.Event{
+		Type:      event.PRChecksPassed,
+		PRNumber:  info.Number,
+		Title:     info.Title,
+		Author:    info.Author,
+		Body:      p.eventBody(event.Excerpt(info.Body)),
+		Commit:    info.HeadSHA,
+		Timestamp: p.now(),
+	})
+	return nil
+}
+
+func (p *Poller) pollCommits(ctx context.Context) *github.RateLimitError {
+	commits, err := p.db.ListCommits()
+	if err != nil {
+		log.Printf("poller: listing commits: %v", err)
+		return nil
+	}
+
+	for i, c := range commits {
+		if ctx.Err() != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Printf("poller: cycle deadline reached, skipped %d of %d commits", len(commits)-i, len(commits))
+			}
+			return nil
+		}
+		if p.budgetExhausted() {
+			log.Printf("poller: error budget of %d failures used up, skipping %d of %d commits until next cycle", p.errorBudget, len(commits)-i, len(commits))
+			return nil
+		}
+		if p.rateReserve > 0 {
+			if rl, ok := p.gh.RateLimit(); ok && rl.Remaining < p.rateReserve {
+				log.Printf("poller: %d GitHub requests left, below the reserve of %d; skipping %d of %d commits until next cycle", rl.Remaining, p.rateReserve, len(commits)-i, len(commits))
+				return nil
+			}
+		}
+		if err := p.pollCommit(ctx, c); err != nil {
+			var rlErr *github.RateLimitError
+			if errors.As(err, &rlErr) {
+				log.Printf("poller: rate limited, resets at %s, skipping remaining commits", rlErr.RetryAfter.Format("15:04:05"))
+				return rlErr
+			}
+			p.cycleErrors++
+		}
+		if err := p.db.UpdateCommitLastChecked(c.SHA); err != nil {
+			log.Printf("poller: updating last_checked_at for commit %s: %v", c.SHA, err)
+		}
+	}
+	return nil
+}
+
+// pollCommit checks a bare tracked commit against each notification branch,
+// mirroring the landing logic for merged PRs.
+func (p *Poller) pollCommit(ctx context.Context, c db.TrackedCommit) error {
+	notificationBranches, targetBranches := p.branches()
+	landedBranches := make(map[string]bool)
+	for _, bs := range c.Branches {
+		if bs.Landed {
+			landedBranches[bs.Branch] = true
+		}
+	}
+
+	var timedOut error
+	for _, branch := range notificationBranches {
+		if landedBranches[branch] {
+			continue
+		}
+
+		skipUpstream := false
+		for downstream := range landedBranches {
+			if p.isUpstreamOf(branch, downstream) {
+				skipUpstream = true
+				break
+			}
+		}
+		if skipUpstream {
+			continue
+		}
+
+		inBranch, err := p.InBranch(ctx, c.SHA, branch)
+		if err != nil {
+			if p.compareTimedOut(ctx, err) {
+				log.Printf("poller: commit %s: compare against %s timed out after %s, moving on", c.SHA, branch, p.compareTimeout)
+				timedOut = err
+				continue
+			}
+			if errors.Is(err, github.ErrNotFound) {
+				p.explainCompareNotFound(ctx, "commit "+c.SHA, c.SHA, branch)
+			} else {
+				log.Printf("poller: checking commit %s in %s: %v", c.SHA, branch, err)
+			}
+			return err
+		}
+
+		if inBranch {
+			log.Printf("poller: commit %s found in %s", c.SHA, branch)
+			if err := p.db.UpdateCommitBranchLanded(c.SHA, branch); err != nil {
+				log.Printf("poller: updating branch status for commit %s: %v", c.SHA, err)
+				continue
+			}
+			p.publish(event.Event{
+				Type:      event.CommitLandedBranch,
+				Title:     c.Title,
+				Branch:    branch,
+				Commit:    c.SHA,
+				Timestamp: p.now(),
 			})
+			landedBranches[branch] = true
+		} else {
+			log.Printf("poller: commit %s not yet in %s", c.SHA, branch)
 		}
 	}
+
+	if timedOut != nil {
+		return fmt.Errorf("%w: %w", github.ErrTransient, timedOut)
+	}
+	for _, branch := range targetBranches {
+		if !landedBranches[branch] {
+			return nil
+		}
+	}
+	log.Printf("commit %s has landed in all branches, removing", c.SHA)
+	if err := p.db.RemoveCommit(c.SHA); err != nil {
+		log.Printf("poller: removing commit %s: %v", c.SHA, err)
+	}
+	p.publish(event.Event{
+		Type:      event.CommitRemoved,
+		Title:     c.Title,
+		Commit:    c.SHA,
+		Timestamp: p.now(),
+	})
 	return nil
 }
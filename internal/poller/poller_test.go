@@ -1,11 +1,21 @@
 package poller
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -87,6 +97,49 @@ func TestPollOpenStaysOpen(t *testing.T) {
 	}
 }
 
+func TestPollOpenUnchangedSkipsWrite(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(1)
+	env.db.UpdatePRStatus(1, "open", "", "Still Open", "alice")
+
+	// Backdate updated_at through a second connection to the shared
+	// in-memory DB, so any write in this cycle would be visible.
+	raw, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("opening DB: %v", err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(`UPDATE tracked_prs SET updated_at = '2020-01-01 00:00:00' WHERE pr_number = 1`); err != nil {
+		t.Fatalf("backdating updated_at: %v", err)
+	}
+
+	title := "Still Open"
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 1, "title": title, "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	env.p.poll(context.Background())
+
+	pr, _ := env.db.GetPR(1)
+	want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !pr.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want unchanged %v", pr.UpdatedAt, want)
+	}
+
+	// A retitled PR is written.
+	title = "Retitled"
+	env.p.poll(context.Background())
+
+	pr, _ = env.db.GetPR(1)
+	if pr.Title != "Retitled" || pr.UpdatedAt.Equal(want) {
+		t.Errorf("after retitle: Title = %q, UpdatedAt = %v; want new title and a fresh updated_at", pr.Title, pr.UpdatedAt)
+	}
+}
+
 func TestPollOpenToMerged(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
 
@@ -130,421 +183,2667 @@ func TestPollOpenToMerged(t *testing.T) {
 	}
 }
 
-func TestPollOpenToClosed(t *testing.T) {
+// Events carry the poller's clock, so they line up with the times it
+// records and tests can pin them.
+func TestPollEventTimestampsUseClock(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
+	clock := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return clock }
 
-	env.db.AddPR(3)
-
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+	env.db.AddPR(2)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/2", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{
-			"number": 3, "title": "Closed", "user": map[string]any{"login": "carol"},
-			"state": "closed", "merged": false,
+			"number": 2, "title": "Now Merged", "user": map[string]any{"login": "bob"},
+			"state": "closed", "merged": true, "merge_commit_sha": "mergesha",
 		})
 	})
-
-	env.p.poll(context.Background())
-
-	pr, _ := env.db.GetPR(3)
-	if pr.Status != "closed" {
-		t.Errorf("Status = %q, want %q", pr.Status, "closed")
-	}
-}
-
-func TestPollMergedChecksBranches(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable"})
-
-	env.db.AddPR(4)
-	env.db.UpdatePRStatus(4, "merged", "commitABC", "Merged PR", "dave")
-
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitABC", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // landed
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...mergesha", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
 	})
 
-	var mu sync.Mutex
 	var events []event.Event
-	env.bus.Subscribe(func(e event.Event) {
-		mu.Lock()
-		events = append(events, e)
-		mu.Unlock()
-	})
+	env.bus.Subscribe(func(e event.Event) { events = append(events, e) })
 
 	env.p.poll(context.Background())
 
-	mu.Lock()
-	defer mu.Unlock()
-	types := make(map[event.Type]bool)
+	var types []event.Type
 	for _, e := range events {
-		types[e.Type] = true
-	}
-	if !types[event.PRLandedBranch] {
-		t.Error("missing PRLandedBranch event")
-	}
-	if !types[event.PRRemoved] {
-		t.Error("missing PRRemoved event (auto-remove)")
+		types = append(types, e.Type)
+		if !e.Timestamp.Equal(clock) {
+			t.Errorf("%s Timestamp = %v, want the poller's clock %v", e.Type, e.Timestamp, clock)
+		}
 	}
-
-	// PR should be removed
-	_, err := env.db.GetPR(4)
-	if err == nil {
-		t.Error("expected PR to be auto-removed")
+	want := []event.Type{event.PRMerged, event.PRLandedBranch, event.PRFullyLanded, event.PRRemoved}
+	if !slices.Equal(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
 	}
 }
 
-func TestPollNotYetLanded(t *testing.T) {
+func TestPollRecordsBase(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
 
-	env.db.AddPR(5)
-	env.db.UpdatePRStatus(5, "merged", "commitDEF", "Not Landed", "eve")
+	env.db.AddPR(3)
 
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitDEF", func(w http.ResponseWriter, r *http.Request) {
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 3, "title": "Mass rebuild", "user": map[string]any{"login": "bob"},
+			"state": "closed", "merged": true, "merge_commit_sha": "stagingsha",
+			"base": map[string]any{"ref": "staging"},
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...stagingsha", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
 	})
 
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
 	env.p.poll(context.Background())
 
-	// PR should still exist
-	pr, err := env.db.GetPR(5)
-	if err != nil {
-		t.Fatalf("expected PR to still exist: %v", err)
+	pr, _ := env.db.GetPR(3)
+	if pr.BaseRef != "staging" {
+		t.Errorf("BaseRef = %q, want %q", pr.BaseRef, "staging")
 	}
-	if pr.Status != "merged" {
-		t.Errorf("Status = %q, want %q", pr.Status, "merged")
+	if !strings.Contains(logs.String(), "pr=3: merged into staging") {
+		t.Errorf("expected a staging merge note in logs, got:\n%s", logs.String())
 	}
 }
 
-func TestPollSkipAlreadyLanded(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable", "nixos-24.11"})
-
-	env.db.AddPR(6)
-	env.db.UpdatePRStatus(6, "merged", "commitGHI", "Partial Landed", "frank")
-	env.db.UpdateBranchLanded(6, "nixos-unstable") // already landed
+func TestPollMergedPublishedOnce(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
 
-	var compareCount int
-	var compareMu sync.Mutex
+	env.db.AddPR(3)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 3, "title": "Merge Once", "user": map[string]any{"login": "bob"},
+			"state": "closed", "merged": true, "merge_commit_sha": "sha3",
+		})
+	})
 	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
-		compareMu.Lock()
-		compareCount++
-		compareMu.Unlock()
-		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // nixos-24.11 not landed
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed, so the PR stays tracked
+	})
+
+	var merged []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRMerged {
+			merged = append(merged, e)
+		}
 	})
 
+	env.p.poll(context.Background())
 	env.p.poll(context.Background())
 
-	compareMu.Lock()
-	defer compareMu.Unlock()
-	// Should only check nixos-24.11 (skip nixos-unstable)
-	if compareCount != 1 {
-		t.Errorf("compare API calls = %d, want 1 (skipping already landed)", compareCount)
+	if len(merged) != 1 {
+		t.Fatalf("got %d PRMerged events over two cycles, want 1", len(merged))
+	}
+	if merged[0].PRNumber != 3 || merged[0].Title != "Merge Once" {
+		t.Errorf("PRMerged = %+v, want PR 3 \"Merge Once\"", merged[0])
 	}
 }
 
-func TestPollAllLandedAutoRemoves(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable", "nixos-24.11"})
+func TestPollChannelRevisionConfirmsLanding(t *testing.T) {
+	// staging goes first; it is upstream of nixos-unstable and would be
+	// skipped once that has landed.
+	env := setupPoller(t, []string{"staging", "nixos-unstable"})
+	env.p = New(env.db, env.gh, env.bus, time.Hour, []string{"staging", "nixos-unstable"}, []string{"staging", "nixos-unstable"},
+		WithChannelRevision(env.gh.BaseURL+"/channels/{branch}/git-revision"))
 
-	env.db.AddPR(7)
-	env.db.UpdatePRStatus(7, "merged", "commitJKL", "All Landing", "grace")
+	env.db.AddPR(5)
+	env.db.UpdatePRStatus(5, "merged", "sha5", "Channel PR", "erin")
 
+	env.ghMux.HandleFunc("/channels/nixos-unstable/git-revision", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "chanrev")
+	})
+	env.ghMux.HandleFunc("/channels/", http.NotFound) // staging has no channel
+	var compared []string
 	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // both landed
+		compared = append(compared, strings.TrimPrefix(r.URL.Path, "/repos/NixOS/nixpkgs/compare/"))
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	var landed []string
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRLandedBranch {
+			landed = append(landed, e.Branch)
+		}
 	})
 
 	env.p.poll(context.Background())
 
-	_, err := env.db.GetPR(7)
-	if err == nil {
-		t.Error("expected PR to be auto-removed after landing in all branches")
+	if got := strings.Join(compared, ","); got != "staging...sha5,chanrev...sha5" {
+		t.Errorf("compared %q, want the channel revision for nixos-unstable and the branch for staging", got)
+	}
+	if got := strings.Join(landed, ","); got != "staging,nixos-unstable" {
+		t.Errorf("landed in %q, want staging,nixos-unstable", got)
 	}
 }
 
-func TestPollPartialLanding(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable", "nixos-24.11"})
+func TestPollPRBody(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	WithPRBody()(env.p)
 
-	env.db.AddPR(8)
-	env.db.UpdatePRStatus(8, "merged", "commitMNO", "Partial", "heidi")
+	env.db.AddPR(2)
 
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitMNO", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // landed
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/2", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 2, "title": "foo: 1 -> 2", "user": map[string]any{"login": "bob"},
+			"body": "Changelog: https://example.com\n", "state": "closed", "merged": true, "merge_commit_sha": "sha2",
+		})
 	})
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-24.11...commitMNO", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha2", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
 	})
 
-	env.p.poll(context.Background())
+	var merged event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRMerged {
+			merged = e
+		}
+	})
 
-	pr, err := env.db.GetPR(8)
-	if err != nil {
-		t.Fatalf("expected PR to still exist: %v", err)
-	}
+	env.p.poll(context.Background())
 
-	statuses, _ := env.db.GetBranchStatus(8)
-	landed := make(map[string]bool)
-	for _, s := range statuses {
-		landed[s.Branch] = s.Landed
-	}
-	if !landed["nixos-unstable"] {
-		t.Error("nixos-unstable should be landed")
+	pr, _ := env.db.GetPR(2)
+	if pr.Body != "Changelog: https://example.com" {
+		t.Errorf("stored Body = %q, want the trimmed description", pr.Body)
 	}
-	if landed["nixos-24.11"] {
-		t.Error("nixos-24.11 should not be landed")
+	if merged.Body != "Changelog: https://example.com" {
+		t.Errorf("PRMerged Body = %q, want the description", merged.Body)
 	}
-	_ = pr
 }
 
-func TestPollGitHubErrorGraceful(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable"})
+func TestPollStages(t *testing.T) {
+	stages := []string{"stage-a", "stage-b", "stage-c"}
+	env := setupPoller(t, stages, []string{"stage-c"})
+	WithStages(stages)(env.p)
 
-	env.db.AddPR(9)
+	env.db.AddPR(1)
+	env.db.UpdatePRStatus(1, "merged", "sha1", "Mass rebuild", "alice")
+	env.db.AddPR(2)
+	env.db.UpdatePRStatus(2, "merged", "sha2", "Jumps ahead", "bob")
 
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/9", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusInternalServerError)
+	// reached maps each PR's merge commit to the stages it is in.
+	reached := map[string]map[string]bool{"sha1": {}, "sha2": {"stage-b": true}}
+	var mu sync.Mutex
+	checked := map[string]int{}
+	for sha := range reached {
+		for _, stage := range stages {
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/"+stage+"..."+sha, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				checked[sha+" "+stage]++
+				status := "ahead"
+				if reached[sha][stage] {
+					status = "behind"
+				}
+				json.NewEncoder(w).Encode(map[string]any{"status": status})
+			})
+		}
+	}
+
+	var landed []string
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRLandedBranch {
+			landed = append(landed, strconv.Itoa(e.PRNumber)+":"+e.Branch)
+		}
 	})
+	cycle := func(want string) {
+		t.Helper()
+		landed = nil
+		env.p.poll(context.Background())
+		if got := strings.Join(landed, ","); got != want {
+			t.Errorf("landed = %q, want %q", got, want)
+		}
+	}
 
-	// Should not panic
-	env.p.poll(context.Background())
+	mu.Lock()
+	reached["sha1"]["stage-a"] = true
+	mu.Unlock()
+	cycle("2:stage-b,1:stage-a")
 
-	// PR should still exist with original status
-	pr, err := env.db.GetPR(9)
-	if err != nil {
-		t.Fatalf("expected PR to still exist: %v", err)
+	mu.Lock()
+	reached["sha1"]["stage-b"] = true
+	reached["sha2"]["stage-a"] = true // too late: PR 2 is past stage-a
+	mu.Unlock()
+	cycle("1:stage-b")
+
+	mu.Lock()
+	reached["sha1"]["stage-c"] = true
+	mu.Unlock()
+	cycle("1:stage-c")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if n := checked["sha2 stage-a"]; n != 1 {
+		t.Errorf("PR 2 stage-a compared %d times, want 1 (skipped once stage-b landed)", n)
 	}
-	if pr.Status != "open" {
-		t.Errorf("Status = %q, want %q (unchanged)", pr.Status, "open")
+	if _, err := env.db.GetPR(1); err == nil {
+		t.Error("PR 1 still tracked after reaching the last stage")
 	}
 }
 
-func TestPollContextCancellation(t *testing.T) {
+func TestPollOpenToClosed(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
 
-	env.db.AddPR(10)
-	env.db.AddPR(11)
+	env.db.AddPR(3)
 
-	callCount := 0
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/", func(w http.ResponseWriter, r *http.Request) {
-		callCount++
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/3", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{
-			"number": 10, "title": "X", "user": map[string]any{"login": "x"},
-			"state": "open", "merged": false,
+			"number": 3, "title": "Closed", "user": map[string]any{"login": "carol"},
+			"state": "closed", "merged": false,
 		})
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
-	cancel() // cancel before poll
+	env.p.poll(context.Background())
 
-	env.p.poll(ctx)
-	// With cancelled context, should return early
+	pr, _ := env.db.GetPR(3)
+	if pr.Status != "closed" {
+		t.Errorf("Status = %q, want %q", pr.Status, "closed")
+	}
 }
 
-func TestStartPollsImmediately(t *testing.T) {
+func TestPollClosedReopens(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return now }
+	WithReopenCheck(time.Hour)(env.p)
 
-	env.db.AddPR(20)
+	env.db.AddPR(3)
+	env.db.UpdatePRStatus(3, "closed", "", "Closed", "carol")
 
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/20", func(w http.ResponseWriter, r *http.Request) {
+	state := "closed"
+	var fetches int
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+		fetches++
 		json.NewEncoder(w).Encode(map[string]any{
-			"number": 20, "title": "Immediate", "user": map[string]any{"login": "ivan"},
-			"state": "open", "merged": false,
+			"number": 3, "title": "Reopened", "user": map[string]any{"login": "carol"},
+			"state": state, "merged": false,
 		})
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
-	env.p.Start(ctx)
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) { events = append(events, e) })
 
-	// Give the goroutine time to complete the immediate poll
-	time.Sleep(100 * time.Millisecond)
-	cancel()
+	env.p.poll(context.Background())
+	if fetches != 1 {
+		t.Fatalf("fetches = %d after first cycle, want 1", fetches)
+	}
 
-	pr, _ := env.db.GetPR(20)
-	if pr.Title != "Immediate" {
-		t.Errorf("Title = %q, want %q (Start should poll immediately)", pr.Title, "Immediate")
+	// Reopened on GitHub, but the next check isn't due yet.
+	state = "open"
+	now = now.Add(30 * time.Minute)
+	env.p.poll(context.Background())
+	if fetches != 1 {
+		t.Errorf("fetches = %d before the check was due, want 1", fetches)
+	}
+	if pr, _ := env.db.GetPR(3); pr.Status != "closed" {
+		t.Errorf("Status = %q before the check was due, want closed", pr.Status)
+	}
+
+	now = now.Add(30 * time.Minute)
+	env.p.poll(context.Background())
+	pr, _ := env.db.GetPR(3)
+	if pr.Status != "open" {
+		t.Errorf("Status = %q, want open", pr.Status)
+	}
+	if pr.Title != "Reopened" {
+		t.Errorf("Title = %q, want Reopened", pr.Title)
+	}
+	if len(events) != 1 || events[0].Type != event.PRReopened || events[0].PRNumber != 3 {
+		t.Errorf("events = %+v, want one PRReopened for PR 3", events)
+	}
+	if _, ok := env.p.reopenChecked[3]; ok {
+		t.Error("reopen check kept for a reopened PR")
 	}
 }
 
-func TestPollRateLimitStopsEarly(t *testing.T) {
+func TestPollClosedForgetsRemovedPRs(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return now }
+	WithReopenCheck(time.Hour)(env.p)
+
+	for _, n := range []int{5, 6} {
+		env.db.AddPR(n)
+		env.db.UpdatePRStatus(n, "closed", "", "Closed", "carol")
+		env.ghMux.HandleFunc(fmt.Sprintf("/repos/NixOS/nixpkgs/pulls/%d", n), func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]any{"number": n, "title": "Closed", "state": "closed"})
+		})
+	}
 
-	// PR 31 has higher number so it comes first (ORDER BY pr_number DESC)
-	env.db.AddPR(30)
-	env.db.AddPR(31)
+	env.p.poll(context.Background())
+	if got := len(env.p.reopenChecked); got != 2 {
+		t.Fatalf("reopen checks recorded = %d, want 2", got)
+	}
+
+	// PR 5 is pruned by the closed sweep; PR 6 reopened in the meantime.
+	WithClosedPruning(time.Hour)(env.p)
+	now = time.Now().Add(2 * time.Hour)
+	env.db.UpdatePRStatus(6, "open", "", "Open again", "carol")
+	env.p.pruneClosed()
+	if _, ok := env.p.reopenChecked[5]; ok {
+		t.Error("reopen check kept for a pruned PR")
+	}
+
+	// PR 6 is removed through the API.
+	env.p.Forget(6)
+	if got := len(env.p.reopenChecked); got != 0 {
+		t.Errorf("reopen checks recorded = %d after removals, want 0", got)
+	}
+}
+
+func TestPollClosedNotCheckedByDefault(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(3)
+	env.db.UpdatePRStatus(3, "closed", "", "Closed", "carol")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/3", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("closed PR fetched without WithReopenCheck")
+	})
+
+	env.p.poll(context.Background())
+}
+
+func TestPollMergedChecksBranches(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(4)
+	env.db.UpdatePRStatus(4, "merged", "commitABC", "Merged PR", "dave")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitABC", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // landed
+	})
+
+	var mu sync.Mutex
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+
+	env.p.poll(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	types := make(map[event.Type]bool)
+	for _, e := range events {
+		types[e.Type] = true
+	}
+	if !types[event.PRLandedBranch] {
+		t.Error("missing PRLandedBranch event")
+	}
+	if !types[event.PRRemoved] {
+		t.Error("missing PRRemoved event (auto-remove)")
+	}
+
+	// PR should be removed
+	_, err := env.db.GetPR(4)
+	if err == nil {
+		t.Error("expected PR to be auto-removed")
+	}
+}
+
+// Branches are compared uniformly, so the development branch works as a
+// target just like a channel.
+func TestPollLandsInMaster(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		landed bool
+	}{
+		{"landed", "behind", true},
+		{"identical", "identical", true},
+		{"not yet", "ahead", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"staging-next", "master"}, []string{"master"})
+
+			env.db.AddPR(40)
+			env.db.UpdatePRStatus(40, "merged", "sha40", "Straight to master", "ivan")
+			env.db.UpdateBranchLanded(40, "staging-next")
+
+			var compared []string
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+				compared = append(compared, strings.TrimPrefix(r.URL.Path, "/repos/NixOS/nixpkgs/compare/"))
+				json.NewEncoder(w).Encode(map[string]any{"status": tt.status})
+			})
+
+			var landed []string
+			env.bus.Subscribe(func(e event.Event) {
+				if e.Type == event.PRLandedBranch {
+					landed = append(landed, e.Branch)
+				}
+			})
+
+			env.p.poll(context.Background())
+
+			if strings.Join(compared, ",") != "master...sha40" {
+				t.Errorf("compared %v, want only master...sha40", compared)
+			}
+			if got := len(landed) == 1 && landed[0] == "master"; got != tt.landed {
+				t.Errorf("landed events = %v, want master landed = %v", landed, tt.landed)
+			}
+			// master is the only target branch, so landing there finishes the PR.
+			if _, err := env.db.GetPR(40); (err != nil) != tt.landed {
+				t.Errorf("GetPR err = %v, want removed = %v", err, tt.landed)
+			}
+		})
+	}
+}
+
+// A poller for an NPT_REPOS repository tracks the same PR number as the
+// primary one independently, against its own repo and branches.
+func TestPollTwoRepos(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	otherDB := env.db.ForRepo("example/other")
+	other := New(otherDB, env.gh.ForRepo("example/other"), env.bus, time.Hour, []string{"main", "release"}, []string{"release"})
+
+	env.db.AddPR(7)
+	otherDB.AddPR(7)
+
+	var mu sync.Mutex
+	var requests []string
+	pull := func(title, sha string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]any{
+				"number": 7, "title": title, "user": map[string]any{"login": "alice"},
+				"state": "closed", "merged": true, "merge_commit_sha": sha,
+			})
+		}
+	}
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/7", pull("primary", "primarysha"))
+	env.ghMux.HandleFunc("/repos/example/other/pulls/7", pull("other", "othersha"))
+	env.ghMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		status := "ahead"
+		if r.URL.Path == "/repos/example/other/compare/main...othersha" {
+			status = "behind"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": status})
+	})
+
+	var landed []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRLandedBranch {
+			landed = append(landed, e)
+		}
+	})
+
+	env.p.poll(context.Background())
+	other.poll(context.Background())
+
+	slices.Sort(requests)
+	want := []string{
+		"/repos/NixOS/nixpkgs/compare/nixos-unstable...primarysha",
+		"/repos/example/other/compare/main...othersha",
+		"/repos/example/other/compare/release...othersha",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("compares = %v, want %v", requests, want)
+	}
+	if len(landed) != 1 || landed[0].Repo != "example/other" || landed[0].Branch != "main" || landed[0].PRNumber != 7 {
+		t.Errorf("landed events = %+v, want PR #7 of example/other in main", landed)
+	}
+
+	pr, err := env.db.GetPR(7)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Title != "primary" || pr.MergeCommit != "primarysha" || len(pr.Branches) != 1 || pr.Branches[0].Landed {
+		t.Errorf("primary PR = %+v, want primarysha not landed in nixos-unstable", pr)
+	}
+	pr, err = otherDB.GetPR(7)
+	if err != nil {
+		t.Fatalf("other GetPR: %v", err)
+	}
+	otherLanded := make(map[string]bool)
+	for _, bs := range pr.Branches {
+		otherLanded[bs.Branch] = bs.Landed
+	}
+	if pr.Title != "other" || pr.MergeCommit != "othersha" || !otherLanded["main"] || otherLanded["release"] {
+		t.Errorf("other PR = %+v, want othersha landed in main only", pr)
+	}
+}
+
+func TestPollRecordsCompareStatus(t *testing.T) {
+	env := setupPoller(t, []string{"master", "nixos-unstable"})
+
+	env.db.AddPR(12)
+	env.db.UpdatePRStatus(12, "merged", "sha12", "Half Way", "alice")
+
+	unstable := "ahead"
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/master...sha12", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha12", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": unstable})
+	})
+
+	statuses := func() map[string]db.BranchStatus {
+		pr, err := env.db.GetPR(12)
+		if err != nil {
+			t.Fatalf("GetPR: %v", err)
+		}
+		m := make(map[string]db.BranchStatus)
+		for _, bs := range pr.Branches {
+			m[bs.Branch] = bs
+		}
+		return m
+	}
+
+	env.p.poll(context.Background())
+	got := statuses()
+	if bs := got["master"]; !bs.Landed || bs.LastStatus != "behind" {
+		t.Errorf("master = %+v, want landed with last status behind", bs)
+	}
+	if bs := got["nixos-unstable"]; bs.Landed || bs.LastStatus != "ahead" {
+		t.Errorf("nixos-unstable = %+v, want pending with last status ahead", bs)
+	}
+
+	unstable = "diverged"
+	env.p.poll(context.Background())
+	if bs := statuses()["nixos-unstable"]; bs.Landed || bs.LastStatus != "diverged" {
+		t.Errorf("nixos-unstable = %+v, want last status updated to diverged", bs)
+	}
+}
+
+func TestPollCommentOnLand(t *testing.T) {
+	tests := []struct {
+		name     string
+		locked   bool
+		unstable string // compare status of nixos-unstable
+		want     []string
+	}{
+		{"comments", false, "ahead", []string{"Landed in nixos-unstable-small as of 2024-05-01."}},
+		{"locked", true, "ahead", nil},
+		{"two landings", false, "behind", []string{"Landed in nixos-unstable-small as of 2024-05-01.", "Landed in nixos-unstable as of 2024-05-01."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"nixos-unstable-small", "nixos-unstable"}, []string{"nixos-unstable"})
+			WithCommentOnLand()(env.p)
+			env.p.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+			env.db.AddPR(45)
+			env.db.UpdatePRStatus(45, "merged", "sha45", "Commented", "ivan")
+
+			var fetches int
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/45", func(w http.ResponseWriter, r *http.Request) {
+				fetches++
+				json.NewEncoder(w).Encode(map[string]any{
+					"number": 45, "state": "closed", "merged": true, "merge_commit_sha": "sha45", "locked": tt.locked,
+				})
+			})
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable-small...sha45", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+			})
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha45", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]any{"status": tt.unstable})
+			})
+			var comments []string
+			env.ghMux.HandleFunc("POST /repos/NixOS/nixpkgs/issues/45/comments", func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]string
+				json.NewDecoder(r.Body).Decode(&req)
+				comments = append(comments, req["body"])
+				w.WriteHeader(http.StatusCreated)
+			})
+
+			env.p.poll(context.Background())
+
+			if strings.Join(comments, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("comments = %q, want %q", comments, tt.want)
+			}
+			// The lock is checked once per poll, however many branches land.
+			if fetches != 1 {
+				t.Errorf("PR fetched %d times, want 1", fetches)
+			}
+		})
+	}
+}
+
+func TestPollNotYetLanded(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(5)
+	env.db.UpdatePRStatus(5, "merged", "commitDEF", "Not Landed", "eve")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitDEF", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+
+	env.p.poll(context.Background())
+
+	// PR should still exist
+	pr, err := env.db.GetPR(5)
+	if err != nil {
+		t.Fatalf("expected PR to still exist: %v", err)
+	}
+	if pr.Status != "merged" {
+		t.Errorf("Status = %q, want %q", pr.Status, "merged")
+	}
+}
+
+func TestPollSkipAlreadyLanded(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable", "nixos-24.11"})
+
+	env.db.AddPR(6)
+	env.db.UpdatePRStatus(6, "merged", "commitGHI", "Partial Landed", "frank")
+	env.db.UpdateBranchLanded(6, "nixos-unstable") // already landed
+
+	var compareCount int
+	var compareMu sync.Mutex
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		compareMu.Lock()
+		compareCount++
+		compareMu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // nixos-24.11 not landed
+	})
+
+	env.p.poll(context.Background())
+
+	compareMu.Lock()
+	defer compareMu.Unlock()
+	// Should only check nixos-24.11 (skip nixos-unstable)
+	if compareCount != 1 {
+		t.Errorf("compare API calls = %d, want 1 (skipping already landed)", compareCount)
+	}
+}
+
+func TestPollAllLandedAutoRemoves(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable", "nixos-24.11"})
+
+	env.db.AddPR(7)
+	env.db.UpdatePRStatus(7, "merged", "commitJKL", "All Landing", "grace")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // both landed
+	})
+
+	env.p.poll(context.Background())
+
+	_, err := env.db.GetPR(7)
+	if err == nil {
+		t.Error("expected PR to be auto-removed after landing in all branches")
+	}
+}
+
+func TestPollFullyLandedEvent(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable-small", "nixos-unstable", "nixpkgs-unstable"})
+
+	env.db.AddPR(8)
+	env.db.UpdatePRStatus(8, "merged", "commitMNO", "Everywhere", "heidi")
+	env.db.UpdateBranchLanded(8, "nixos-unstable-small") // landed in an earlier cycle
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	var types []event.Type
+	var fully []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		types = append(types, e.Type)
+		if e.Type == event.PRFullyLanded {
+			fully = append(fully, e)
+		}
+	})
+
+	env.p.poll(context.Background())
+
+	if len(fully) != 1 {
+		t.Fatalf("got %d PRFullyLanded events, want 1", len(fully))
+	}
+	want := []string{"nixos-unstable-small", "nixos-unstable", "nixpkgs-unstable"}
+	if strings.Join(fully[0].Branches, ",") != strings.Join(want, ",") {
+		t.Errorf("Branches = %v, want %v", fully[0].Branches, want)
+	}
+	if fully[0].PRNumber != 8 || fully[0].Title != "Everywhere" {
+		t.Errorf("event = %+v, want PR 8 \"Everywhere\"", fully[0])
+	}
+	// Fully landed comes right before the removal.
+	if n := len(types); n < 2 || types[n-2] != event.PRFullyLanded || types[n-1] != event.PRRemoved {
+		t.Errorf("event order = %v, want ... pr_fully_landed, pr_removed", types)
+	}
+}
+
+func TestPollPartialLanding(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable", "nixos-24.11"})
+
+	env.db.AddPR(8)
+	env.db.UpdatePRStatus(8, "merged", "commitMNO", "Partial", "heidi")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitMNO", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // landed
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-24.11...commitMNO", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed
+	})
+
+	env.p.poll(context.Background())
+
+	pr, err := env.db.GetPR(8)
+	if err != nil {
+		t.Fatalf("expected PR to still exist: %v", err)
+	}
+
+	statuses, _ := env.db.GetBranchStatus(8)
+	landed := make(map[string]bool)
+	for _, s := range statuses {
+		landed[s.Branch] = s.Landed
+	}
+	if !landed["nixos-unstable"] {
+		t.Error("nixos-unstable should be landed")
+	}
+	if landed["nixos-24.11"] {
+		t.Error("nixos-24.11 should not be landed")
+	}
+	_ = pr
+}
+
+func TestPollGroupedLandings(t *testing.T) {
+	tests := []struct {
+		name     string
+		grouped  bool
+		unstable int // compare response for nixos-unstable
+		want     []string
+	}{
+		{"separate", false, http.StatusOK, []string{"nixos-unstable-small", "nixos-24.11"}},
+		{"grouped", true, http.StatusOK, []string{"nixos-unstable-small,nixos-24.11"}},
+		// Landings already recorded are still announced when a later
+		// branch fails.
+		{"grouped with failure", true, http.StatusInternalServerError, []string{"nixos-unstable-small,nixos-24.11"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"nixos-unstable-small", "nixos-24.11", "nixos-unstable"}, []string{"nixos-unstable"})
+			if tt.grouped {
+				WithGroupedLandings()(env.p)
+			}
+
+			env.db.AddPR(46)
+			env.db.UpdatePRStatus(46, "merged", "sha46", "Grouped", "ivan")
+
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/nixos-unstable...sha46") {
+					w.WriteHeader(tt.unstable)
+					json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+			})
+
+			var landed []string
+			env.bus.Subscribe(func(e event.Event) {
+				if e.Type != event.PRLandedBranch {
+					return
+				}
+				if len(e.Branches) > 0 {
+					landed = append(landed, strings.Join(e.Branches, ","))
+				} else {
+					landed = append(landed, e.Branch)
+				}
+			})
+
+			env.p.poll(context.Background())
+
+			if strings.Join(landed, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("PRLandedBranch events = %q, want %q", landed, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollGitHubErrorGraceful(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(9)
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/9", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	// Should not panic
+	env.p.poll(context.Background())
+
+	// PR should still exist with original status
+	pr, err := env.db.GetPR(9)
+	if err != nil {
+		t.Fatalf("expected PR to still exist: %v", err)
+	}
+	if pr.Status != "open" {
+		t.Errorf("Status = %q, want %q (unchanged)", pr.Status, "open")
+	}
+}
+
+func TestPollContextCancellation(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(10)
+	env.db.AddPR(11)
+
+	callCount := 0
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/", func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 10, "title": "X", "user": map[string]any{"login": "x"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // cancel before poll
+
+	env.p.poll(ctx)
+	// With cancelled context, should return early
+}
+
+func TestStartPollsImmediately(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(20)
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/20", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 20, "title": "Immediate", "user": map[string]any{"login": "ivan"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env.p.Start(ctx)
+
+	// Give the goroutine time to complete the immediate poll
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	pr, _ := env.db.GetPR(20)
+	if pr.Title != "Immediate" {
+		t.Errorf("Title = %q, want %q (Start should poll immediately)", pr.Title, "Immediate")
+	}
+}
+
+func TestDailySummary(t *testing.T) {
+	env := setupPoller(t, []string{"staging", "nixos-unstable"})
+	env.p.deferFirstPoll = true
+
+	// The clock stands just before a minute boundary, so the summary set
+	// for that minute is due in 50ms.
+	due := time.Now().Truncate(time.Minute).Add(time.Minute)
+	now := due.Add(-50 * time.Millisecond)
+	env.p.now = func() time.Time { return now }
+	WithDailySummary(due.Hour(), due.Minute())(env.p)
+
+	landedAt := now.Add(-time.Hour)
+	longAgo := now.Add(-72 * time.Hour)
+	env.db.RestorePR(db.TrackedPR{PRNumber: 1, Status: "merged", UpdatedAt: now.Add(-2 * time.Hour),
+		Branches: []db.BranchStatus{{Branch: "staging", Landed: true, LandedAt: &landedAt}}})
+	env.db.RestorePR(db.TrackedPR{PRNumber: 2, Status: "merged", UpdatedAt: longAgo})
+	env.db.RestorePR(db.TrackedPR{PRNumber: 3, Status: "open"})
+	env.db.RestorePR(db.TrackedPR{PRNumber: 6, Status: "landed",
+		Branches: []db.BranchStatus{{Branch: "nixos-unstable", Landed: true, LandedAt: &longAgo}}})
+	// PR 4 fully landed and was removed within the day, PR 5 before it.
+	env.db.AddEvent(db.EventRecord{Type: string(event.PRFullyLanded), PRNumber: 4, CreatedAt: now.Add(-time.Hour)})
+	env.db.AddEvent(db.EventRecord{Type: string(event.PRFullyLanded), PRNumber: 5, CreatedAt: now.Add(-48 * time.Hour)})
+
+	summaries := make(chan event.Event, 1)
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.DailySummary {
+			select {
+			case summaries <- e:
+			default:
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env.p.Start(ctx)
+
+	var e event.Event
+	select {
+	case e = <-summaries:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no daily summary at the configured time")
+	}
+	got := e.Summary
+	if got == nil {
+		t.Fatal("DailySummary without a Summary")
+	}
+	if !slices.Equal(got.Landed, []int{1, 4}) {
+		t.Errorf("Landed = %v, want [1 4]", got.Landed)
+	}
+	if !slices.Equal(got.Pending, []int{1, 2}) {
+		t.Errorf("Pending = %v, want [1 2]", got.Pending)
+	}
+	if got.OldestPending != 2 || !got.PendingSince.Equal(longAgo.Truncate(time.Second)) {
+		t.Errorf("oldest pending = #%d since %v, want #2 since %v", got.OldestPending, got.PendingSince, longAgo)
+	}
+	if !e.Timestamp.Equal(now) {
+		t.Errorf("Timestamp = %v, want the clock's %v", e.Timestamp, now)
+	}
+}
+
+// A pending PR's age runs from its merge, not from its last update, which
+// a later refresh or reset moves.
+func TestDailySummaryPendingSinceMerge(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	now := time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return now }
+
+	mergedAt := now.Add(-72 * time.Hour)
+	env.db.RestorePR(db.TrackedPR{PRNumber: 1, Status: "merged", MergedAt: mergedAt, UpdatedAt: now.Add(-time.Hour)})
+	env.db.RestorePR(db.TrackedPR{PRNumber: 2, Status: "merged", UpdatedAt: now.Add(-24 * time.Hour)})
+
+	var got *event.Summary
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.DailySummary {
+			got = e.Summary
+		}
+	})
+	env.p.publishSummary()
+
+	if got == nil {
+		t.Fatal("no daily summary")
+	}
+	if got.OldestPending != 1 || !got.PendingSince.Equal(mergedAt) {
+		t.Errorf("oldest pending = #%d since %v, want #1 since %v", got.OldestPending, got.PendingSince, mergedAt)
+	}
+}
+
+func TestUntilSummary(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	WithDailySummary(18, 0)(env.p)
+
+	tests := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{time.Date(2025, 3, 1, 17, 30, 0, 0, time.UTC), 30 * time.Minute},
+		{time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC), 23 * time.Hour},
+	}
+	for _, tt := range tests {
+		env.p.now = func() time.Time { return tt.now }
+		if got := env.p.untilSummary(); got != tt.want {
+			t.Errorf("untilSummary() at %s = %v, want %v", tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestStartDeferredFirstPoll(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p.deferFirstPoll = true
+	env.p.interval = 200 * time.Millisecond
+
+	env.db.AddPR(21)
+
+	polled := make(chan time.Time, 4)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/21", func(w http.ResponseWriter, r *http.Request) {
+		polled <- time.Now()
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 21, "title": "Deferred", "user": map[string]any{"login": "ivan"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	env.p.Start(ctx)
+
+	select {
+	case at := <-polled:
+		if elapsed := at.Sub(start); elapsed < env.p.interval {
+			t.Errorf("first poll after %v, want no poll before the first tick at %v", elapsed, env.p.interval)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no poll after the first tick")
+	}
+}
+
+func TestPollRateLimitStopsEarly(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	// PR 31 has higher number so it comes first (ORDER BY pr_number DESC)
+	env.db.AddPR(30)
+	env.db.AddPR(31)
+
+	var apiCalls atomic.Int32
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/31", func(w http.ResponseWriter, r *http.Request) {
+		apiCalls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(30*time.Minute).Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/30", func(w http.ResponseWriter, r *http.Request) {
+		apiCalls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 30, "title": "Should Not Reach", "user": map[string]any{"login": "z"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	env.p.poll(context.Background())
+
+	if n := apiCalls.Load(); n != 1 {
+		t.Errorf("API calls = %d, want 1 (second PR should be skipped after rate limit)", n)
+	}
+
+	// Both PRs should still exist unmodified
+	pr31, err := env.db.GetPR(31)
+	if err != nil {
+		t.Fatalf("PR 31 should still exist: %v", err)
+	}
+	if pr31.Status != "open" {
+		t.Errorf("PR 31 status = %q, want %q", pr31.Status, "open")
+	}
+
+	pr30, err := env.db.GetPR(30)
+	if err != nil {
+		t.Fatalf("PR 30 should still exist: %v", err)
+	}
+	if pr30.Status != "open" {
+		t.Errorf("PR 30 status = %q, want %q", pr30.Status, "open")
+	}
+}
+
+func TestRunPollCycleBackoff(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(40)
+
+	// Rate limit resets 2 seconds from now (Unix timestamp has second granularity)
+	resetAt := time.Now().Add(2 * time.Second)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/40", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	start := time.Now()
+	env.p.runPollCycle(context.Background())
+	elapsed := time.Since(start)
+
+	// runPollCycle should have blocked until the reset time
+	if elapsed < 1*time.Second {
+		t.Errorf("runPollCycle returned too quickly (%v), expected backoff wait", elapsed)
+	}
+}
+
+func TestRunPollCycleRateLimitedEvent(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(41)
+
+	resetAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/41", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.RateLimited {
+			events = append(events, e)
+		}
+	})
+
+	// Two cycles inside the same rate-limit window; each gives up waiting
+	// when its context expires.
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		env.p.runPollCycle(ctx)
+		cancel()
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("got %d RateLimited events, want 1", len(events))
+	}
+	if !events[0].ResetAt.Equal(resetAt) {
+		t.Errorf("ResetAt = %v, want %v", events[0].ResetAt, resetAt)
+	}
+}
+
+func TestRunPollCycleRateLimitUsesClock(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(43)
+
+	resetAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/43", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.RateLimited {
+			events = append(events, e)
+		}
+	})
+
+	clock := resetAt.Add(-30 * time.Minute)
+	env.p.now = func() time.Time { return clock }
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	env.p.runPollCycle(ctx)
+	cancel()
+	if len(events) != 1 {
+		t.Fatalf("got %d RateLimited events, want 1", len(events))
+	}
+	if !events[0].Timestamp.Equal(clock) {
+		t.Errorf("Timestamp = %v, want the poller's clock %v", events[0].Timestamp, clock)
+	}
+
+	// Past the reset on the poller's clock, the cycle runs again and
+	// doesn't wait even though GitHub still reports the old limit.
+	env.p.now = func() time.Time { return resetAt.Add(time.Hour) }
+	start := time.Now()
+	env.p.runPollCycle(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("runPollCycle waited %v past the reset", elapsed)
+	}
+	if len(events) != 1 {
+		t.Errorf("got %d RateLimited events, want no new one past the reset", len(events))
+	}
+}
+
+func TestRunPollCycleBackoffContextCancel(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(41)
+
+	// Rate limit resets far in the future
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/41", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(1*time.Hour).Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	env.p.runPollCycle(ctx)
+	elapsed := time.Since(start)
+
+	// Should unblock quickly after cancel, not wait the full hour
+	if elapsed > 2*time.Second {
+		t.Errorf("runPollCycle took %v after context cancel, expected fast return", elapsed)
+	}
+}
+
+func TestPollSkipsUpstreamBranches(t *testing.T) {
+	// Track staging, staging-next, master, and nixos-unstable.
+	// master has already landed, so staging and staging-next should be skipped.
+	env := setupPoller(t, []string{"staging", "staging-next", "master", "nixos-unstable"})
+
+	env.db.AddPR(50)
+	env.db.UpdatePRStatus(50, "merged", "commitXYZ", "Skip Upstream", "alice")
+	env.db.UpdateBranchLanded(50, "master") // master already landed
+
+	var compareMu sync.Mutex
+	checkedBranches := make(map[string]bool)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		// Extract branch name from URL: /repos/NixOS/nixpkgs/compare/{branch}...{commit}
+		path := r.URL.Path
+		// path looks like /repos/NixOS/nixpkgs/compare/nixos-unstable...commitXYZ
+		branch := path[len("/repos/NixOS/nixpkgs/compare/"):]
+		if idx := len(branch) - len("...commitXYZ"); idx > 0 {
+			branch = branch[:idx]
+		}
+		compareMu.Lock()
+		checkedBranches[branch] = true
+		compareMu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed
+	})
+
+	env.p.poll(context.Background())
+
+	compareMu.Lock()
+	defer compareMu.Unlock()
+
+	// staging and staging-next are upstream of master, should be skipped
+	if checkedBranches["staging"] {
+		t.Error("staging should have been skipped (upstream of landed master)")
+	}
+	if checkedBranches["staging-next"] {
+		t.Error("staging-next should have been skipped (upstream of landed master)")
+	}
+	// nixos-unstable should still be checked
+	if !checkedBranches["nixos-unstable"] {
+		t.Error("nixos-unstable should have been checked")
+	}
+}
+
+func TestRunPollCycleResetInPast(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(42)
+
+	// Rate limit reset already in the past
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/42", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(-1*time.Minute).Unix()))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	start := time.Now()
+	env.p.runPollCycle(context.Background())
+	elapsed := time.Since(start)
+
+	// Should return immediately since reset is in the past
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("runPollCycle took %v for past reset time, expected immediate return", elapsed)
+	}
+}
+
+func TestPollTargetBranchesAutoRemove(t *testing.T) {
+	// Track staging + nixos-unstable as notification branches,
+	// but only nixos-unstable is a target branch.
+	// PR lands in nixos-unstable but not staging → should be auto-removed.
+	env := setupPoller(t,
+		[]string{"staging", "nixos-unstable"},
+		[]string{"nixos-unstable"},
+	)
+
+	env.db.AddPR(60)
+	env.db.UpdatePRStatus(60, "merged", "commitFIN", "Target Branch Test", "alice")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/staging...commitFIN", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitFIN", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // landed
+	})
+
+	env.p.poll(context.Background())
+
+	// PR should be auto-removed because nixos-unstable (the only target branch) has landed
+	_, err := env.db.GetPR(60)
+	if err == nil {
+		t.Error("expected PR to be auto-removed after landing in all target branches")
+	}
+}
+
+func TestPollTrackedCommitLanding(t *testing.T) {
+	env := setupPoller(t,
+		[]string{"staging", "nixos-unstable"},
+		[]string{"nixos-unstable"},
+	)
+
+	env.db.AddCommit("bare123", "staging-next merge")
+
+	landed := false
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/staging...bare123", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...bare123", func(w http.ResponseWriter, r *http.Request) {
+		status := "ahead"
+		if landed {
+			status = "behind"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"status": status})
+	})
+
+	var mu sync.Mutex
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+
+	// First cycle: landed in staging only, commit stays tracked.
+	env.p.poll(context.Background())
+
+	c, err := env.db.GetCommit("bare123")
+	if err != nil {
+		t.Fatalf("expected commit to still be tracked: %v", err)
+	}
+	if len(c.Branches) != 1 || c.Branches[0].Branch != "staging" {
+		t.Errorf("Branches = %+v, want [staging]", c.Branches)
+	}
+
+	// Second cycle: lands in the target branch and is auto-removed.
+	landed = true
+	env.p.poll(context.Background())
+
+	if _, err := env.db.GetCommit("bare123"); err == nil {
+		t.Error("expected commit to be auto-removed after landing in all target branches")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var landedIn []string
+	removed := false
+	for _, e := range events {
+		switch e.Type {
+		case event.CommitLandedBranch:
+			if e.Commit != "bare123" {
+				t.Errorf("CommitLandedBranch Commit = %q, want %q", e.Commit, "bare123")
+			}
+			landedIn = append(landedIn, e.Branch)
+		case event.CommitRemoved:
+			removed = true
+		}
+	}
+	if len(landedIn) != 2 || landedIn[0] != "staging" || landedIn[1] != "nixos-unstable" {
+		t.Errorf("landed events = %v, want [staging nixos-unstable]", landedIn)
+	}
+	if !removed {
+		t.Error("missing CommitRemoved event")
+	}
+}
+
+func TestRefreshConcurrentWithPollDedupes(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(70)
+
+	var calls atomic.Int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/70", func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 70, "title": "Dedupe", "user": map[string]any{"login": "x"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		env.p.poll(context.Background())
+	}()
+
+	<-entered
+	go func() {
+		defer wg.Done()
+		if err := env.p.Refresh(context.Background(), 70); err != nil {
+			t.Errorf("Refresh: %v", err)
+		}
+	}()
+
+	// Give Refresh time to reach the in-flight guard before releasing.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("GitHub PR calls = %d, want 1 (refresh should wait for in-flight poll)", n)
+	}
+}
+
+func TestRefreshUntrackedPR(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	if err := env.p.Refresh(context.Background(), 404); err == nil {
+		t.Error("expected error refreshing an untracked PR")
+	}
+}
+
+func TestPollLandingFallback(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p = New(env.db, env.gh, env.bus, time.Hour, []string{"nixos-unstable"}, []string{"nixos-unstable"}, WithLandingFallback(time.Hour))
+	// The fallback waits on the poller's clock, not the wall clock.
+	env.p.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	env.db.AddPR(80)
+	env.db.UpdatePRStatus(80, "merged", "squashme", "foo: 1.0 -> 2.0", "alice")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...squashme", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "diverged"}) // SHA never lands
+	})
+	// The PR was tracked without a merge time, so it is fetched before
+	// searching history.
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/80", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 80, "title": "foo: 1.0 -> 2.0", "state": "closed",
+			"merged": true, "merge_commit_sha": "squashme", "merged_at": "2025-03-01T12:00:00Z",
+			"user": map[string]any{"login": "alice"},
+		})
+	})
+	var since string
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/commits", func(w http.ResponseWriter, r *http.Request) {
+		since = r.URL.Query().Get("since")
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"commit": map[string]any{"message": "foo: 1.0 -> 2.0 (#80)", "committer": map[string]any{"date": "2025-03-01T12:00:00Z"}}},
+		})
+	})
 
-	var apiCalls atomic.Int32
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/31", func(w http.ResponseWriter, r *http.Request) {
-		apiCalls.Add(1)
+	env.p.poll(context.Background())
+
+	if _, err := env.db.GetPR(80); err == nil {
+		t.Error("expected PR to be auto-removed after fallback detected landing")
+	}
+	if want := "2025-03-01T11:59:00Z"; since != want {
+		t.Errorf("commits since = %q, want %q", since, want)
+	}
+}
+
+func TestPollLandingFallbackDisabled(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(81)
+	env.db.UpdatePRStatus(81, "merged", "squashme2", "bar: 1.0 -> 2.0", "alice")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...squashme2", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "diverged"})
+	})
+	var historyCalls atomic.Int32
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/commits", func(w http.ResponseWriter, r *http.Request) {
+		historyCalls.Add(1)
+		json.NewEncoder(w).Encode([]map[string]any{})
+	})
+
+	env.p.poll(context.Background())
+
+	if n := historyCalls.Load(); n != 0 {
+		t.Errorf("commits API calls = %d, want 0 with fallback disabled", n)
+	}
+	if _, err := env.db.GetPR(81); err != nil {
+		t.Errorf("expected PR to remain tracked: %v", err)
+	}
+}
+
+func TestNextIntervalJitter(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p.interval = 10 * time.Minute
+
+	if got := env.p.nextInterval(); got != 10*time.Minute {
+		t.Errorf("nextInterval() without jitter = %v, want %v", got, 10*time.Minute)
+	}
+
+	env.p.jitter = 0.2
+	draws := []float64{0, 0.5, 0.75}
+	env.p.rand = func() float64 {
+		r := draws[0]
+		draws = draws[1:]
+		return r
+	}
+	for _, want := range []time.Duration{8 * time.Minute, 10 * time.Minute, 11 * time.Minute} {
+		if got := env.p.nextInterval(); got != want {
+			t.Errorf("nextInterval() = %v, want %v", got, want)
+		}
+	}
+
+	// Over many ticks the waits stay within ±20% and actually vary.
+	env.p.rand = rand.New(rand.NewPCG(1, 2)).Float64
+	lo, hi := 8*time.Minute, 12*time.Minute
+	seen := make(map[time.Duration]bool)
+	for range 100 {
+		d := env.p.nextInterval()
+		if d < lo || d > hi {
+			t.Fatalf("nextInterval() = %v, want within [%v, %v]", d, lo, hi)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 50 {
+		t.Errorf("got %d distinct intervals in 100 ticks, want them to vary", len(seen))
+	}
+}
+
+func TestSetIntervalResetsTicker(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(90)
+
+	var calls atomic.Int32
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/90", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 90, "title": "Reload", "user": map[string]any{"login": "x"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env.p.Start(ctx) // interval is 1h, so only the immediate poll would run
+
+	time.Sleep(50 * time.Millisecond)
+	env.p.SetInterval(20 * time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	if n := calls.Load(); n < 3 {
+		t.Errorf("polls = %d, want >= 3 after shortening the interval", n)
+	}
+	if got := env.p.Interval(); got != 20*time.Millisecond {
+		t.Errorf("Interval() = %v, want %v", got, 20*time.Millisecond)
+	}
+}
+
+func TestRunPollCyclePrunesEvents(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p = New(env.db, env.gh, env.bus, time.Hour, []string{"nixos-unstable"}, []string{"nixos-unstable"}, WithEventRetention(24*time.Hour))
+
+	now := time.Now()
+	env.db.AddEvent(db.EventRecord{Type: "pr_added", PRNumber: 1, CreatedAt: now.Add(-48 * time.Hour)})
+	env.db.AddEvent(db.EventRecord{Type: "pr_merged", PRNumber: 1, CreatedAt: now.Add(-time.Hour)})
+
+	env.p.runPollCycle(context.Background())
+
+	events, err := env.db.ListEvents(10)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "pr_merged" {
+		t.Errorf("events = %+v, want only the recent pr_merged", events)
+	}
+}
+
+func TestRunPollCycleDeadline(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p = New(env.db, env.gh, env.bus, time.Hour, []string{"nixos-unstable"}, []string{"nixos-unstable"}, WithPollTimeout(100*time.Millisecond))
+
+	for _, n := range []int{1, 2, 3} {
+		env.db.AddPR(n)
+	}
+
+	var mu sync.Mutex
+	var polled []int
+	slow := true
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/repos/NixOS/nixpkgs/pulls/"), "%d", &n)
+		mu.Lock()
+		polled = append(polled, n)
+		stall := n == 2 && slow
+		mu.Unlock()
+		if stall {
+			// Hang until the cycle deadline cancels the request.
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": n, "title": fmt.Sprintf("PR %d", n), "user": map[string]any{"login": "x"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	start := time.Now()
+	env.p.runPollCycle(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("cycle took %s, want it cut off near the 100ms deadline", elapsed)
+	}
+	mu.Lock()
+	if got := fmt.Sprint(polled); got != "[3 2]" {
+		t.Errorf("first cycle polled %s, want [3 2] (PR 1 skipped by the deadline)", got)
+	}
+	polled, slow = nil, false
+	mu.Unlock()
+	if last, _ := env.p.Health(); !last.IsZero() {
+		t.Error("a cycle cut short by its deadline should not count as a success")
+	}
+
+	env.p.runPollCycle(context.Background())
+	mu.Lock()
+	defer mu.Unlock()
+	if got := fmt.Sprint(polled); got != "[2 1 3]" {
+		t.Errorf("second cycle polled %s, want [2 1 3] (deferred PRs first)", got)
+	}
+	if pr, _ := env.db.GetPR(1); pr.Title != "PR 1" {
+		t.Errorf("PR 1 title = %q, want it polled in the second cycle", pr.Title)
+	}
+}
+
+func TestStartPrunesOldClosedPRs(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p = New(env.db, env.gh, env.bus, time.Hour, []string{"nixos-unstable"}, []string{"nixos-unstable"}, WithClosedPruning(7*24*time.Hour))
+
+	now := time.Now()
+	seed := []db.TrackedPR{
+		{PRNumber: 40, Title: "Old Closed", Status: "closed", UpdatedAt: now.Add(-30 * 24 * time.Hour)},
+		{PRNumber: 41, Title: "Recently Closed", Status: "closed", UpdatedAt: now.Add(-time.Hour)},
+	}
+	for _, pr := range seed {
+		pr.CreatedAt, pr.LastCheckedAt = pr.UpdatedAt, pr.UpdatedAt
+		if err := env.db.RestorePR(pr); err != nil {
+			t.Fatalf("RestorePR(%d): %v", pr.PRNumber, err)
+		}
+	}
+
+	removed := make(chan event.Event, 4)
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRRemoved {
+			removed <- e
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	env.p.Start(ctx)
+
+	select {
+	case e := <-removed:
+		if e.PRNumber != 40 || e.Title != "Old Closed" {
+			t.Errorf("PRRemoved for #%d %q, want #40 %q", e.PRNumber, e.Title, "Old Closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no PRRemoved event for the old closed PR")
+	}
+
+	if _, err := env.db.GetPR(40); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetPR(40) err = %v, want db.ErrNotFound", err)
+	}
+	if _, err := env.db.GetPR(41); err != nil {
+		t.Errorf("recently closed PR 41 should be kept: %v", err)
+	}
+	select {
+	case e := <-removed:
+		t.Errorf("unexpected PRRemoved for #%d", e.PRNumber)
+	default:
+	}
+}
+
+func TestPollLandedRetention(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p.landedRetention = 2 * time.Hour
+
+	env.db.AddPR(45)
+	env.db.UpdatePRStatus(45, "merged", "sha45", "Lingering", "alice")
+
+	var compares atomic.Int32
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha45", func(w http.ResponseWriter, r *http.Request) {
+		compares.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	var mu sync.Mutex
+	var types []event.Type
+	env.bus.Subscribe(func(e event.Event) {
+		mu.Lock()
+		types = append(types, e.Type)
+		mu.Unlock()
+	})
+	eventTypes := func() []event.Type {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(types)
+	}
+
+	env.p.poll(context.Background())
+
+	pr, err := env.db.GetPR(45)
+	if err != nil {
+		t.Fatalf("PR removed on landing, want it kept: %v", err)
+	}
+	if pr.Status != "landed" {
+		t.Errorf("Status = %q, want %q", pr.Status, "landed")
+	}
+	if got, want := eventTypes(), []event.Type{event.PRLandedBranch, event.PRFullyLanded}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	// Within the window the PR stays and GitHub is left alone.
+	landedAt := pr.UpdatedAt
+	env.p.now = func() time.Time { return landedAt.Add(time.Hour) }
+	env.p.poll(context.Background())
+	if _, err := env.db.GetPR(45); err != nil {
+		t.Fatalf("PR removed inside the retention window: %v", err)
+	}
+	if n := compares.Load(); n != 1 {
+		t.Errorf("compare called %d times, want 1 (landed PRs are not polled)", n)
+	}
+
+	env.p.now = func() time.Time { return landedAt.Add(2 * time.Hour) }
+	env.p.poll(context.Background())
+	if _, err := env.db.GetPR(45); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetPR(45) err = %v, want db.ErrNotFound after the window", err)
+	}
+	if got, want := eventTypes(), []event.Type{event.PRLandedBranch, event.PRFullyLanded, event.PRRemoved}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestHealthStall(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return clock }
+	env.p.started = clock
+
+	if last, healthy := env.p.Health(); !healthy || !last.IsZero() {
+		t.Errorf("Health() = %v, %v before first poll, want zero, true", last, healthy)
+	}
+
+	env.p.runPollCycle(context.Background())
+	if last, healthy := env.p.Health(); !healthy || !last.Equal(clock) {
+		t.Errorf("Health() = %v, %v after poll, want %v, true", last, healthy, clock)
+	}
+
+	// interval is 1h: still healthy at exactly 3 intervals, stalled after
+	clock = clock.Add(3 * time.Hour)
+	if _, healthy := env.p.Health(); !healthy {
+		t.Error("expected healthy at 3 intervals since last poll")
+	}
+	clock = clock.Add(time.Minute)
+	if _, healthy := env.p.Health(); healthy {
+		t.Error("expected unhealthy after 3 intervals without a completed poll")
+	}
+
+	env.p.runPollCycle(context.Background())
+	if _, healthy := env.p.Health(); !healthy {
+		t.Error("expected healthy again after a completed poll")
+	}
+}
+
+// Cycles held off for a rate limit don't count as a stall until 3 intervals
+// after the reset.
+func TestHealthRateLimited(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return clock }
+	env.p.started = clock
+
+	// The poll succeeds, but GitHub reports no requests left until a reset
+	// 5 intervals away, so the following cycles are skipped.
+	env.db.AddPR(1)
+	resetAt := clock.Add(5 * time.Hour)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/1", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-RateLimit-Remaining", "0")
-		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(30*time.Minute).Unix()))
-		w.WriteHeader(http.StatusForbidden)
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 1, "title": "T", "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false,
+		})
+	})
+	env.p.runPollCycle(context.Background())
+
+	clock = clock.Add(4 * time.Hour)
+	env.p.runPollCycle(context.Background())
+	if last, healthy := env.p.Health(); !healthy || last.Equal(clock) {
+		t.Errorf("Health() = %v, %v while skipping cycles for the rate limit, want the first poll, true", last, healthy)
+	}
+	clock = resetAt.Add(3 * time.Hour)
+	if _, healthy := env.p.Health(); !healthy {
+		t.Error("expected healthy within 3 intervals of the reset")
+	}
+	clock = clock.Add(time.Minute)
+	if _, healthy := env.p.Health(); healthy {
+		t.Error("expected unhealthy 3 intervals after the reset without a completed poll")
+	}
+}
+
+func TestHealthNeverPolled(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.p.now = func() time.Time { return clock }
+	env.p.started = clock
+
+	clock = clock.Add(4 * time.Hour)
+	if _, healthy := env.p.Health(); healthy {
+		t.Error("expected unhealthy when no poll completed within 3 intervals of start")
+	}
+}
+
+func TestPollRetriesListPRs(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	orig := listPRsRetryDelay
+	listPRsRetryDelay = time.Millisecond
+	t.Cleanup(func() { listPRsRetryDelay = orig })
+
+	env.db.AddPR(1)
+	var calls int
+	env.p.listPRs = func() ([]db.TrackedPR, error) {
+		calls++
+		if calls == 1 {
+			return nil, fmt.Errorf("database is locked")
+		}
+		return env.db.ListPRs()
+	}
+
+	var fetched atomic.Bool
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		fetched.Store(true)
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 1, "title": "Retried", "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	env.p.poll(context.Background())
+
+	if calls != 2 {
+		t.Errorf("listPRs calls = %d, want 2", calls)
+	}
+	if !fetched.Load() {
+		t.Error("expected PR to be polled after ListPRs recovered")
+	}
+}
+
+func TestPollListPRsRetryGivesUp(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	orig := listPRsRetryDelay
+	listPRsRetryDelay = time.Millisecond
+	t.Cleanup(func() { listPRsRetryDelay = orig })
+
+	var calls int
+	env.p.listPRs = func() ([]db.TrackedPR, error) {
+		calls++
+		return nil, fmt.Errorf("database is locked")
+	}
+
+	env.p.poll(context.Background())
+
+	if calls != listPRsAttempts {
+		t.Errorf("listPRs calls = %d, want %d", calls, listPRsAttempts)
+	}
+}
+
+func TestPollListPRsRetryContextCancel(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	env.p.listPRs = func() ([]db.TrackedPR, error) {
+		calls++
+		cancel()
+		return nil, fmt.Errorf("database is locked")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		env.p.poll(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("poll did not return promptly after context cancel during retry backoff")
+	}
+	if calls != 1 {
+		t.Errorf("listPRs calls = %d, want 1", calls)
+	}
+}
+
+func TestPollChecksPassed(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	env.p = New(env.db, env.gh, env.bus, time.Hour, []string{"nixos-unstable"}, []string{"nixos-unstable"}, WithChecksNotification())
+
+	env.db.AddPR(90)
+
+	var head atomic.Value
+	head.Store("head1")
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/90", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 90, "title": "foo: init", "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false, "head": map[string]any{"sha": head.Load()},
+		})
+	})
+	var conclusion atomic.Value
+	conclusion.Store("failure")
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/commits/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"check_runs": []map[string]any{
+				{"name": "eval", "status": "completed", "conclusion": "success"},
+				{"name": "build", "status": "completed", "conclusion": conclusion.Load()},
+			},
+		})
+	})
+
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRChecksPassed {
+			events = append(events, e)
+		}
+	})
+
+	env.p.poll(context.Background())
+	if len(events) != 0 {
+		t.Fatalf("got %d PRChecksPassed events with a failing check, want 0", len(events))
+	}
+
+	conclusion.Store("success")
+	env.p.poll(context.Background())
+	env.p.poll(context.Background())
+	if len(events) != 1 {
+		t.Fatalf("got %d PRChecksPassed events after going green, want exactly 1", len(events))
+	}
+	if events[0].PRNumber != 90 || events[0].Commit != "head1" {
+		t.Errorf("event = %+v, want PR 90 at head1", events[0])
+	}
+
+	// A new push re-arms the notification.
+	head.Store("head2")
+	env.p.poll(context.Background())
+	if len(events) != 2 || events[1].Commit != "head2" {
+		t.Errorf("events = %+v, want a second event for head2", events)
+	}
+}
+
+func TestPollChecksDisabled(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(91)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/91", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 91, "title": "bar", "user": map[string]any{"login": "bob"},
+			"state": "open", "merged": false, "head": map[string]any{"sha": "head1"},
+		})
 	})
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/30", func(w http.ResponseWriter, r *http.Request) {
-		apiCalls.Add(1)
+	var checkCalls atomic.Int32
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/commits/", func(w http.ResponseWriter, r *http.Request) {
+		checkCalls.Add(1)
+	})
+
+	env.p.poll(context.Background())
+
+	if n := checkCalls.Load(); n != 0 {
+		t.Errorf("check-runs requests = %d, want 0 when disabled", n)
+	}
+}
+
+func TestPausedPollerSkipsGitHub(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(95)
+	var calls atomic.Int32
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/95", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
 		json.NewEncoder(w).Encode(map[string]any{
-			"number": 30, "title": "Should Not Reach", "user": map[string]any{"login": "z"},
+			"number": 95, "title": "paused", "user": map[string]any{"login": "alice"},
 			"state": "open", "merged": false,
 		})
 	})
 
+	env.p.Pause()
+	env.p.runPollCycle(context.Background())
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("GitHub calls while paused = %d, want 0", n)
+	}
+	if _, healthy := env.p.Health(); !healthy {
+		t.Error("expected paused poller to report healthy")
+	}
+
+	env.p.Resume()
+	env.p.runPollCycle(context.Background())
+	if n := calls.Load(); n != 1 {
+		t.Errorf("GitHub calls after resume = %d, want 1", n)
+	}
+}
+
+func TestPollChecksBranchAddedLater(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"}, []string{"nixos-unstable", "nixpkgs-unstable"})
+
+	env.db.AddPR(96)
+	env.db.UpdatePRStatus(96, "merged", "sha96", "baz: 1 -> 2", "alice")
+
+	var checked sync.Map
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		checked.Store(r.URL.Path, true)
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	env.p.poll(context.Background())
+	if _, ok := checked.Load("/repos/NixOS/nixpkgs/compare/nixpkgs-unstable...sha96"); ok {
+		t.Fatal("nixpkgs-unstable checked before it was configured")
+	}
+	if _, err := env.db.GetPR(96); err != nil {
+		t.Fatal("PR removed before landing in all target branches")
+	}
+
+	env.p.SetBranches([]string{"nixos-unstable", "nixpkgs-unstable"}, []string{"nixos-unstable", "nixpkgs-unstable"})
 	env.p.poll(context.Background())
 
-	if n := apiCalls.Load(); n != 1 {
-		t.Errorf("API calls = %d, want 1 (second PR should be skipped after rate limit)", n)
+	if _, ok := checked.Load("/repos/NixOS/nixpkgs/compare/nixpkgs-unstable...sha96"); !ok {
+		t.Error("expected newly added branch nixpkgs-unstable to be checked")
 	}
+	if _, err := env.db.GetPR(96); err == nil {
+		t.Error("expected PR to be auto-removed once the added branch landed")
+	}
+}
 
-	// Both PRs should still exist unmodified
-	pr31, err := env.db.GetPR(31)
-	if err != nil {
-		t.Fatalf("PR 31 should still exist: %v", err)
+func TestPollCompareNotFoundExplains(t *testing.T) {
+	tests := []struct {
+		name         string
+		branchExists bool
+		want         string
+	}{
+		{"bad branch", false, "pr=98 branch=nixos-unstable: branch nixos-unstable does not exist"},
+		{"unknown commit", true, "pr=98 branch=nixos-unstable: commit sha98 not found"},
 	}
-	if pr31.Status != "open" {
-		t.Errorf("PR 31 status = %q, want %q", pr31.Status, "open")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"nixos-unstable"})
+
+			env.db.AddPR(98)
+			env.db.UpdatePRStatus(98, "merged", "sha98", "typo", "alice")
+
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", http.NotFound)
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/branches/nixos-unstable", func(w http.ResponseWriter, r *http.Request) {
+				if !tt.branchExists {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"name": "nixos-unstable"})
+			})
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			env.p.poll(context.Background())
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("log output %q does not contain %q", buf.String(), tt.want)
+			}
+		})
 	}
+}
 
-	pr30, err := env.db.GetPR(30)
-	if err != nil {
-		t.Fatalf("PR 30 should still exist: %v", err)
+func TestPollLogsCarryPRAndBranch(t *testing.T) {
+	env := setupPoller(t, []string{"master", "nixos-unstable"})
+
+	env.db.AddPR(97)
+	env.db.UpdatePRStatus(97, "merged", "sha97", "foo: 1 -> 2", "alice")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/master...sha97", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha97", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	env.p.poll(context.Background())
+
+	for _, want := range []string{
+		"poller: pr=97 branch=master: commit sha97 found",
+		"poller: pr=97 branch=nixos-unstable: commit sha97 not yet landed",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output %q does not contain %q", buf.String(), want)
+		}
 	}
-	if pr30.Status != "open" {
-		t.Errorf("PR 30 status = %q, want %q", pr30.Status, "open")
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.Contains(line, "sha97") && !strings.Contains(line, "poller: pr=97 branch=") {
+			t.Errorf("log line %q lacks pr and branch fields", line)
+		}
 	}
 }
 
-func TestRunPollCycleBackoff(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable"})
+// An NPT_REPOS poller's lines name its repository, since the same PR number
+// can be tracked in several.
+func TestPollLogsCarryRepo(t *testing.T) {
+	env := setupPoller(t, []string{"master"})
+	otherDB := env.db.ForRepo("example/other")
+	other := New(otherDB, env.gh.ForRepo("example/other"), env.bus, time.Hour, []string{"main"}, []string{"main"})
 
-	env.db.AddPR(40)
+	otherDB.AddPR(97)
+	otherDB.UpdatePRStatus(97, "merged", "sha97", "foo: 1 -> 2", "alice")
 
-	// Rate limit resets 2 seconds from now (Unix timestamp has second granularity)
-	resetAt := time.Now().Add(2 * time.Second)
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/40", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-RateLimit-Remaining", "0")
-		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
-		w.WriteHeader(http.StatusForbidden)
+	env.ghMux.HandleFunc("/repos/example/other/compare/main...sha97", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
 	})
 
-	start := time.Now()
-	env.p.runPollCycle(context.Background())
-	elapsed := time.Since(start)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
 
-	// runPollCycle should have blocked until the reset time
-	if elapsed < 1*time.Second {
-		t.Errorf("runPollCycle returned too quickly (%v), expected backoff wait", elapsed)
+	other.poll(context.Background())
+
+	want := "poller: repo=example/other pr=97 branch=main: commit sha97 not yet landed"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log output %q does not contain %q", buf.String(), want)
 	}
 }
 
-func TestRunPollCycleBackoffContextCancel(t *testing.T) {
+func TestPollFailureThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		remove      bool
+		wantTracked bool
+	}{
+		{"event only", false, true},
+		{"event and remove", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"nixos-unstable"})
+			WithFailureThreshold(3, tt.remove)(env.p)
+
+			env.db.AddPR(404)
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/404", http.NotFound)
+
+			var errs, removed []event.Event
+			env.bus.Subscribe(func(e event.Event) {
+				switch e.Type {
+				case event.PRError:
+					errs = append(errs, e)
+				case event.PRRemoved:
+					removed = append(removed, e)
+				}
+			})
+
+			for i := 0; i < 2; i++ {
+				env.p.poll(context.Background())
+			}
+			if len(errs) != 0 {
+				t.Fatalf("pr_error fired after 2 failures, want it at 3")
+			}
+
+			// Further failures past the threshold must not repeat the event.
+			for i := 0; i < 3; i++ {
+				env.p.poll(context.Background())
+			}
+			if len(errs) != 1 {
+				t.Fatalf("got %d pr_error events, want 1", len(errs))
+			}
+			if errs[0].PRNumber != 404 || errs[0].Error == "" {
+				t.Errorf("pr_error = %+v, want PR 404 with an error message", errs[0])
+			}
+
+			_, err := env.db.GetPR(404)
+			if tracked := err == nil; tracked != tt.wantTracked {
+				t.Errorf("tracked = %v, want %v", tracked, tt.wantTracked)
+			}
+			if wantRemoved := !tt.wantTracked; (len(removed) == 1) != wantRemoved {
+				t.Errorf("got %d pr_removed events, want removed=%v", len(removed), wantRemoved)
+			}
+		})
+	}
+}
+
+// A PR removed and added again starts counting failures from zero.
+func TestForgetResetsFailures(t *testing.T) {
 	env := setupPoller(t, []string{"nixos-unstable"})
+	WithFailureThreshold(3, false)(env.p)
 
-	env.db.AddPR(41)
+	env.db.AddPR(404)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/404", http.NotFound)
 
-	// Rate limit resets far in the future
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/41", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-RateLimit-Remaining", "0")
-		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(1*time.Hour).Unix()))
-		w.WriteHeader(http.StatusForbidden)
+	var errs int
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRError {
+			errs++
+		}
 	})
 
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		cancel()
-	}()
+	env.p.poll(context.Background())
+	env.p.poll(context.Background())
+
+	env.db.RemovePR(404)
+	env.p.Forget(404)
+	env.db.AddPR(404)
+
+	env.p.poll(context.Background())
+	env.p.poll(context.Background())
+	if errs != 0 {
+		t.Errorf("got %d pr_error events, want none after 2 failures since re-adding", errs)
+	}
+}
+
+func TestPollCompareTimeout(t *testing.T) {
+	env := setupPoller(t, []string{"master", "nixos-unstable"})
+	WithCompareTimeout(50 * time.Millisecond)(env.p)
+
+	env.db.AddPR(30)
+	env.db.UpdatePRStatus(30, "merged", "sha30", "Slow", "alice")
+	env.db.AddPR(31)
+	env.db.UpdatePRStatus(31, "merged", "sha31", "Fast", "bob")
+
+	// master hangs for every PR; nixos-unstable answers at once.
+	for _, sha := range []string{"sha30", "sha31"} {
+		env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/master..."+sha, func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		})
+		env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable..."+sha, func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+		})
+	}
 
 	start := time.Now()
-	env.p.runPollCycle(ctx)
-	elapsed := time.Since(start)
+	env.p.poll(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cycle took %s, want the hung compares cut off", elapsed)
+	}
 
-	// Should unblock quickly after cancel, not wait the full hour
-	if elapsed > 2*time.Second {
-		t.Errorf("runPollCycle took %v after context cancel, expected fast return", elapsed)
+	for _, n := range []int{30, 31} {
+		pr, err := env.db.GetPR(n)
+		if err != nil {
+			t.Fatalf("GetPR(%d): %v", n, err)
+		}
+		for _, bs := range pr.Branches {
+			if bs.Branch == "master" && bs.Landed {
+				t.Errorf("PR %d: master landed despite the timed out compare", n)
+			}
+			if bs.Branch == "nixos-unstable" && !bs.Landed {
+				t.Errorf("PR %d: nixos-unstable not checked after master timed out", n)
+			}
+		}
+	}
+
+	// Each timed out PR still counts against the error budget.
+	if env.p.cycleErrors != 2 {
+		t.Errorf("cycleErrors = %d, want 2", env.p.cycleErrors)
 	}
 }
 
-func TestPollSkipsUpstreamBranches(t *testing.T) {
-	// Track staging, staging-next, master, and nixos-unstable.
-	// master has already landed, so staging and staging-next should be skipped.
-	env := setupPoller(t, []string{"staging", "staging-next", "master", "nixos-unstable"})
+func TestPollBranchConcurrency(t *testing.T) {
+	branches := []string{"release-a", "release-b", "release-c", "release-d", "release-e", "release-f"}
+	env := setupPoller(t, branches)
+	WithBranchConcurrency(3)(env.p)
 
-	env.db.AddPR(50)
-	env.db.UpdatePRStatus(50, "merged", "commitXYZ", "Skip Upstream", "alice")
-	env.db.UpdateBranchLanded(50, "master") // master already landed
+	env.db.AddPR(40)
+	env.db.UpdatePRStatus(40, "merged", "sha40", "Wide", "alice")
+
+	reached := map[string]bool{"release-a": true, "release-c": true, "release-d": true, "release-f": true}
+	var inflight, maxInflight atomic.Int32
+	for _, branch := range branches {
+		env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/"+branch+"...sha40", func(w http.ResponseWriter, r *http.Request) {
+			n := inflight.Add(1)
+			defer inflight.Add(-1)
+			for {
+				m := maxInflight.Load()
+				if n <= m || maxInflight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			status := "ahead"
+			if reached[branch] {
+				status = "behind"
+			}
+			json.NewEncoder(w).Encode(map[string]any{"status": status})
+		})
+	}
 
-	var compareMu sync.Mutex
-	checkedBranches := make(map[string]bool)
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
-		// Extract branch name from URL: /repos/NixOS/nixpkgs/compare/{branch}...{commit}
-		path := r.URL.Path
-		// path looks like /repos/NixOS/nixpkgs/compare/nixos-unstable...commitXYZ
-		branch := path[len("/repos/NixOS/nixpkgs/compare/"):]
-		if idx := len(branch) - len("...commitXYZ"); idx > 0 {
-			branch = branch[:idx]
+	var landed []string
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRLandedBranch {
+			landed = append(landed, e.Branch)
 		}
-		compareMu.Lock()
-		checkedBranches[branch] = true
-		compareMu.Unlock()
-		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed
 	})
 
-	env.p.poll(context.Background())
-
-	compareMu.Lock()
-	defer compareMu.Unlock()
+	pr, err := env.db.GetPR(40)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if err := env.p.pollPR(context.Background(), *pr); err != nil {
+		t.Fatalf("pollPR: %v", err)
+	}
 
-	// staging and staging-next are upstream of master, should be skipped
-	if checkedBranches["staging"] {
-		t.Error("staging should have been skipped (upstream of landed master)")
+	if n := maxInflight.Load(); n < 2 || n > 3 {
+		t.Errorf("max concurrent compares = %d, want 2 or 3", n)
 	}
-	if checkedBranches["staging-next"] {
-		t.Error("staging-next should have been skipped (upstream of landed master)")
+	// Events follow the branch order regardless of which compare finished
+	// first.
+	if got := strings.Join(landed, ","); got != "release-a,release-c,release-d,release-f" {
+		t.Errorf("landed events = %q, want release-a,release-c,release-d,release-f", got)
 	}
-	// nixos-unstable should still be checked
-	if !checkedBranches["nixos-unstable"] {
-		t.Error("nixos-unstable should have been checked")
+	pr, err = env.db.GetPR(40)
+	if err != nil {
+		t.Fatalf("GetPR after poll: %v", err)
+	}
+	if len(pr.Branches) != len(branches) {
+		t.Fatalf("branch statuses = %d, want %d", len(pr.Branches), len(branches))
+	}
+	for _, bs := range pr.Branches {
+		if bs.Landed != reached[bs.Branch] {
+			t.Errorf("%s: landed = %v, want %v", bs.Branch, bs.Landed, reached[bs.Branch])
+		}
 	}
 }
 
-func TestRunPollCycleResetInPast(t *testing.T) {
-	env := setupPoller(t, []string{"nixos-unstable"})
+func TestPollBranchConcurrencyStopsDownstream(t *testing.T) {
+	branches := []string{"staging", "master", "nixos-unstable-small", "nixos-unstable", "nixpkgs-unstable"}
+	tests := []struct {
+		name         string
+		status       map[string]string // compare status per branch; unlisted are "ahead"
+		wantCompared string
+		wantLanded   string
+	}{
+		{
+			// staging never gets a master PR, so it gates nothing; once
+			// nixos-unstable-small is missing it, nixos-unstable can't have it.
+			name:         "stops below the first branch without it",
+			status:       map[string]string{"staging": "diverged", "master": "behind", "nixpkgs-unstable": "behind"},
+			wantCompared: "master,nixos-unstable-small,nixpkgs-unstable,staging",
+			wantLanded:   "master,nixpkgs-unstable",
+		},
+		{
+			name:         "not in the base",
+			status:       map[string]string{"staging": "diverged"},
+			wantCompared: "master,staging",
+		},
+	}
+	for _, tt := range tests {
+		for _, concurrency := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s/concurrency %d", tt.name, concurrency), func(t *testing.T) {
+				env := setupPoller(t, branches)
+				WithBranchConcurrency(concurrency)(env.p)
+
+				env.db.AddPR(42)
+				env.db.UpdatePRStatus(42, "merged", "sha42", "Piped", "alice")
+				env.db.UpdatePRBase(42, "master")
+
+				var mu sync.Mutex
+				var compared []string
+				for _, branch := range branches {
+					env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/"+branch+"...sha42", func(w http.ResponseWriter, r *http.Request) {
+						mu.Lock()
+						compared = append(compared, branch)
+						mu.Unlock()
+						status := tt.status[branch]
+						if status == "" {
+							status = "ahead"
+						}
+						json.NewEncoder(w).Encode(map[string]any{"status": status})
+					})
+				}
+
+				pr, err := env.db.GetPR(42)
+				if err != nil {
+					t.Fatalf("GetPR: %v", err)
+				}
+				if err := env.p.pollPR(context.Background(), *pr); err != nil {
+					t.Fatalf("pollPR: %v", err)
+				}
+
+				slices.Sort(compared)
+				if got := strings.Join(compared, ","); got != tt.wantCompared {
+					t.Errorf("compared %q, want %q", got, tt.wantCompared)
+				}
+				pr, err = env.db.GetPR(42)
+				if err != nil {
+					t.Fatalf("GetPR after poll: %v", err)
+				}
+				var landed []string
+				for _, bs := range pr.Branches {
+					if bs.Landed {
+						landed = append(landed, bs.Branch)
+					}
+				}
+				slices.Sort(landed)
+				if got := strings.Join(landed, ","); got != tt.wantLanded {
+					t.Errorf("landed %q, want %q", got, tt.wantLanded)
+				}
+			})
+		}
+	}
+}
 
-	env.db.AddPR(42)
+func TestPollBranchConcurrencyRateLimit(t *testing.T) {
+	branches := []string{"release-a", "release-b", "release-c", "release-d"}
+	env := setupPoller(t, branches)
+	WithBranchConcurrency(4)(env.p)
 
-	// Rate limit reset already in the past
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/42", func(w http.ResponseWriter, r *http.Request) {
+	env.db.AddPR(41)
+	env.db.UpdatePRStatus(41, "merged", "sha41", "Limited", "alice")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/release-b...sha41", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-RateLimit-Remaining", "0")
-		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(-1*time.Minute).Unix()))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(30*time.Minute).Unix(), 10))
 		w.WriteHeader(http.StatusForbidden)
 	})
+	// The other branches would land, but only after the rate limit has
+	// cancelled them.
+	for _, branch := range []string{"release-a", "release-c", "release-d"} {
+		env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/"+branch+"...sha41", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+		})
+	}
 
+	pr, err := env.db.GetPR(41)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
 	start := time.Now()
-	env.p.runPollCycle(context.Background())
-	elapsed := time.Since(start)
+	err = env.p.pollPR(context.Background(), *pr)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("pollPR took %s, want the other compares cancelled", elapsed)
+	}
+	var rlErr *github.RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("pollPR error = %v, want a RateLimitError", err)
+	}
 
-	// Should return immediately since reset is in the past
-	if elapsed > 500*time.Millisecond {
-		t.Errorf("runPollCycle took %v for past reset time, expected immediate return", elapsed)
+	pr, err = env.db.GetPR(41)
+	if err != nil {
+		t.Fatalf("GetPR after poll: %v", err)
+	}
+	for _, bs := range pr.Branches {
+		if bs.Landed {
+			t.Errorf("%s landed despite the cancelled compare", bs.Branch)
+		}
 	}
 }
 
-func TestPollTargetBranchesAutoRemove(t *testing.T) {
-	// Track staging + nixos-unstable as notification branches,
-	// but only nixos-unstable is a target branch.
-	// PR lands in nixos-unstable but not staging → should be auto-removed.
-	env := setupPoller(t,
-		[]string{"staging", "nixos-unstable"},
-		[]string{"nixos-unstable"},
-	)
+func TestPollRateReserve(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining string
+		want      []string
+	}{
+		// Tracked PRs are listed newest first; open ones are moved ahead.
+		// Commits come last and wait like the merged PRs.
+		{"plenty left", "500", []string{"pulls/4", "pulls/2", "compare/nixos-unstable...sha3", "compare/nixos-unstable...sha1", "compare/nixos-unstable...commitX"}},
+		{"below reserve", "5", []string{"pulls/4", "pulls/2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"nixos-unstable"})
+			WithRateReserve(10)(env.p)
+
+			env.db.AddPR(1)
+			env.db.UpdatePRStatus(1, "merged", "sha1", "Merged one", "alice")
+			env.db.AddPR(2)
+			env.db.AddPR(3)
+			env.db.UpdatePRStatus(3, "merged", "sha3", "Merged three", "alice")
+			env.db.AddPR(4)
+			env.db.AddCommit("commitX", "")
+
+			var requests []string
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/", func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, strings.TrimPrefix(r.URL.Path, "/repos/NixOS/nixpkgs/"))
+				w.Header().Set("X-RateLimit-Remaining", tt.remaining)
+				if strings.Contains(r.URL.Path, "/compare/") {
+					json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"state": "open", "title": "Open"})
+			})
+
+			env.p.poll(context.Background())
+
+			if strings.Join(requests, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("requests = %v, want %v", requests, tt.want)
+			}
+			if got := len(env.p.resumePRs) == 2; got != (tt.remaining == "5") {
+				t.Errorf("resumePRs = %v, want the merged PRs deferred only below the reserve", env.p.resumePRs)
+			}
+		})
+	}
+}
 
-	env.db.AddPR(60)
-	env.db.UpdatePRStatus(60, "merged", "commitFIN", "Target Branch Test", "alice")
+func TestPollCycleDeferredByRateLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining string
+		wantPoll  bool
+	}{
+		{"enough left", "4", true},
+		{"too few left", "3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupPoller(t, []string{"nixos-unstable"})
+			now := time.Now()
+			env.p.now = func() time.Time { return now }
+
+			var polled atomic.Int32
+			env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/", func(w http.ResponseWriter, r *http.Request) {
+				polled.Add(1)
+				w.Header().Set("X-RateLimit-Remaining", tt.remaining)
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(30*time.Minute).Unix(), 10))
+				json.NewEncoder(w).Encode(map[string]any{"state": "open", "title": "Open"})
+			})
+			// An earlier request leaves the rate limit on record.
+			env.gh.GetPR(context.Background(), 99)
+			polled.Store(0)
+
+			// Three PRs and a commit need at least four requests; the
+			// landed PR needs none.
+			for _, n := range []int{1, 2, 3, 4} {
+				env.db.AddPR(n)
+			}
+			env.db.UpdatePRStatus(4, "landed", "sha4", "Landed", "alice")
+			env.db.AddCommit("commitX", "")
+
+			// The check uses the cycle's listing rather than reading again.
+			var lists int
+			env.p.listPRs = func() ([]db.TrackedPR, error) {
+				lists++
+				return env.db.ListPRs()
+			}
+
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			env.p.runPollCycle(context.Background())
+
+			if got := polled.Load() > 0; got != tt.wantPoll {
+				t.Errorf("polled %d PRs, want polled = %v", polled.Load(), tt.wantPoll)
+			}
+			if lists != 1 {
+				t.Errorf("listed PRs %d times, want once per cycle", lists)
+			}
+			if got := strings.Contains(logs.String(), "3 GitHub requests left until the rate limit resets"); got == tt.wantPoll {
+				t.Errorf("logs = %q, want a deferral message = %v", logs.String(), !tt.wantPoll)
+			}
+		})
+	}
+}
 
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/staging...commitFIN", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"}) // not landed
+func TestPollErrorBudget(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	WithErrorBudget(3)(env.p)
+
+	for n := 1; n <= 20; n++ {
+		env.db.AddPR(n)
+	}
+	env.db.AddCommit("commitXYZ", "")
+
+	var calls atomic.Int32
+	env.ghMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unavailable", http.StatusBadGateway)
 	})
-	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...commitFIN", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(map[string]any{"status": "behind"}) // landed
+
+	start := time.Now()
+	env.p.poll(context.Background())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cycle took %s, want it to stop promptly", elapsed)
+	}
+
+	if n := calls.Load(); n != 3 {
+		t.Errorf("GitHub calls = %d, want 3 (the budget), with commits skipped too", n)
+	}
+	if len(env.p.resumePRs) != 17 {
+		t.Errorf("%d PRs deferred to the next cycle, want 17", len(env.p.resumePRs))
+	}
+
+	// The budget is per cycle.
+	calls.Store(0)
+	env.p.poll(context.Background())
+	if n := calls.Load(); n != 3 {
+		t.Errorf("second cycle GitHub calls = %d, want 3", n)
+	}
+}
+
+func TestPollTransientErrorsDontCountAsFailures(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	WithFailureThreshold(1, true)(env.p)
+
+	env.db.AddPR(8)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "200")
+		io.WriteString(w, `{"number": 8, "title": "Trunc`)
+	})
+
+	var errs int
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRError {
+			errs++
+		}
 	})
 
 	env.p.poll(context.Background())
 
-	// PR should be auto-removed because nixos-unstable (the only target branch) has landed
-	_, err := env.db.GetPR(60)
-	if err == nil {
-		t.Error("expected PR to be auto-removed after landing in all target branches")
+	if errs != 0 {
+		t.Errorf("got %d pr_error events for a truncated response, want 0", errs)
+	}
+	if _, err := env.db.GetPR(8); err != nil {
+		t.Errorf("PR 8 should still be tracked: %v", err)
+	}
+}
+
+func TestPollFailureCountResetsOnSuccess(t *testing.T) {
+	env := setupPoller(t, []string{"nixos-unstable"})
+	WithFailureThreshold(2, false)(env.p)
+
+	env.db.AddPR(7)
+	var fail atomic.Bool
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"state": "open", "title": "t", "user": map[string]any{"login": "a"}})
+	})
+
+	var errs int
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRError {
+			errs++
+		}
+	})
+
+	fail.Store(true)
+	env.p.poll(context.Background())
+	fail.Store(false)
+	env.p.poll(context.Background())
+	fail.Store(true)
+	env.p.poll(context.Background())
+
+	if errs != 0 {
+		t.Errorf("got %d pr_error events, want 0 since failures were not consecutive", errs)
 	}
 }
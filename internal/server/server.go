@@ -1,42 +1,412 @@
 package server
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"html/template"
+	"io"
 	"log"
+	"maps"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/db"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/github"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/poller"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/topology"
 )
 
+// restoreWindow is how long a manually removed PR can be restored with
+// POST /api/prs/{number}/restore.
+const restoreWindow = 15 * time.Minute
+
+// maxCheckPRs bounds how many PRs one POST /api/check may ask about, since
+// each costs a GitHub request plus one per branch.
+const maxCheckPRs = 20
+
+// tombstone is the last known state of a manually removed PR.
+type tombstone struct {
+	pr        db.TrackedPR
+	removedAt time.Time
+}
+
+// tombstoneKey identifies a removed PR by repository ("" for the primary
+// one) and number.
+type tombstoneKey struct {
+	repo   string
+	number int
+}
+
+// shaPattern matches abbreviated or full hex commit SHAs.
+var shaPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
 type Server struct {
+	db     *db.DB
+	gh     *github.Client
+	bus    *event.Bus
+	poller *poller.Poller
+	tmpl   *template.Template
+
+	readOnly      bool
+	separateAdmin bool     // admin endpoints are served by AdminRoutes only
+	includeBody   bool     // store PR descriptions and attach them to events
+	trackPaths    []string // a PR must touch one of these to be added; empty means any
+
+	// githubWebhookSecret verifies POST /api/github/webhook deliveries; the
+	// endpoint is only served when it is set.
+	githubWebhookSecret string
+
+	authToken   string   // required on writes when set, see WithAuthToken
+	corsOrigins []string // browser origins allowed to call the API
+
+	// repos are the NPT_REPOS repositories, by "owner/name"; see WithRepo.
+	repos map[string]repoTarget
+
+	mu                   sync.RWMutex // guards the branch lists, tombstones and debugConfig
+	notificationBranches []string
+	targetBranches       []string
+	tombstones           map[tombstoneKey]tombstone
+	debugConfig          map[string]any
+
+	// indexTTL is how long the index page reuses a PR list; zero disables
+	// the cache. indexPRs is dropped on every published event, since an
+	// event means some PR changed.
+	indexTTL     time.Duration
+	indexMu      sync.Mutex // guards indexPRs and indexExpires
+	indexPRs     []db.TrackedPR
+	indexExpires time.Time
+
+	now func() time.Time
+
+	// listPRs reads the tracked PRs for the index; it is db.ListPRs outside
+	// of tests.
+	listPRs func() ([]db.TrackedPR, error)
+}
+
+// repoTarget is where one repository's PRs are tracked.
+type repoTarget struct {
+	repo                 string // empty for NPT_GITHUB_REPO
 	db                   *db.DB
 	gh                   *github.Client
-	bus                  *event.Bus
+	poller               *poller.Poller
 	notificationBranches []string
 	targetBranches       []string
-	tmpl                 *template.Template
 }
 
-func New(database *db.DB, gh *github.Client, bus *event.Bus, notificationBranches []string, targetBranches []string, tmpl *template.Template) *Server {
-	return &Server{
-		db:                   database,
-		gh:                   gh,
-		bus:                  bus,
-		notificationBranches: notificationBranches,
-		targetBranches:       targetBranches,
-		tmpl:                 tmpl,
+// Option configures optional Server behavior.
+type Option func(*Server)
+
+// WithRepo lets POST /api/prs and GET /api/prs work on the PRs of repo, an
+// NPT_REPOS repository, when the request names it. database and gh must be
+// scoped to repo, see db.DB.ForRepo and github.Client.ForRepo, and p polls
+// them; branches are its notification and target branches.
+func WithRepo(repo string, database *db.DB, gh *github.Client, p *poller.Poller, branches []string) Option {
+	return func(s *Server) {
+		if s.repos == nil {
+			s.repos = make(map[string]repoTarget)
+		}
+		s.repos[repo] = repoTarget{repo: repo, db: database, gh: gh, poller: p, notificationBranches: branches, targetBranches: branches}
+	}
+}
+
+// WithBranches sets the branches a PR added through the API is checked
+// against. SetBranches replaces them later.
+func WithBranches(notificationBranches, targetBranches []string) Option {
+	return func(s *Server) {
+		s.notificationBranches = notificationBranches
+		s.targetBranches = targetBranches
 	}
 }
 
+// WithTemplate renders the HTML pages from tmpl, which defines
+// "index.html" and "detail.html". Without it the pages are empty.
+func WithTemplate(tmpl *template.Template) Option {
+	return func(s *Server) {
+		s.tmpl = tmpl
+	}
+}
+
+// WithAuthToken requires every request other than GET and HEAD to carry
+// "Authorization: Bearer <token>", and answers 401 otherwise. POST
+// /api/github/webhook is exempt: its deliveries are signed instead.
+func WithAuthToken(token string) Option {
+	return func(s *Server) {
+		s.authToken = token
+	}
+}
+
+// WithCORS lets pages from origins, e.g. "https://dash.example.com", call
+// the API from a browser; "*" allows any origin. Preflight requests from
+// them are answered directly.
+func WithCORS(origins ...string) Option {
+	return func(s *Server) {
+		s.corsOrigins = origins
+	}
+}
+
+// New returns a Server for the tracked PRs in database. Everything else is
+// set with opts or, for settings not yet moved to options, the Set methods
+// before Routes is called.
+func New(database *db.DB, gh *github.Client, bus *event.Bus, p *poller.Poller, opts ...Option) *Server {
+	s := &Server{
+		db:         database,
+		gh:         gh,
+		bus:        bus,
+		poller:     p,
+		tmpl:       template.New(""),
+		tombstones: make(map[tombstoneKey]tombstone),
+		now:        time.Now,
+		listPRs:    database.ListPRs,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	bus.Subscribe(func(event.Event) { s.invalidateIndex() })
+	return s
+}
+
+// SetBranches replaces the branches checked when a PR is added.
+func (s *Server) SetBranches(notificationBranches, targetBranches []string) {
+	s.mu.Lock()
+	s.notificationBranches = notificationBranches
+	s.targetBranches = targetBranches
+	s.mu.Unlock()
+}
+
+// SetReadOnly makes every request other than GET and HEAD fail with 403,
+// e.g. for a public status page. It must be called before Routes.
+func (s *Server) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// SetSeparateAdmin moves /healthz and the /api/poller endpoints from Routes
+// to AdminRoutes, so they can be served on an internal listener. It must be
+// called before Routes.
+func (s *Server) SetSeparateAdmin(separate bool) {
+	s.separateAdmin = separate
+}
+
+// SetIncludeBody makes added PRs keep an excerpt of their description and
+// attaches it to the events they publish, for notifications. It must be
+// called before Routes.
+func (s *Server) SetIncludeBody(include bool) {
+	s.includeBody = include
+}
+
+// SetTrackPaths makes POST /api/prs reject PRs that change no file under
+// any of paths, e.g. "pkgs/by-name/fo/foo". It must be called before Routes.
+func (s *Server) SetTrackPaths(paths []string) {
+	s.trackPaths = paths
+}
+
+// SetGitHubWebhookSecret serves POST /api/github/webhook for GitHub webhook
+// deliveries signed with secret. It must be called before Routes.
+func (s *Server) SetGitHubWebhookSecret(secret string) {
+	s.githubWebhookSecret = secret
+}
+
+// SetDebugConfig sets the effective configuration GET /api/debug/config
+// returns. It must already have its secrets redacted.
+func (s *Server) SetDebugConfig(cfg map[string]any) {
+	s.mu.Lock()
+	s.debugConfig = cfg
+	s.mu.Unlock()
+}
+
+// eventBody returns body for an event's Body, or nothing unless
+// SetIncludeBody is on.
+func (s *Server) eventBody(body string) string {
+	if !s.includeBody {
+		return ""
+	}
+	return body
+}
+
+// SetIndexCacheTTL lets the index page reuse the PR list for up to ttl, so
+// a frequently refreshed dashboard doesn't re-read every PR on each load.
+// Any published event drops the cached list early. Zero disables caching.
+func (s *Server) SetIndexCacheTTL(ttl time.Duration) {
+	s.indexMu.Lock()
+	s.indexTTL = ttl
+	s.indexPRs = nil
+	s.indexMu.Unlock()
+}
+
+// indexList returns the PRs for the index page, from the cache while it is
+// fresh.
+func (s *Server) indexList() ([]db.TrackedPR, error) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if s.indexPRs != nil && s.now().Before(s.indexExpires) {
+		return s.indexPRs, nil
+	}
+	prs, err := s.listPRs()
+	if err != nil {
+		return nil, err
+	}
+	if s.indexTTL > 0 {
+		if prs == nil {
+			prs = []db.TrackedPR{}
+		}
+		s.indexPRs = prs
+		s.indexExpires = s.now().Add(s.indexTTL)
+	}
+	return prs, nil
+}
+
+// publish drops the cached index before publishing e, so a page loaded right
+// after a change made through the API shows it even on an async bus.
+func (s *Server) publish(e event.Event) {
+	s.invalidateIndex()
+	s.bus.Publish(e)
+}
+
+func (s *Server) invalidateIndex() {
+	s.indexMu.Lock()
+	s.indexPRs = nil
+	s.indexMu.Unlock()
+}
+
+func (s *Server) branches() (notificationBranches, targetBranches []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notificationBranches, s.targetBranches
+}
+
+// target returns where repo's PRs are tracked: the primary repository for
+// "", or one added with WithRepo. It returns false for any other repo.
+func (s *Server) target(repo string) (repoTarget, bool) {
+	if repo == "" {
+		notificationBranches, targetBranches := s.branches()
+		return repoTarget{db: s.db, gh: s.gh, poller: s.poller, notificationBranches: notificationBranches, targetBranches: targetBranches}, true
+	}
+	t, ok := s.repos[repo]
+	return t, ok
+}
+
+// pollers returns the poller of NPT_GITHUB_REPO followed by those of the
+// NPT_REPOS repositories, in name order.
+func (s *Server) pollers() []*poller.Poller {
+	pollers := []*poller.Poller{s.poller}
+	for _, repo := range slices.Sorted(maps.Keys(s.repos)) {
+		pollers = append(pollers, s.repos[repo].poller)
+	}
+	return pollers
+}
+
+// queryTarget resolves the ?repo= parameter of a request on one tracked PR,
+// answering 400 for a repository that isn't configured.
+func (s *Server) queryTarget(w http.ResponseWriter, r *http.Request) (repoTarget, bool) {
+	t, ok := s.target(r.URL.Query().Get("repo"))
+	if !ok {
+		http.Error(w, `{"error":"unknown repo"}`, http.StatusBadRequest)
+	}
+	return t, ok
+}
+
+// IndexData is what the index page template renders: the branch columns,
+// notification branches in configured order followed by any other branch a
+// PR has a landing recorded in, and one row per tracked PR.
+type IndexData struct {
+	Branches []string
+	PRs      []IndexRow
+}
+
+// IndexRow is a tracked PR on the index page. Cells line up with
+// IndexData.Branches; a branch the PR has no record for is not landed.
+type IndexRow struct {
+	db.TrackedPR
+	Cells []db.BranchStatus
+}
+
+// matrixColumns returns the branch columns for prs: notificationBranches in
+// order, then any other recorded branch, sorted.
+func matrixColumns(notificationBranches []string, prs []db.TrackedPR) []string {
+	columns := slices.Clone(notificationBranches)
+	seen := make(map[string]bool, len(columns))
+	for _, b := range columns {
+		seen[b] = true
+	}
+	var extra []string
+	for _, pr := range prs {
+		for _, bs := range pr.Branches {
+			if !seen[bs.Branch] {
+				seen[bs.Branch] = true
+				extra = append(extra, bs.Branch)
+			}
+		}
+	}
+	slices.Sort(extra)
+	return append(columns, extra...)
+}
+
+// matrixCells returns pr's branch status for each of columns.
+func matrixCells(pr db.TrackedPR, columns []string) []db.BranchStatus {
+	recorded := make(map[string]db.BranchStatus, len(pr.Branches))
+	for _, bs := range pr.Branches {
+		recorded[bs.Branch] = bs
+	}
+	cells := make([]db.BranchStatus, len(columns))
+	for i, b := range columns {
+		cells[i] = recorded[b]
+		cells[i].Branch = b
+	}
+	return cells
+}
+
 type PRDetailData struct {
 	PR       *db.TrackedPR
 	Pipeline topology.Pipeline
+	Repo     string // "owner/name", for GitHub links
+	Pending  []BranchCheck
+
+	// ViaStaging is set when the PR targets a staging-style branch, so the
+	// page can explain why landings take longer.
+	ViaStaging bool
+}
+
+// BranchCheck is the last compare result for a branch a PR hasn't landed in.
+type BranchCheck struct {
+	Branch  string
+	Status  string // GitHub compare status, e.g. "ahead"
+	Meaning string // human-readable explanation of Status
+}
+
+// compareMeaning explains a compare status of a merge commit against a
+// branch in the words the detail page uses.
+func compareMeaning(status string) string {
+	switch status {
+	case "ahead":
+		return "merge not yet in the branch"
+	case "diverged":
+		return "merge not yet in the branch, which has moved on separately"
+	case "behind", "identical":
+		return "merge is in the branch, but this status is not counted as landed"
+	}
+	return "unrecognised compare status"
+}
+
+// upstreamOfLanded reports whether branch is upstream of any landed branch
+// in branches.
+func upstreamOfLanded(branch string, branches []db.BranchStatus) bool {
+	for _, bs := range branches {
+		if bs.Landed && topology.IsUpstreamOf(branch, bs.Branch) {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Server) Routes() http.Handler {
@@ -45,8 +415,112 @@ func (s *Server) Routes() http.Handler {
 	mux.HandleFunc("GET /pr/{number}", s.handlePRDetail)
 	mux.HandleFunc("POST /api/prs", s.handleAddPR)
 	mux.HandleFunc("GET /api/prs", s.handleListPRs)
+	mux.HandleFunc("GET /api/archive", s.handleListArchive)
 	mux.HandleFunc("DELETE /api/prs/{number}", s.handleDeletePR)
-	return mux
+	mux.HandleFunc("POST /api/prs/{number}/refresh", s.handleRefreshPR)
+	mux.HandleFunc("POST /api/prs/{number}/restore", s.handleRestorePR)
+	mux.HandleFunc("POST /api/prs/{number}/reset", s.handleResetPR)
+	mux.HandleFunc("POST /api/commits", s.handleAddCommit)
+	mux.HandleFunc("GET /api/commits", s.handleListCommits)
+	mux.HandleFunc("GET /api/commits/{sha}", s.handleGetCommitPR)
+	mux.HandleFunc("DELETE /api/commits/{sha}", s.handleDeleteCommit)
+	mux.HandleFunc("GET /api/config", s.handleConfig)
+	mux.HandleFunc("GET /api/matrix", s.handleMatrix)
+	mux.HandleFunc("GET /api/feed.atom", s.handleFeed)
+	mux.HandleFunc("POST /api/check", s.handleCheck)
+	if s.githubWebhookSecret != "" {
+		mux.HandleFunc("POST /api/github/webhook", s.handleGitHubWebhook)
+	}
+	if !s.separateAdmin {
+		s.adminRoutes(mux)
+	}
+	return s.wrap(mux)
+}
+
+// AdminRoutes serves only the health check, poller and debug admin
+// endpoints, for a listener of their own (see SetSeparateAdmin).
+func (s *Server) AdminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	s.adminRoutes(mux)
+	return s.wrap(mux)
+}
+
+// wrap adds the read-only, auth and CORS handling the options ask for
+// around h. CORS goes outermost so preflight requests are answered before
+// any write check.
+func (s *Server) wrap(h http.Handler) http.Handler {
+	if s.readOnly {
+		h = rejectWrites(h)
+	}
+	if s.authToken != "" {
+		h = requireToken(s.authToken, h)
+	}
+	if len(s.corsOrigins) > 0 {
+		h = allowOrigins(s.corsOrigins, h)
+	}
+	return h
+}
+
+func (s *Server) adminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/poller/pause", s.handlePausePoller)
+	mux.HandleFunc("POST /api/poller/resume", s.handleResumePoller)
+	mux.HandleFunc("POST /api/poller/run", s.handleRunPoller)
+	mux.HandleFunc("GET /api/poller/status", s.handlePollerStatus)
+	mux.HandleFunc("GET /api/debug/config", s.handleDebugConfig)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+}
+
+// rejectWrites answers anything but GET and HEAD with 403. POST /api/check
+// is let through: it only reads from GitHub.
+func rejectWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.URL.Path != "/api/check" {
+			http.Error(w, `{"error":"read-only mode"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireToken answers anything but GET and HEAD with 401 unless it carries
+// token as a bearer token. GitHub webhook deliveries are let through, and
+// GET /api/debug/config needs the token too, since it shows the setup.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if (read && r.URL.Path != "/api/debug/config") || r.URL.Path == "/api/github/webhook" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, `{"error":"missing or wrong API token"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowOrigins adds CORS headers for requests from origins and answers their
+// preflight requests.
+func allowOrigins(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !(slices.Contains(origins, "*") || slices.Contains(origins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -55,15 +529,21 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	prs, err := s.db.ListPRs()
+	prs, err := s.indexList()
 	if err != nil {
 		log.Printf("server: listing PRs: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
+	notificationBranches, _ := s.branches()
+	data := IndexData{Branches: matrixColumns(notificationBranches, prs)}
+	for _, pr := range prs {
+		data.PRs = append(data.PRs, IndexRow{TrackedPR: pr, Cells: matrixCells(pr, data.Branches)})
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.tmpl.ExecuteTemplate(w, "index.html", prs); err != nil {
+	if err := s.tmpl.ExecuteTemplate(w, "index.html", data); err != nil {
 		log.Printf("server: rendering template: %v", err)
 	}
 }
@@ -83,13 +563,27 @@ func (s *Server) handlePRDetail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	trackedBranches := make(map[string]*time.Time, len(pr.Branches))
+	var pending []BranchCheck
 	for _, bs := range pr.Branches {
+		if !bs.Landed && upstreamOfLanded(bs.Branch, pr.Branches) {
+			// The poller stops checking a branch once one downstream of it
+			// lands, so its last status is stale; let the pipeline show it
+			// as skipped.
+			continue
+		}
 		trackedBranches[bs.Branch] = bs.LandedAt
+		if !bs.Landed && bs.LastStatus != "" {
+			pending = append(pending, BranchCheck{Branch: bs.Branch, Status: bs.LastStatus, Meaning: compareMeaning(bs.LastStatus)})
+		}
 	}
 
 	data := PRDetailData{
 		PR:       pr,
 		Pipeline: topology.BuildPipeline(trackedBranches),
+		Repo:     s.gh.Repo(),
+		Pending:  pending,
+
+		ViaStaging: topology.IsStagingBranch(pr.BaseRef),
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -98,28 +592,78 @@ func (s *Server) handlePRDetail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// touchesPaths reports whether any of files is one of paths or lies under
+// one of them.
+func touchesPaths(files, paths []string) bool {
+	for _, f := range files {
+		for _, p := range paths {
+			if f == p || strings.HasPrefix(f, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *Server) handleAddPR(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		PRNumber int `json:"pr_number"`
+		PRNumber int    `json:"pr_number"`
+		Commit   string `json:"commit"`
+		Repo     string `json:"repo"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
 		return
 	}
+	t, ok := s.target(req.Repo)
+	if !ok {
+		http.Error(w, `{"error":"unknown repo"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Commit != "" {
+		if req.PRNumber != 0 {
+			http.Error(w, `{"error":"give either pr_number or commit, not both"}`, http.StatusBadRequest)
+			return
+		}
+		found, err := t.gh.GetPRForCommit(r.Context(), req.Commit)
+		if errors.Is(err, github.ErrNotFound) {
+			http.Error(w, `{"error":"no PR found for commit"}`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("server: looking up PR for commit %s: %v", req.Commit, err)
+			http.Error(w, `{"error":"could not look up commit on GitHub"}`, http.StatusBadGateway)
+			return
+		}
+		req.PRNumber = found.Number
+	}
 	if req.PRNumber <= 0 {
 		http.Error(w, `{"error":"pr_number must be positive"}`, http.StatusBadRequest)
 		return
 	}
 
 	// Verify PR exists on GitHub
-	info, err := s.gh.GetPR(r.Context(), req.PRNumber)
+	info, err := t.gh.GetPR(r.Context(), req.PRNumber)
 	if err != nil {
 		log.Printf("server: fetching PR #%d: %v", req.PRNumber, err)
 		http.Error(w, `{"error":"could not fetch PR from GitHub"}`, http.StatusBadGateway)
 		return
 	}
 
-	if err := s.db.AddPR(req.PRNumber); err != nil {
+	if len(s.trackPaths) > 0 && req.Repo == "" {
+		files, err := t.gh.GetPRFiles(r.Context(), req.PRNumber)
+		if err != nil {
+			log.Printf("server: fetching files of PR #%d: %v", req.PRNumber, err)
+			http.Error(w, `{"error":"could not fetch PR files from GitHub"}`, http.StatusBadGateway)
+			return
+		}
+		if !touchesPaths(files, s.trackPaths) {
+			http.Error(w, `{"error":"PR does not touch any tracked path"}`, http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	if err := t.db.AddPR(req.PRNumber); err != nil {
 		log.Printf("server: adding PR #%d: %v", req.PRNumber, err)
 		http.Error(w, `{"error":"could not add PR"}`, http.StatusInternalServerError)
 		return
@@ -134,46 +678,67 @@ func (s *Server) handleAddPR(w http.ResponseWriter, r *http.Request) {
 	} else if info.State == "closed" {
 		status = "closed"
 	}
-	if err := s.db.UpdatePRStatus(req.PRNumber, status, mergeCommit, info.Title, info.Author); err != nil {
+	if err := t.db.UpdatePRStatus(req.PRNumber, status, mergeCommit, info.Title, info.Author); err != nil {
 		log.Printf("server: updating PR #%d status: %v", req.PRNumber, err)
 	}
+	if err := t.db.UpdatePRBase(req.PRNumber, info.BaseRef); err != nil {
+		log.Printf("server: updating PR #%d base: %v", req.PRNumber, err)
+	}
+	if !info.MergedAt.IsZero() {
+		if err := t.db.UpdatePRMergedAt(req.PRNumber, info.MergedAt); err != nil {
+			log.Printf("server: updating PR #%d merge time: %v", req.PRNumber, err)
+		}
+	}
+	body := s.eventBody(event.Excerpt(info.Body))
+	if body != "" {
+		if err := t.db.UpdatePRBody(req.PRNumber, body); err != nil {
+			log.Printf("server: updating PR #%d body: %v", req.PRNumber, err)
+		}
+	}
 
-	s.bus.Publish(event.Event{
+	s.publish(event.Event{
 		Type:      event.PRAdded,
+		Repo:      req.Repo,
 		PRNumber:  req.PRNumber,
 		Title:     info.Title,
 		Author:    info.Author,
+		Body:      body,
 		Timestamp: time.Now(),
 	})
 
 	// Emit notifications for gates already passed
+	notificationBranches, targetBranches := t.notificationBranches, t.targetBranches
+	landedBranches := make(map[string]bool)
 	allLanded := false
 	if info.Merged {
-		s.bus.Publish(event.Event{
+		s.publish(event.Event{
 			Type:      event.PRMerged,
+			Repo:      req.Repo,
 			PRNumber:  req.PRNumber,
 			Title:     info.Title,
 			Author:    info.Author,
+			Body:      body,
 			Timestamp: time.Now(),
 		})
 
 		// Check each branch and emit + record if already landed
-		landedBranches := make(map[string]bool)
-		for _, branch := range s.notificationBranches {
-			inBranch, err := s.gh.IsCommitInBranch(r.Context(), info.MergeCommit, branch)
+		for _, branch := range notificationBranches {
+			inBranch, err := t.poller.InBranch(r.Context(), info.MergeCommit, branch)
 			if err != nil {
 				log.Printf("server: checking PR #%d in %s: %v", req.PRNumber, branch, err)
 				continue
 			}
 			if inBranch {
-				if err := s.db.UpdateBranchLanded(req.PRNumber, branch); err != nil {
+				if err := t.db.UpdateBranchLanded(req.PRNumber, branch); err != nil {
 					log.Printf("server: updating branch status for PR #%d: %v", req.PRNumber, err)
 				}
-				s.bus.Publish(event.Event{
+				s.publish(event.Event{
 					Type:      event.PRLandedBranch,
+					Repo:      req.Repo,
 					PRNumber:  req.PRNumber,
 					Title:     info.Title,
 					Author:    info.Author,
+					Body:      body,
 					Branch:    branch,
 					Timestamp: time.Now(),
 				})
@@ -181,7 +746,7 @@ func (s *Server) handleAddPR(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		allLanded = true
-		for _, branch := range s.targetBranches {
+		for _, branch := range targetBranches {
 			if !landedBranches[branch] {
 				allLanded = false
 				break
@@ -189,22 +754,52 @@ func (s *Server) handleAddPR(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Auto-remove if already landed in all branches
+	// Auto-remove if already landed in all branches, or mark it landed
+	// like the poller does when landed PRs are kept for a while.
 	if allLanded {
-		log.Printf("PR #%d has already landed in all branches, removing", req.PRNumber)
-		if err := s.db.RemovePR(req.PRNumber); err != nil {
-			log.Printf("server: removing PR #%d: %v", req.PRNumber, err)
+		retention := t.poller.LandedRetention()
+		if retention > 0 {
+			log.Printf("PR #%d has already landed in all branches, keeping for %s", req.PRNumber, retention)
+		} else {
+			log.Printf("PR #%d has already landed in all branches, removing", req.PRNumber)
 		}
-		s.bus.Publish(event.Event{
-			Type:      event.PRRemoved,
+		var landed []string
+		for _, branch := range notificationBranches {
+			if landedBranches[branch] {
+				landed = append(landed, branch)
+			}
+		}
+		s.publish(event.Event{
+			Type:      event.PRFullyLanded,
+			Repo:      req.Repo,
 			PRNumber:  req.PRNumber,
 			Title:     info.Title,
 			Author:    info.Author,
+			Body:      body,
+			Branches:  landed,
 			Timestamp: time.Now(),
 		})
+		if retention > 0 {
+			if err := t.db.UpdatePRStatus(req.PRNumber, "landed", mergeCommit, info.Title, info.Author); err != nil {
+				log.Printf("server: updating PR #%d status: %v", req.PRNumber, err)
+			}
+		} else {
+			if err := t.db.RemovePR(req.PRNumber); err != nil {
+				log.Printf("server: removing PR #%d: %v", req.PRNumber, err)
+			}
+			s.publish(event.Event{
+				Type:      event.PRRemoved,
+				Repo:      req.Repo,
+				PRNumber:  req.PRNumber,
+				Title:     info.Title,
+				Author:    info.Author,
+				Body:      body,
+				Timestamp: time.Now(),
+			})
+		}
 	}
 
-	pr, err := s.db.GetPR(req.PRNumber)
+	pr, err := t.db.GetPR(req.PRNumber)
 	if err != nil {
 		log.Printf("server: fetching added PR #%d: %v", req.PRNumber, err)
 		http.Error(w, `{"error":"PR added but could not fetch"}`, http.StatusInternalServerError)
@@ -217,7 +812,26 @@ func (s *Server) handleAddPR(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleListPRs(w http.ResponseWriter, r *http.Request) {
-	prs, err := s.db.ListPRs()
+	withBranches := true
+	if v := r.URL.Query().Get("branches"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, `{"error":"branches must be true or false"}`, http.StatusBadRequest)
+			return
+		}
+		withBranches = b
+	}
+
+	t, ok := s.target(r.URL.Query().Get("repo"))
+	if !ok {
+		http.Error(w, `{"error":"unknown repo"}`, http.StatusBadRequest)
+		return
+	}
+	list := t.db.ListPRs
+	if !withBranches {
+		list = t.db.ListPRsWithoutBranches
+	}
+	prs, err := list()
 	if err != nil {
 		log.Printf("server: listing PRs: %v", err)
 		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
@@ -235,28 +849,769 @@ func (s *Server) handleDeletePR(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error":"invalid PR number"}`, http.StatusBadRequest)
 		return
 	}
+	t, ok := s.queryTarget(w, r)
+	if !ok {
+		return
+	}
 
-	pr, err := s.db.GetPR(num)
+	pr, err := t.db.GetPR(num)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, `{"error":"PR not tracked"}`, http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("server: fetching PR #%d for removal: %v", num, err)
+		http.Error(w, `{"error":"could not remove PR"}`, http.StatusInternalServerError)
+		return
 	}
 
-	if err := s.db.RemovePR(num); err != nil {
+	if err := t.db.RemovePR(num); err != nil {
 		log.Printf("server: removing PR #%d: %v", num, err)
 		http.Error(w, `{"error":"could not remove PR"}`, http.StatusInternalServerError)
 		return
 	}
+	t.poller.Forget(num)
 
-	evt := event.Event{
+	s.addTombstone(t.repo, *pr)
+	s.publish(event.Event{
 		Type:      event.PRRemoved,
+		Repo:      t.repo,
+		PRNumber:  num,
+		Title:     pr.Title,
+		Author:    pr.Author,
+		Body:      s.eventBody(pr.Body),
+		Timestamp: time.Now(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addTombstone remembers a PR removed from repo for restoreWindow,
+// dropping any tombstones that have already expired.
+func (s *Server) addTombstone(repo string, pr db.TrackedPR) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, t := range s.tombstones {
+		if now.Sub(t.removedAt) > restoreWindow {
+			delete(s.tombstones, key)
+		}
+	}
+	s.tombstones[tombstoneKey{repo, pr.PRNumber}] = tombstone{pr: pr, removedAt: now}
+}
+
+// handleRestorePR re-tracks a PR removed through the API within the last
+// restoreWindow, with the state it had when removed and no GitHub call.
+func (s *Server) handleRestorePR(w http.ResponseWriter, r *http.Request) {
+	numStr := r.PathValue("number")
+	num, err := strconv.Atoi(numStr)
+	if err != nil {
+		http.Error(w, `{"error":"invalid PR number"}`, http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.queryTarget(w, r)
+	if !ok {
+		return
+	}
+
+	key := tombstoneKey{t.repo, num}
+	s.mu.Lock()
+	ts, ok := s.tombstones[key]
+	s.mu.Unlock()
+	if !ok || time.Since(ts.removedAt) > restoreWindow {
+		http.Error(w, `{"error":"no recently removed PR to restore"}`, http.StatusNotFound)
+		return
+	}
+
+	// The tombstone is only dropped once the restore succeeds, so a
+	// rejected or failed attempt leaves it to retry.
+	if _, err := t.db.GetPR(num); err == nil {
+		http.Error(w, `{"error":"PR is already tracked"}`, http.StatusConflict)
+		return
+	}
+	if err := t.db.RestorePR(ts.pr); err != nil {
+		log.Printf("server: restoring PR #%d: %v", num, err)
+		http.Error(w, `{"error":"could not restore PR"}`, http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	if cur, ok := s.tombstones[key]; ok && cur.removedAt.Equal(ts.removedAt) {
+		delete(s.tombstones, key)
+	}
+	s.mu.Unlock()
+
+	s.publish(event.Event{
+		Type:      event.PRAdded,
+		Repo:      t.repo,
 		PRNumber:  num,
+		Title:     ts.pr.Title,
+		Author:    ts.pr.Author,
+		Body:      s.eventBody(ts.pr.Body),
 		Timestamp: time.Now(),
+	})
+
+	pr, err := t.db.GetPR(num)
+	if err != nil {
+		log.Printf("server: fetching restored PR #%d: %v", num, err)
+		http.Error(w, `{"error":"PR restored but could not fetch"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(pr)
+}
+
+func (s *Server) handleRefreshPR(w http.ResponseWriter, r *http.Request) {
+	numStr := r.PathValue("number")
+	num, err := strconv.Atoi(numStr)
+	if err != nil {
+		http.Error(w, `{"error":"invalid PR number"}`, http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.queryTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := t.poller.Refresh(r.Context(), num); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, `{"error":"PR not tracked"}`, http.StatusNotFound)
+			return
+		}
+		log.Printf("server: refreshing PR #%d: %v", num, err)
+		http.Error(w, `{"error":"could not refresh PR from GitHub"}`, http.StatusBadGateway)
+		return
+	}
+	// A refresh can update the title or author without publishing an event.
+	s.invalidateIndex()
+
+	pr, err := t.db.GetPR(num)
+	if err != nil {
+		// Landed everywhere during the refresh and was auto-removed.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pr)
+}
+
+// handleGitHubWebhook takes GitHub's "pull_request" webhook deliveries so a
+// tracked PR's merge or close is recorded at once rather than at the next
+// poll. Other events, and PRs that aren't tracked, are acknowledged and
+// ignored.
+func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	sig := r.Header.Get("X-Hub-Signature-256")
+	if sig == "" {
+		http.Error(w, `{"error":"missing X-Hub-Signature-256"}`, http.StatusUnauthorized)
+		return
+	}
+	// The body is read before it can be verified, so bound it.
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGitHubWebhookBody))
+	if err != nil {
+		http.Error(w, `{"error":"could not read body"}`, http.StatusBadRequest)
+		return
+	}
+	if !validGitHubSignature(s.githubWebhookSecret, body, sig) {
+		http.Error(w, `{"error":"invalid signature"}`, http.StatusUnauthorized)
+		return
 	}
-	if pr != nil {
-		evt.Title = pr.Title
-		evt.Author = pr.Author
+	if r.Header.Get("X-GitHub-Event") != "pull_request" {
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
-	s.bus.Publish(evt)
 
+	ev, err := github.ParsePullRequestEvent(body)
+	if err != nil {
+		http.Error(w, `{"error":"invalid pull_request payload"}`, http.StatusBadRequest)
+		return
+	}
+	t, ok := s.webhookTarget(ev.Repo)
+	if !ok {
+		log.Printf("server: ignoring webhook for PR #%d of %s", ev.PR.Number, ev.Repo)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := t.poller.ApplyPRUpdate(r.Context(), ev.PR); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		log.Printf("server: applying webhook for PR #%d of %s: %v", ev.PR.Number, ev.Repo, err)
+		http.Error(w, `{"error":"could not update PR"}`, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("server: PR #%d of %s %s via webhook", ev.PR.Number, ev.Repo, ev.Action)
+	// A title change is recorded without publishing an event.
+	s.invalidateIndex()
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// webhookTarget resolves the repository a GitHub webhook names, which GitHub
+// may spell in a different case than the configuration.
+func (s *Server) webhookTarget(repo string) (repoTarget, bool) {
+	if strings.EqualFold(repo, s.gh.Repo()) {
+		return s.target("")
+	}
+	for name, t := range s.repos {
+		if strings.EqualFold(repo, name) {
+			return t, true
+		}
+	}
+	return repoTarget{}, false
+}
+
+// maxGitHubWebhookBody is the largest payload GitHub delivers, 25 MB.
+const maxGitHubWebhookBody = 25 << 20
+
+// validGitHubSignature reports whether header, an X-Hub-Signature-256
+// value, is "sha256=" followed by the hex HMAC-SHA256 of body under secret.
+// The MACs are compared in constant time.
+func validGitHubSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// handleResetPR clears a PR's recorded landings so the next poll re-detects
+// them, e.g. after a landing was recorded by mistake.
+func (s *Server) handleResetPR(w http.ResponseWriter, r *http.Request) {
+	numStr := r.PathValue("number")
+	num, err := strconv.Atoi(numStr)
+	if err != nil {
+		http.Error(w, `{"error":"invalid PR number"}`, http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.queryTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := t.db.ResetBranchStatus(num); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			http.Error(w, `{"error":"PR not tracked"}`, http.StatusNotFound)
+			return
+		}
+		log.Printf("server: resetting PR #%d: %v", num, err)
+		http.Error(w, `{"error":"could not reset PR"}`, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("server: reset landings of PR #%d", num)
+	s.invalidateIndex()
+
+	pr, err := t.db.GetPR(num)
+	if err != nil {
+		log.Printf("server: fetching PR #%d after reset: %v", num, err)
+		http.Error(w, `{"error":"PR reset but could not fetch"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pr)
+}
+
+func (s *Server) handleAddCommit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SHA   string `json:"sha"`
+		Title string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	sha := strings.ToLower(strings.TrimSpace(req.SHA))
+	if !shaPattern.MatchString(sha) {
+		http.Error(w, `{"error":"sha must be a hex commit SHA"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.AddCommit(sha, req.Title); err != nil {
+		log.Printf("server: adding commit %s: %v", sha, err)
+		http.Error(w, `{"error":"could not add commit"}`, http.StatusInternalServerError)
+		return
+	}
+
+	c, err := s.db.GetCommit(sha)
+	if err != nil {
+		log.Printf("server: fetching added commit %s: %v", sha, err)
+		http.Error(w, `{"error":"commit added but could not fetch"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// handleListArchive lists PRs removed while NPT_ARCHIVE_ON_REMOVE was on,
+// most recently removed first.
+func (s *Server) handleListArchive(w http.ResponseWriter, r *http.Request) {
+	prs, err := s.db.ListArchivedPRs()
+	if err != nil {
+		log.Printf("server: listing archived PRs: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prs)
+}
+
+func (s *Server) handleListCommits(w http.ResponseWriter, r *http.Request) {
+	commits, err := s.db.ListCommits()
+	if err != nil {
+		log.Printf("server: listing commits: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commits)
+}
+
+// handleGetCommitPR returns the tracked PR whose merge commit is the full
+// SHA in the path.
+func (s *Server) handleGetCommitPR(w http.ResponseWriter, r *http.Request) {
+	sha := strings.ToLower(r.PathValue("sha"))
+	if !shaPattern.MatchString(sha) {
+		http.Error(w, `{"error":"invalid commit SHA"}`, http.StatusBadRequest)
+		return
+	}
+
+	pr, err := s.db.GetPRByMergeCommit(sha)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, `{"error":"no tracked PR has this merge commit"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("server: looking up PR for commit %s: %v", sha, err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pr)
+}
+
+func (s *Server) handleDeleteCommit(w http.ResponseWriter, r *http.Request) {
+	sha := strings.ToLower(r.PathValue("sha"))
+	if !shaPattern.MatchString(sha) {
+		http.Error(w, `{"error":"invalid commit SHA"}`, http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.db.GetCommit(sha)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, `{"error":"commit not tracked"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("server: fetching commit %s for removal: %v", sha, err)
+		http.Error(w, `{"error":"could not remove commit"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.db.RemoveCommit(sha); err != nil {
+		log.Printf("server: removing commit %s: %v", sha, err)
+		http.Error(w, `{"error":"could not remove commit"}`, http.StatusInternalServerError)
+		return
+	}
+
+	s.publish(event.Event{
+		Type:      event.CommitRemoved,
+		Commit:    sha,
+		Title:     c.Title,
+		Timestamp: time.Now(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePausePoller pauses the pollers of every repository.
+func (s *Server) handlePausePoller(w http.ResponseWriter, r *http.Request) {
+	for _, p := range s.pollers() {
+		p.Pause()
+	}
+	s.handlePollerStatus(w, r)
+}
+
+func (s *Server) handleResumePoller(w http.ResponseWriter, r *http.Request) {
+	for _, p := range s.pollers() {
+		p.Resume()
+	}
+	s.handlePollerStatus(w, r)
+}
+
+// handleRunPoller queues a full poll cycle of every repository and returns
+// 202 without waiting for them. A second request while all are still
+// queued or running gets 409.
+func (s *Server) handleRunPoller(w http.ResponseWriter, r *http.Request) {
+	if s.poller.Paused() {
+		http.Error(w, `{"error":"poller is paused"}`, http.StatusConflict)
+		return
+	}
+	queued := false
+	for _, p := range s.pollers() {
+		if p.RunNow() {
+			queued = true
+		}
+	}
+	if !queued {
+		http.Error(w, `{"error":"a manual poll is already in progress"}`, http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// health reports the last successful poll of NPT_GITHUB_REPO and whether
+// the pollers of all repositories are healthy.
+func (s *Server) health() (time.Time, bool) {
+	lastSuccess, healthy := s.poller.Health()
+	for _, p := range s.pollers()[1:] {
+		if _, ok := p.Health(); !ok {
+			healthy = false
+		}
+	}
+	return lastSuccess, healthy
+}
+
+// handlePollerStatus reports the poller of NPT_GITHUB_REPO; healthy covers
+// the pollers of all repositories.
+func (s *Server) handlePollerStatus(w http.ResponseWriter, r *http.Request) {
+	lastSuccess, healthy := s.health()
+	manualRun, lastTriggered := s.poller.ManualRun()
+
+	resp := struct {
+		Paused        bool       `json:"paused"`
+		Healthy       bool       `json:"healthy"`
+		Interval      string     `json:"interval"`
+		LastPoll      *time.Time `json:"last_poll,omitempty"`
+		ManualRun     bool       `json:"manual_run"`
+		LastTriggered *time.Time `json:"last_triggered,omitempty"`
+	}{
+		Paused:    s.poller.Paused(),
+		Healthy:   healthy,
+		Interval:  s.poller.Interval().String(),
+		ManualRun: manualRun,
+	}
+	if !lastSuccess.IsZero() {
+		resp.LastPoll = &lastSuccess
+	}
+	if !lastTriggered.IsZero() {
+		resp.LastTriggered = &lastTriggered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleConfig describes what this instance tracks: the polled branches,
+// which of them a PR must land in before auto-removal, and the poll
+// interval. It deliberately exposes no credentials or endpoints.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	notificationBranches, targetBranches := s.branches()
+
+	type branchPolicy struct {
+		Name     string `json:"name"`
+		Required bool   `json:"required"`
+	}
+	required := make(map[string]bool, len(targetBranches))
+	for _, b := range targetBranches {
+		required[b] = true
+	}
+	branches := make([]branchPolicy, len(notificationBranches))
+	for i, b := range notificationBranches {
+		branches[i] = branchPolicy{Name: b, Required: required[b]}
+	}
+
+	resp := struct {
+		Branches             []branchPolicy `json:"branches"`
+		NotificationBranches []string       `json:"notification_branches"`
+		TargetBranches       []string       `json:"target_branches"`
+		PollInterval         string         `json:"poll_interval"`
+		ReadOnly             bool           `json:"read_only"`
+	}{
+		Branches:             branches,
+		NotificationBranches: notificationBranches,
+		TargetBranches:       targetBranches,
+		PollInterval:         s.poller.Interval().String(),
+		ReadOnly:             s.readOnly,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleDebugConfig returns the full effective configuration, redacted, for
+// sharing in support requests. It is an admin endpoint, so NPT_ADMIN_ADDR
+// can keep it off the public listener.
+func (s *Server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	cfg := s.debugConfig
+	s.mu.RUnlock()
+	if cfg == nil {
+		cfg = map[string]any{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
+
+// handleMatrix returns the landing grid across all tracked PRs: one column
+// per notification branch in configured order, followed by any other branch
+// a PR has recorded a landing in, and one row per PR whose cells line up
+// with the columns.
+func (s *Server) handleMatrix(w http.ResponseWriter, r *http.Request) {
+	prs, err := s.db.ListPRs()
+	if err != nil {
+		log.Printf("server: listing PRs: %v", err)
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	notificationBranches, _ := s.branches()
+	columns := matrixColumns(notificationBranches, prs)
+
+	type cell struct {
+		State      string     `json:"state"` // "landed" or "pending"
+		LandedAt   *time.Time `json:"landed_at,omitempty"`
+		LastStatus string     `json:"last_status,omitempty"`
+	}
+	type row struct {
+		PRNumber int    `json:"pr_number"`
+		Title    string `json:"title"`
+		Author   string `json:"author"`
+		Status   string `json:"status"`
+		Cells    []cell `json:"cells"`
+	}
+	rows := make([]row, 0, len(prs))
+	for _, pr := range prs {
+		cells := make([]cell, len(columns))
+		for i, bs := range matrixCells(pr, columns) {
+			cells[i] = cell{State: "pending", LastStatus: bs.LastStatus}
+			if bs.Landed {
+				cells[i] = cell{State: "landed", LandedAt: bs.LandedAt, LastStatus: bs.LastStatus}
+			}
+		}
+		rows = append(rows, row{PRNumber: pr.PRNumber, Title: pr.Title, Author: pr.Author, Status: pr.Status, Cells: cells})
+	}
+
+	resp := struct {
+		Branches []string `json:"branches"`
+		PRs      []row    `json:"prs"`
+	}{Branches: columns, PRs: rows}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkRow is one PR in a POST /api/check response. Cells follow the
+// response's branches; Error is set instead when the PR couldn't be checked.
+type checkRow struct {
+	PRNumber int         `json:"pr_number"`
+	Title    string      `json:"title,omitempty"`
+	Author   string      `json:"author,omitempty"`
+	Status   string      `json:"status,omitempty"`
+	Cells    []checkCell `json:"cells,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type checkCell struct {
+	State string `json:"state"` // "landed" or "pending"
+}
+
+// feedEntries is how many landings GET /api/feed.atom lists.
+const feedEntries = 50
+
+// atomFeed and atomEntry are the parts of an Atom (RFC 4287) feed the
+// landings feed uses.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+// handleFeed serves recent landings from the events log as an Atom feed, for
+// feed readers. Entry IDs are derived from event IDs, so they stay stable
+// across requests until the event is pruned.
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	events, err := s.db.ListEventsByType(feedEntries, string(event.PRLandedBranch), string(event.PRFullyLanded))
+	if err != nil {
+		log.Printf("server: listing events: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	feed := atomFeed{
+		ID:      "urn:nixpkgs-pr-tracker:landings",
+		Title:   "nixpkgs PR landings",
+		Updated: s.now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "nixpkgs-pr-tracker"},
+	}
+	if len(events) > 0 {
+		feed.Updated = events[0].CreatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, e := range events {
+		title := "PR #" + strconv.Itoa(e.PRNumber) + " landed in " + e.Branch
+		if e.Type == string(event.PRFullyLanded) {
+			title = "PR #" + strconv.Itoa(e.PRNumber) + " landed in all branches"
+		}
+		repo := s.gh.Repo()
+		if e.Repo != "" {
+			repo = e.Repo
+			title = repo + " " + title
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:nixpkgs-pr-tracker:event:" + strconv.Itoa(e.ID),
+			Title:   title,
+			Updated: e.CreatedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: "https://github.com/" + repo + "/pull/" + strconv.Itoa(e.PRNumber)},
+			Summary: e.Title,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("server: encoding feed: %v", err)
+	}
+}
+
+// handleCheck reports where arbitrary PRs have landed without tracking them:
+// nothing is written to the database and no events are published. Branches
+// are the notification branches. A GitHub rate limit aborts the whole batch
+// with 429 and a Retry-After header.
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PRNumbers []int `json:"pr_numbers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.PRNumbers) == 0 {
+		http.Error(w, `{"error":"pr_numbers must not be empty"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.PRNumbers) > maxCheckPRs {
+		http.Error(w, `{"error":"at most `+strconv.Itoa(maxCheckPRs)+` pr_numbers per request"}`, http.StatusBadRequest)
+		return
+	}
+	for _, n := range req.PRNumbers {
+		if n <= 0 {
+			http.Error(w, `{"error":"pr_numbers must be positive"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	notificationBranches, _ := s.branches()
+	rows := make([]checkRow, 0, len(req.PRNumbers))
+	for _, n := range req.PRNumbers {
+		row, err := s.checkPR(r.Context(), n, notificationBranches)
+		if err != nil {
+			var rlErr *github.RateLimitError
+			if errors.As(err, &rlErr) {
+				retry := max(int(time.Until(rlErr.RetryAfter).Seconds()), 1)
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				http.Error(w, `{"error":"GitHub rate limit reached"}`, http.StatusTooManyRequests)
+				return
+			}
+			log.Printf("server: checking PR #%d: %v", n, err)
+		}
+		rows = append(rows, row)
+	}
+
+	resp := struct {
+		Branches []string   `json:"branches"`
+		PRs      []checkRow `json:"prs"`
+	}{Branches: notificationBranches, PRs: rows}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkPR fetches PR n and, if it is merged, checks its merge commit against
+// each branch. On error the returned row carries a message for the client.
+func (s *Server) checkPR(ctx context.Context, n int, branches []string) (checkRow, error) {
+	info, err := s.gh.GetPR(ctx, n)
+	if err != nil {
+		if errors.Is(err, github.ErrNotFound) {
+			return checkRow{PRNumber: n, Error: "PR not found"}, nil
+		}
+		return checkRow{PRNumber: n, Error: "could not fetch PR from GitHub"}, err
+	}
+
+	row := checkRow{PRNumber: n, Title: info.Title, Author: info.Author, Status: "open"}
+	if info.Merged {
+		row.Status = "merged"
+	} else if info.State == "closed" {
+		row.Status = "closed"
+	}
+	row.Cells = make([]checkCell, len(branches))
+	for i, branch := range branches {
+		row.Cells[i] = checkCell{State: "pending"}
+		if !info.Merged {
+			continue
+		}
+		inBranch, err := s.poller.InBranch(ctx, info.MergeCommit, branch)
+		if err != nil {
+			return checkRow{PRNumber: n, Error: "could not check " + branch}, err
+		}
+		if inBranch {
+			row.Cells[i] = checkCell{State: "landed"}
+		}
+	}
+	return row, nil
+}
+
+// handleHealthz returns 200 while the pollers of all repositories are
+// completing cycles and 503 once one has stalled, so alerting can tell
+// "process alive" from "working".
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	lastSuccess, healthy := s.health()
+
+	resp := struct {
+		Status   string     `json:"status"`
+		LastPoll *time.Time `json:"last_poll,omitempty"`
+	}{Status: "ok"}
+	if !lastSuccess.IsZero() {
+		resp.LastPoll = &lastSuccess
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		resp.Status = "stalled"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -1,20 +1,32 @@
 package server
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/config"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/db"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/github"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/poller"
 )
 
-const testTemplate = `{{define "index.html"}}<!DOCTYPE html><html><body>{{if .}}{{range .}}#{{.PRNumber}}{{end}}{{else}}empty{{end}}</body></html>{{end}}{{define "detail.html"}}<!DOCTYPE html><html><body>PR #{{.PR.PRNumber}} {{.PR.Title}}</body></html>{{end}}`
+const testTemplate = `{{define "index.html"}}<!DOCTYPE html><html><body>{{range .Branches}}<th>{{.}}</th>{{end}}{{if .PRs}}{{range .PRs}}#{{.PRNumber}}{{range .Cells}} {{.Branch}}={{.Landed}}{{end}}{{end}}{{else}}empty{{end}}</body></html>{{end}}{{define "detail.html"}}<!DOCTYPE html><html><body>PR #{{.PR.PRNumber}} {{.PR.Title}}{{if .ViaStaging}} via {{.PR.BaseRef}}{{end}}{{range .Pending}} {{.Branch}}: {{.Status}} ({{.Meaning}}){{end}}</body></html>{{end}}`
 
 type testEnv struct {
 	db     *db.DB
@@ -50,7 +62,8 @@ func setupTest(t *testing.T, notificationBranches []string, targetBranches ...[]
 	if len(targetBranches) > 0 {
 		tb = targetBranches[0]
 	}
-	s := New(database, ghClient, bus, notificationBranches, tb, tmpl)
+	p := poller.New(database, ghClient, bus, time.Hour, notificationBranches, tb)
+	s := New(database, ghClient, bus, p, WithBranches(notificationBranches, tb), WithTemplate(tmpl))
 
 	return &testEnv{
 		db:     database,
@@ -77,13 +90,126 @@ func TestListPRsEmpty(t *testing.T) {
 	}
 }
 
+func TestListArchive(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	// A second handle on the same in-memory database, archiving on removal.
+	archiving, err := db.New("file:"+t.Name()+"?mode=memory&cache=shared", db.WithArchiveOnRemove())
+	if err != nil {
+		t.Fatalf("opening DB: %v", err)
+	}
+	t.Cleanup(func() { archiving.Close() })
+	archiving.AddPR(42)
+	archiving.UpdatePRStatus(42, "merged", "sha42", "Archived PR", "alice")
+	archiving.UpdateBranchLanded(42, "nixos-unstable")
+	archiving.RemovePR(42)
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/archive", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var prs []db.ArchivedPR
+	if err := json.Unmarshal(w.Body.Bytes(), &prs); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(prs) != 1 || prs[0].PRNumber != 42 || prs[0].Title != "Archived PR" || prs[0].ArchivedAt.IsZero() || len(prs[0].Branches) != 1 {
+		t.Errorf("archive = %+v", prs)
+	}
+}
+
+func TestIndexCache(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	env.srv.now = func() time.Time { return clock }
+	var queries int
+	env.srv.listPRs = func() ([]db.TrackedPR, error) {
+		queries++
+		return env.db.ListPRs()
+	}
+	env.srv.SetIndexCacheTTL(5 * time.Second)
+
+	load := func() {
+		t.Helper()
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET / = %d", w.Code)
+		}
+	}
+
+	load()
+	load()
+	if queries != 1 {
+		t.Errorf("queries within TTL = %d, want 1", queries)
+	}
+
+	clock = clock.Add(5 * time.Second)
+	load()
+	if queries != 2 {
+		t.Errorf("queries after TTL = %d, want 2", queries)
+	}
+
+	env.bus.Publish(event.Event{Type: event.PRAdded, PRNumber: 1})
+	load()
+	if queries != 3 {
+		t.Errorf("queries after an event = %d, want 3", queries)
+	}
+	load()
+	if queries != 3 {
+		t.Errorf("queries after re-caching = %d, want 3", queries)
+	}
+}
+
+func TestListPRsBranchesParam(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	env.db.AddPR(12)
+	env.db.UpdateBranchLanded(12, "nixos-unstable")
+
+	tests := []struct {
+		query        string
+		wantCode     int
+		wantBranches int
+	}{
+		{"", http.StatusOK, 1},
+		{"?branches=true", http.StatusOK, 1},
+		{"?branches=false", http.StatusOK, 0},
+		{"?branches=maybe", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/prs"+tt.query, nil)
+			w := httptest.NewRecorder()
+			env.router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var prs []db.TrackedPR
+			if err := json.Unmarshal(w.Body.Bytes(), &prs); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if len(prs) != 1 {
+				t.Fatalf("got %d PRs, want 1", len(prs))
+			}
+			if len(prs[0].Branches) != tt.wantBranches {
+				t.Errorf("Branches = %+v, want %d entries", prs[0].Branches, tt.wantBranches)
+			}
+		})
+	}
+}
+
 func TestAddPRSuccess(t *testing.T) {
 	env := setupTest(t, []string{"nixos-unstable"})
 
 	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/42", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{
 			"number": 42, "title": "Test PR", "user": map[string]any{"login": "alice"},
-			"state": "open", "merged": false,
+			"state": "open", "merged": false, "base": map[string]any{"ref": "staging"},
 		})
 	})
 
@@ -103,6 +229,9 @@ func TestAddPRSuccess(t *testing.T) {
 	if pr.Title != "Test PR" {
 		t.Errorf("Title = %q, want %q", pr.Title, "Test PR")
 	}
+	if pr.BaseRef != "staging" {
+		t.Errorf("BaseRef = %q, want %q", pr.BaseRef, "staging")
+	}
 }
 
 func TestAddMergedPR(t *testing.T) {
@@ -133,6 +262,253 @@ func TestAddMergedPR(t *testing.T) {
 	}
 }
 
+func TestAddPRByCommit(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/commits/sha123/pulls", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"number": 50, "title": "Merged PR", "state": "closed", "merge_commit_sha": "sha123"},
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/commits/orphan/pulls", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/50", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 50, "title": "Merged PR", "user": map[string]any{"login": "bob"},
+			"state": "closed", "merged": true, "merge_commit_sha": "sha123",
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha123", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"commit": "sha123"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", w.Code, w.Body.String())
+	}
+	pr, err := env.db.GetPR(50)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Status != "merged" || pr.Author != "bob" {
+		t.Errorf("PR = %+v, want merged PR by bob", pr)
+	}
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"commit": "orphan"}`, http.StatusNotFound},
+		{`{"commit": "sha123", "pr_number": 50}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prs", strings.NewReader(tt.body)))
+		if w.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.body, w.Code, tt.code)
+		}
+	}
+}
+
+func TestAddPRWithRepo(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	otherDB, otherGH := env.db.ForRepo("example/other"), env.gh.ForRepo("example/other")
+	otherPoller := poller.New(otherDB, otherGH, env.bus, time.Hour, []string{"main"}, []string{"main"})
+	p := poller.New(env.db, env.gh, env.bus, time.Hour, []string{"nixos-unstable"}, []string{"nixos-unstable"})
+	router := New(env.db, env.gh, env.bus, p,
+		WithBranches([]string{"nixos-unstable"}, []string{"nixos-unstable"}),
+		WithRepo("example/other", otherDB, otherGH, otherPoller, []string{"main"}),
+	).Routes()
+
+	env.ghMux.HandleFunc("/repos/example/other/pulls/42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 42, "title": "Other PR", "user": map[string]any{"login": "alice"},
+			"state": "closed", "merged": true, "merge_commit_sha": "sha42",
+		})
+	})
+	env.ghMux.HandleFunc("/repos/example/other/compare/main...sha42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+
+	var added []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRAdded {
+			added = append(added, e)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 42, "repo": "example/other"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", w.Code, w.Body.String())
+	}
+	pr, err := otherDB.GetPR(42)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Repo != "example/other" || pr.Title != "Other PR" || pr.Status != "merged" {
+		t.Errorf("PR = %+v, want merged Other PR in example/other", pr)
+	}
+	if _, err := env.db.GetPR(42); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("primary GetPR = %v, want ErrNotFound", err)
+	}
+	if len(added) != 1 || added[0].Repo != "example/other" {
+		t.Errorf("pr_added events = %+v, want one for example/other", added)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"", 0},
+		{"?repo=example/other", 1},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/prs"+tt.query, nil))
+		var prs []db.TrackedPR
+		json.NewDecoder(w.Body).Decode(&prs)
+		if w.Code != http.StatusOK || len(prs) != tt.want {
+			t.Errorf("GET /api/prs%s = %d with %d PRs, want 200 with %d", tt.query, w.Code, len(prs), tt.want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 42, "repo": "example/unknown"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown repo: status = %d, want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/prs?repo=example/unknown", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET unknown repo: status = %d, want 400", w.Code)
+	}
+}
+
+func TestPRRoutesWithRepo(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	otherDB, otherGH := env.db.ForRepo("example/other"), env.gh.ForRepo("example/other")
+	otherPoller := poller.New(otherDB, otherGH, env.bus, time.Hour, []string{"main"}, []string{"main"})
+	router := New(env.db, env.gh, env.bus, env.srv.poller,
+		WithBranches([]string{"nixos-unstable"}, []string{"nixos-unstable"}),
+		WithRepo("example/other", otherDB, otherGH, otherPoller, []string{"main"}),
+	).Routes()
+
+	otherDB.AddPR(42)
+	otherDB.UpdatePRStatus(42, "merged", "sha42", "Other PR", "alice")
+	otherDB.UpdateBranchLanded(42, "main")
+	env.ghMux.HandleFunc("/repos/example/other/compare/main...sha42", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRRemoved || e.Type == event.PRAdded {
+			events = append(events, e)
+		}
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	for _, tt := range []struct {
+		method, path string
+	}{
+		{"POST", "/api/prs/42/reset"},
+		{"POST", "/api/prs/42/refresh"},
+		{"DELETE", "/api/prs/42"},
+	} {
+		if w := serve(tt.method, tt.path); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s without repo = %d, want 404", tt.method, tt.path, w.Code)
+		}
+		if w := serve(tt.method, tt.path+"?repo=example/unknown"); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s for an unknown repo = %d, want 400", tt.method, tt.path, w.Code)
+		}
+	}
+
+	if w := serve("POST", "/api/prs/42/reset?repo=example/other"); w.Code != http.StatusOK {
+		t.Fatalf("reset = %d: %s", w.Code, w.Body.String())
+	}
+	if pr, _ := otherDB.GetPR(42); len(pr.Branches) != 0 {
+		t.Errorf("branches after reset = %+v, want none", pr.Branches)
+	}
+
+	if w := serve("POST", "/api/prs/42/refresh?repo=example/other"); w.Code != http.StatusOK {
+		t.Fatalf("refresh = %d: %s", w.Code, w.Body.String())
+	}
+	if pr, _ := otherDB.GetPR(42); len(pr.Branches) != 1 || pr.Branches[0].LastStatus != "ahead" {
+		t.Errorf("branches after refresh = %+v, want main compared as ahead", pr.Branches)
+	}
+
+	if w := serve("DELETE", "/api/prs/42?repo=example/other"); w.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := otherDB.GetPR(42); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetPR after delete = %v, want ErrNotFound", err)
+	}
+	if w := serve("POST", "/api/prs/42/restore"); w.Code != http.StatusNotFound {
+		t.Errorf("restore into the primary repo = %d, want 404", w.Code)
+	}
+	if w := serve("POST", "/api/prs/42/restore?repo=example/other"); w.Code != http.StatusCreated {
+		t.Fatalf("restore = %d: %s", w.Code, w.Body.String())
+	}
+	if pr, err := otherDB.GetPR(42); err != nil || pr.Title != "Other PR" {
+		t.Errorf("restored PR = %+v, %v, want Other PR", pr, err)
+	}
+
+	if len(events) != 2 || events[0].Type != event.PRRemoved || events[1].Type != event.PRAdded ||
+		events[0].Repo != "example/other" || events[1].Repo != "example/other" {
+		t.Errorf("events = %+v, want pr_events and pr_added for example/other", events)
+	}
+}
+func TestAddPRTrackPaths(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	env.srv.SetTrackPaths([]string{"pkgs/by-name/fo/foo"})
+	router := env.srv.Routes()
+
+	files := map[string][]string{
+		"21": {"pkgs/by-name/fo/foo/package.nix"},
+		"22": {"pkgs/by-name/fo/foobar/package.nix", "README.md"},
+	}
+	for num, changed := range files {
+		env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/"+num, func(w http.ResponseWriter, r *http.Request) {
+			n, _ := strconv.Atoi(num)
+			json.NewEncoder(w).Encode(map[string]any{
+				"number": n, "title": "PR " + num, "user": map[string]any{"login": "alice"}, "state": "open",
+			})
+		})
+		env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/"+num+"/files", func(w http.ResponseWriter, r *http.Request) {
+			var out []map[string]any
+			for _, f := range changed {
+				out = append(out, map[string]any{"filename": f})
+			}
+			json.NewEncoder(w).Encode(out)
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 21}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("matching PR: status = %d, want 201; body: %s", w.Code, w.Body.String())
+	}
+
+	// foobar only shares a prefix with foo.
+	req = httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 22}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("non-matching PR: status = %d, want 422; body: %s", w.Code, w.Body.String())
+	}
+	if _, err := env.db.GetPR(22); err == nil {
+		t.Error("non-matching PR #22 was tracked")
+	}
+}
+
 func TestAddPRInvalidJSON(t *testing.T) {
 	env := setupTest(t, []string{"nixos-unstable"})
 
@@ -323,6 +699,75 @@ func TestAutoRemoveAllLanded(t *testing.T) {
 	}
 }
 
+func TestAddPRFullyLandedEvent(t *testing.T) {
+	env := setupTest(t, []string{"master", "nixos-unstable"})
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/14", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 14, "title": "Old news", "user": map[string]any{"login": "grace"},
+			"state": "closed", "merged": true, "merge_commit_sha": "shaOld",
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	var fully []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRFullyLanded {
+			fully = append(fully, e)
+		}
+	})
+
+	req := httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 14}`))
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if len(fully) != 1 {
+		t.Fatalf("got %d PRFullyLanded events, want 1", len(fully))
+	}
+	if got := strings.Join(fully[0].Branches, ","); got != "master,nixos-unstable" {
+		t.Errorf("Branches = %q, want master,nixos-unstable", got)
+	}
+}
+
+func TestAddPRFullyLandedWithRetention(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	poller.WithLandedRetention(time.Hour)(env.srv.poller)
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/14", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 14, "title": "Old news", "user": map[string]any{"login": "grace"},
+			"state": "closed", "merged": true, "merge_commit_sha": "shaOld",
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+
+	var types []event.Type
+	env.bus.Subscribe(func(e event.Event) {
+		types = append(types, e.Type)
+	})
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 14}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", w.Code, w.Body.String())
+	}
+
+	pr, err := env.db.GetPR(14)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if pr.Status != "landed" || pr.MergeCommit != "shaOld" {
+		t.Errorf("PR = %+v, want status landed with its merge commit", pr)
+	}
+	if slices.Contains(types, event.PRRemoved) || !slices.Contains(types, event.PRFullyLanded) {
+		t.Errorf("events = %v, want pr_fully_landed and no pr_removed", types)
+	}
+}
+
 func TestDeletePR(t *testing.T) {
 	env := setupTest(t, []string{"nixos-unstable"})
 
@@ -378,6 +823,34 @@ func TestDeletePREvent(t *testing.T) {
 	}
 }
 
+func TestDeletePRNotTracked(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		events = append(events, e)
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/prs/404", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if len(events) != 0 {
+		t.Errorf("published %v, want no events", events)
+	}
+
+	// Nothing to restore either.
+	req = httptest.NewRequest("POST", "/api/prs/404/restore", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("restore status = %d, want 404", w.Code)
+	}
+}
+
 func TestIndexPage(t *testing.T) {
 	env := setupTest(t, []string{"nixos-unstable"})
 
@@ -393,6 +866,29 @@ func TestIndexPage(t *testing.T) {
 	}
 }
 
+func TestIndexBranchColumns(t *testing.T) {
+	env := setupTest(t, []string{"staging", "nixos-unstable"}, []string{"nixos-unstable"})
+
+	env.db.AddPR(10)
+	env.db.UpdatePRStatus(10, "merged", "sha10", "Partly Landed", "alice")
+	env.db.UpdateBranchLanded(10, "staging")
+	env.db.UpdateBranchLanded(10, "release-24.11") // no longer configured
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "<th>staging</th><th>nixos-unstable</th><th>release-24.11</th>") {
+		t.Errorf("body = %q, want configured branch headers then recorded extras", body)
+	}
+	if !strings.Contains(body, "#10 staging=true nixos-unstable=false release-24.11=true") {
+		t.Errorf("body = %q, want cells in column order", body)
+	}
+}
+
 func TestNotFoundPage(t *testing.T) {
 	env := setupTest(t, []string{"nixos-unstable"})
 
@@ -426,6 +922,66 @@ func TestPRDetailPage(t *testing.T) {
 	}
 }
 
+func TestPRDetailPageStagingBase(t *testing.T) {
+	tests := []struct {
+		base string
+		want bool
+	}{
+		{"staging", true},
+		{"staging-next", true},
+		{"master", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run("base="+tt.base, func(t *testing.T) {
+			env := setupTest(t, []string{"nixos-unstable"})
+			env.db.AddPR(101)
+			env.db.UpdatePRStatus(101, "merged", "sha101", "Mass rebuild", "alice")
+			env.db.UpdatePRBase(101, tt.base)
+
+			req := httptest.NewRequest("GET", "/pr/101", nil)
+			w := httptest.NewRecorder()
+			env.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200; body: %s", w.Code, w.Body.String())
+			}
+			if got := strings.Contains(w.Body.String(), " via "+tt.base); got != tt.want {
+				t.Errorf("staging annotation shown = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPRDetailPageLastStatus(t *testing.T) {
+	env := setupTest(t, []string{"staging", "master", "nixos-unstable"})
+
+	env.db.AddPR(102)
+	env.db.UpdatePRStatus(102, "merged", "sha102", "Waiting", "alice")
+	env.db.UpdateBranchLastStatus(102, "staging", "ahead") // checked before master landed
+	env.db.UpdateBranchLastStatus(102, "master", "behind")
+	env.db.UpdateBranchLanded(102, "master")
+	env.db.UpdateBranchLastStatus(102, "nixos-unstable", "ahead")
+
+	req := httptest.NewRequest("GET", "/pr/102", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "nixos-unstable: ahead (merge not yet in the branch)") {
+		t.Errorf("missing nixos-unstable explanation in %q", body)
+	}
+	if strings.Contains(body, "staging: ahead") {
+		t.Errorf("stale status of staging, upstream of landed master, shown in %q", body)
+	}
+	if strings.Contains(body, "master: behind") {
+		t.Errorf("landed master listed as not landed in %q", body)
+	}
+}
+
 func TestPRDetailPageNotFound(t *testing.T) {
 	env := setupTest(t, []string{"nixos-unstable"})
 
@@ -482,3 +1038,1162 @@ func TestAddPRTargetBranchesAutoRemove(t *testing.T) {
 		t.Error("expected PR to be auto-removed after landing in all target branches")
 	}
 }
+
+func TestAddCommit(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	req := httptest.NewRequest("POST", "/api/commits", strings.NewReader(`{"sha": "ABCDEF1234567", "title": "staging-next merge"}`))
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", w.Code, w.Body.String())
+	}
+
+	c, err := env.db.GetCommit("abcdef1234567")
+	if err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	if c.Title != "staging-next merge" {
+		t.Errorf("Title = %q, want %q", c.Title, "staging-next merge")
+	}
+}
+
+func TestAddCommitInvalidSHA(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	for _, body := range []string{`{"sha": ""}`, `{"sha": "not-a-sha"}`, `{"sha": "abc"}`, "bad"} {
+		req := httptest.NewRequest("POST", "/api/commits", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestDeleteCommit(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.db.AddCommit("deadbeef", "Delete Me")
+
+	var received event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		received = e
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/commits/deadbeef", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", w.Code)
+	}
+	if _, err := env.db.GetCommit("deadbeef"); err == nil {
+		t.Error("expected commit to be deleted")
+	}
+	if received.Type != event.CommitRemoved || received.Commit != "deadbeef" {
+		t.Errorf("event = %+v, want CommitRemoved for deadbeef", received)
+	}
+}
+
+func TestDeleteCommitNotTracked(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	var events []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		events = append(events, e)
+	})
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/commits/deadbeef", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if len(events) != 0 {
+		t.Errorf("events = %+v, want none", events)
+	}
+}
+
+func TestGetCommitPR(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	env.db.AddPR(91)
+	env.db.UpdatePRStatus(91, "merged", sha, "hello: 1.0 -> 2.0", "alice")
+
+	req := httptest.NewRequest("GET", "/api/commits/"+strings.ToUpper(sha), nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", w.Code, w.Body.String())
+	}
+	var pr db.TrackedPR
+	if err := json.NewDecoder(w.Body).Decode(&pr); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if pr.PRNumber != 91 || pr.MergeCommit != sha {
+		t.Errorf("response = PR #%d merge commit %q, want PR #91 merge commit %q", pr.PRNumber, pr.MergeCommit, sha)
+	}
+
+	for path, want := range map[string]int{
+		"/api/commits/fedcba9876543210fedcba9876543210fedcba98": http.StatusNotFound,
+		"/api/commits/not-a-sha":                                http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestRefreshPR(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(90)
+	env.db.UpdatePRStatus(90, "open", "", "Old Title", "alice")
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/90", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 90, "title": "New Title", "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	req := httptest.NewRequest("POST", "/api/prs/90/refresh", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", w.Code, w.Body.String())
+	}
+	pr, _ := env.db.GetPR(90)
+	if pr.Title != "New Title" {
+		t.Errorf("Title = %q, want %q", pr.Title, "New Title")
+	}
+}
+
+func TestRefreshPRNotTracked(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	req := httptest.NewRequest("POST", "/api/prs/91/refresh", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+// githubSignature signs body the way GitHub fills in X-Hub-Signature-256.
+func githubSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitHubWebhookMerged(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	env.srv.SetGitHubWebhookSecret("hook-secret")
+	router := env.srv.Routes()
+
+	env.db.AddPR(92)
+	env.db.UpdatePRStatus(92, "open", "", "Some Title", "alice")
+
+	var merged []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRMerged {
+			merged = append(merged, e)
+		}
+	})
+
+	body := `{"action":"closed","repository":{"full_name":"NixOS/nixpkgs"},"pull_request":{"number":92,"title":"Some Title","user":{"login":"alice"},"state":"closed","merged":true,"merge_commit_sha":"abc123","base":{"ref":"master"}}}`
+	req := httptest.NewRequest("POST", "/api/github/webhook", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", githubSignature("hook-secret", body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204; body: %s", w.Code, w.Body.String())
+	}
+	pr, _ := env.db.GetPR(92)
+	if pr.Status != "merged" || pr.MergeCommit != "abc123" {
+		t.Errorf("status = %q, merge commit = %q, want merged, abc123", pr.Status, pr.MergeCommit)
+	}
+	if len(merged) != 1 || merged[0].PRNumber != 92 {
+		t.Errorf("PRMerged events = %v, want one for #92", merged)
+	}
+}
+
+// A webhook for an NPT_REPOS repository updates that repository's PR, not
+// the primary one with the same number.
+func TestGitHubWebhookOtherRepo(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	otherDB, otherGH := env.db.ForRepo("example/other"), env.gh.ForRepo("example/other")
+	otherPoller := poller.New(otherDB, otherGH, env.bus, time.Hour, []string{"main"}, []string{"main"})
+	srv := New(env.db, env.gh, env.bus, env.srv.poller,
+		WithBranches([]string{"nixos-unstable"}, []string{"nixos-unstable"}),
+		WithRepo("example/other", otherDB, otherGH, otherPoller, []string{"main"}),
+	)
+	srv.SetGitHubWebhookSecret("hook-secret")
+	router := srv.Routes()
+
+	env.db.AddPR(92)
+	env.db.UpdatePRStatus(92, "open", "", "Primary", "alice")
+	otherDB.AddPR(92)
+	otherDB.UpdatePRStatus(92, "open", "", "Other", "bob")
+
+	var merged []event.Event
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRMerged {
+			merged = append(merged, e)
+		}
+	})
+
+	// GitHub may spell the repository in a different case.
+	body := `{"action":"closed","repository":{"full_name":"Example/Other"},"pull_request":{"number":92,"title":"Other","user":{"login":"bob"},"state":"closed","merged":true,"merge_commit_sha":"abc123","base":{"ref":"main"}}}`
+	req := httptest.NewRequest("POST", "/api/github/webhook", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-Hub-Signature-256", githubSignature("hook-secret", body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204; body: %s", w.Code, w.Body.String())
+	}
+	if pr, _ := otherDB.GetPR(92); pr.Status != "merged" || pr.MergeCommit != "abc123" {
+		t.Errorf("other status = %q, merge commit = %q, want merged, abc123", pr.Status, pr.MergeCommit)
+	}
+	if pr, _ := env.db.GetPR(92); pr.Status != "open" {
+		t.Errorf("primary status = %q, want open", pr.Status)
+	}
+	if len(merged) != 1 || merged[0].Repo != "example/other" {
+		t.Errorf("PRMerged events = %v, want one for example/other", merged)
+	}
+}
+
+func TestGitHubWebhookSignature(t *testing.T) {
+	body := `{"action":"closed","repository":{"full_name":"NixOS/nixpkgs"},"pull_request":{"number":93,"title":"T","user":{"login":"alice"},"state":"closed","merged":false}}`
+	tests := []struct {
+		name       string
+		signature  string
+		wantCode   int
+		wantStatus string
+	}{
+		{"valid", githubSignature("hook-secret", body), http.StatusNoContent, "closed"},
+		{"wrong secret", githubSignature("other-secret", body), http.StatusUnauthorized, "open"},
+		{"not hex", "sha256=zz", http.StatusUnauthorized, "open"},
+		{"sha1", "sha1=" + strings.TrimPrefix(githubSignature("hook-secret", body), "sha256="), http.StatusUnauthorized, "open"},
+		{"missing", "", http.StatusUnauthorized, "open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupTest(t, []string{"nixos-unstable"})
+			env.srv.SetGitHubWebhookSecret("hook-secret")
+			router := env.srv.Routes()
+			env.db.AddPR(93)
+			env.db.UpdatePRStatus(93, "open", "", "T", "alice")
+
+			req := httptest.NewRequest("POST", "/api/github/webhook", strings.NewReader(body))
+			req.Header.Set("X-GitHub-Event", "pull_request")
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body: %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			pr, _ := env.db.GetPR(93)
+			if pr.Status != tt.wantStatus {
+				t.Errorf("PR status = %q, want %q", pr.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGitHubWebhookDisabled(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	req := httptest.NewRequest("POST", "/api/github/webhook", strings.NewReader(`{}`))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	// Without a secret there is nothing to verify against, so no endpoint.
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp map[string]any
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["status"] != "ok" {
+		t.Errorf("status = %v, want ok", resp["status"])
+	}
+	if _, ok := resp["last_poll"]; ok {
+		t.Errorf("last_poll = %v, want omitted before the first poll", resp["last_poll"])
+	}
+}
+
+func TestPausePoller(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	status := func() map[string]any {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/poller/status", nil)
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want 200", w.Code)
+		}
+		var resp map[string]any
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	if resp := status(); resp["paused"] != false || resp["interval"] != "1h0m0s" {
+		t.Errorf("initial status = %v, want paused=false interval=1h0m0s", resp)
+	}
+
+	req := httptest.NewRequest("POST", "/api/poller/pause", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("pause status = %d, want 200", w.Code)
+	}
+	if resp := status(); resp["paused"] != true {
+		t.Errorf("paused = %v after pause, want true", resp["paused"])
+	}
+
+	req = httptest.NewRequest("POST", "/api/poller/resume", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("resume status = %d, want 200", w.Code)
+	}
+	if resp := status(); resp["paused"] != false {
+		t.Errorf("paused = %v after resume, want false", resp["paused"])
+	}
+}
+
+func TestRunPoller(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	fetched := make(chan struct{}, 1)
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/97", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case fetched <- struct{}{}:
+		default:
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 97, "title": "manual", "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false,
+		})
+	})
+
+	// Start with nothing tracked so the initial cycle makes no GitHub calls.
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	env.srv.poller.Start(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for last, _ := env.srv.poller.Health(); last.IsZero(); last, _ = env.srv.poller.Health() {
+		if time.Now().After(deadline) {
+			t.Fatal("initial poll cycle did not complete")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	env.db.AddPR(97)
+
+	req := httptest.NewRequest("POST", "/api/poller/run", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	select {
+	case <-fetched:
+	case <-time.After(2 * time.Second):
+		t.Fatal("manual run did not poll the tracked PR")
+	}
+}
+
+func TestPollerRoutesCoverRepos(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	otherDB, otherGH := env.db.ForRepo("example/other"), env.gh.ForRepo("example/other")
+	// An interval this short makes the never-started poller stall at once.
+	otherPoller := poller.New(otherDB, otherGH, env.bus, time.Nanosecond, []string{"main"}, []string{"main"})
+	router := New(env.db, env.gh, env.bus, env.srv.poller,
+		WithBranches([]string{"nixos-unstable"}, []string{"nixos-unstable"}),
+		WithRepo("example/other", otherDB, otherGH, otherPoller, []string{"main"}),
+	).Routes()
+	time.Sleep(time.Millisecond)
+
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	if code := serve("GET", "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("healthz with a stalled repository poller = %d, want 503", code)
+	}
+
+	if code := serve("POST", "/api/poller/pause"); code != http.StatusOK {
+		t.Fatalf("pause = %d", code)
+	}
+	if !env.srv.poller.Paused() || !otherPoller.Paused() {
+		t.Errorf("paused = %v, %v, want both pollers paused", env.srv.poller.Paused(), otherPoller.Paused())
+	}
+	if code := serve("GET", "/healthz"); code != http.StatusOK {
+		t.Errorf("healthz while paused = %d, want 200", code)
+	}
+
+	if code := serve("POST", "/api/poller/resume"); code != http.StatusOK {
+		t.Fatalf("resume = %d", code)
+	}
+	if env.srv.poller.Paused() || otherPoller.Paused() {
+		t.Error("a poller is still paused after resume")
+	}
+
+	if code := serve("POST", "/api/poller/run"); code != http.StatusAccepted {
+		t.Fatalf("run = %d, want 202", code)
+	}
+	for i, p := range []*poller.Poller{env.srv.poller, otherPoller} {
+		if queued, _ := p.ManualRun(); !queued {
+			t.Errorf("poller %d: manual run not queued", i)
+		}
+	}
+}
+
+func TestRunPollerWhilePending(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	// Poller not started: the first run stays queued.
+	req := httptest.NewRequest("POST", "/api/poller/run", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("first run status = %d, want 202", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/poller/run", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("second run status = %d, want 409", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/poller/status", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	var resp map[string]any
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["manual_run"] != true {
+		t.Errorf("manual_run = %v, want true", resp["manual_run"])
+	}
+	if _, ok := resp["last_triggered"]; !ok {
+		t.Error("last_triggered missing from status")
+	}
+}
+
+func TestRestorePR(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.db.AddPR(78)
+	env.db.UpdatePRStatus(78, "merged", "sha78", "Oops Deleted", "judy")
+	env.db.UpdateBranchLanded(78, "master")
+
+	var githubCalls atomic.Int32
+	env.ghMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		githubCalls.Add(1)
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/prs/78", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want 204", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/prs/78/restore", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("restore status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+
+	pr, err := env.db.GetPR(78)
+	if err != nil {
+		t.Fatalf("PR not tracked after restore: %v", err)
+	}
+	if pr.Title != "Oops Deleted" || pr.Status != "merged" || pr.MergeCommit != "sha78" {
+		t.Errorf("restored PR = %+v, want merged \"Oops Deleted\" at sha78", pr)
+	}
+	if len(pr.Branches) != 1 || pr.Branches[0].Branch != "master" || !pr.Branches[0].Landed {
+		t.Errorf("restored branches = %+v, want master landed", pr.Branches)
+	}
+	if n := githubCalls.Load(); n != 0 {
+		t.Errorf("GitHub calls = %d, want 0", n)
+	}
+
+	// The tombstone is consumed by the restore.
+	req = httptest.NewRequest("POST", "/api/prs/78/restore", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second restore status = %d, want 404", w.Code)
+	}
+}
+
+func TestRestorePRAlreadyTrackedKeepsTombstone(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.srv.tombstones[tombstoneKey{"", 80}] = tombstone{
+		pr:        db.TrackedPR{PRNumber: 80, Title: "Back Again", Status: "merged", MergeCommit: "sha80"},
+		removedAt: time.Now(),
+	}
+	env.db.AddPR(80)
+
+	req := httptest.NewRequest("POST", "/api/prs/80/restore", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("restore status = %d, want 409", w.Code)
+	}
+	if _, ok := env.srv.tombstones[tombstoneKey{"", 80}]; !ok {
+		t.Fatal("tombstone dropped by a rejected restore")
+	}
+
+	// Once the PR is gone again, the restore can be retried.
+	env.db.RemovePR(80)
+	req = httptest.NewRequest("POST", "/api/prs/80/restore", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("retried restore status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	if pr, err := env.db.GetPR(80); err != nil || pr.Title != "Back Again" {
+		t.Errorf("restored PR = %+v, %v, want \"Back Again\"", pr, err)
+	}
+	if _, ok := env.srv.tombstones[tombstoneKey{"", 80}]; ok {
+		t.Error("tombstone kept after a successful restore")
+	}
+}
+
+func TestRestorePRExpired(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.srv.tombstones[tombstoneKey{"", 79}] = tombstone{
+		pr:        db.TrackedPR{PRNumber: 79, Title: "Long Gone", Status: "open"},
+		removedAt: time.Now().Add(-restoreWindow - time.Minute),
+	}
+
+	req := httptest.NewRequest("POST", "/api/prs/79/restore", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if _, err := env.db.GetPR(79); err == nil {
+		t.Error("expired tombstone should not be restored")
+	}
+}
+
+func TestMatrixEndpoint(t *testing.T) {
+	env := setupTest(t, []string{"staging", "nixos-unstable"}, []string{"nixos-unstable"})
+
+	env.db.AddPR(10)
+	env.db.UpdatePRStatus(10, "merged", "sha10", "Partly Landed", "alice")
+	env.db.UpdateBranchLanded(10, "staging")
+	env.db.UpdateBranchLanded(10, "release-24.11") // no longer configured
+	env.db.UpdateBranchLastStatus(10, "nixos-unstable", "ahead")
+	env.db.AddPR(11)
+	env.db.UpdatePRStatus(11, "open", "", "Still Open", "bob")
+
+	req := httptest.NewRequest("GET", "/api/matrix", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp struct {
+		Branches []string `json:"branches"`
+		PRs      []struct {
+			PRNumber int    `json:"pr_number"`
+			Status   string `json:"status"`
+			Cells    []struct {
+				State      string     `json:"state"`
+				LandedAt   *time.Time `json:"landed_at"`
+				LastStatus string     `json:"last_status"`
+			} `json:"cells"`
+		} `json:"prs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if got := strings.Join(resp.Branches, ","); got != "staging,nixos-unstable,release-24.11" {
+		t.Errorf("branches = %s, want configured order then recorded extras", got)
+	}
+	if len(resp.PRs) != 2 {
+		t.Fatalf("got %d rows, want 2", len(resp.PRs))
+	}
+	want := map[int]string{
+		10: "landed,pending,landed",
+		11: "pending,pending,pending",
+	}
+	for _, row := range resp.PRs {
+		var states []string
+		for _, c := range row.Cells {
+			states = append(states, c.State)
+			if (c.State == "landed") != (c.LandedAt != nil) {
+				t.Errorf("PR %d cell %+v: landed_at should be set exactly for landed cells", row.PRNumber, c)
+			}
+		}
+		if got := strings.Join(states, ","); got != want[row.PRNumber] {
+			t.Errorf("PR %d cells = %s, want %s", row.PRNumber, got, want[row.PRNumber])
+		}
+		if row.PRNumber == 10 && row.Cells[1].LastStatus != "ahead" {
+			t.Errorf("PR 10 nixos-unstable last_status = %q, want ahead", row.Cells[1].LastStatus)
+		}
+	}
+}
+
+func TestFeedEndpoint(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	landed := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	env.db.AddEvent(db.EventRecord{Type: "pr_merged", PRNumber: 7, Title: "foo: 1 -> 2", CreatedAt: landed.Add(-time.Hour)})
+	env.db.AddEvent(db.EventRecord{Type: "pr_landed_branch", PRNumber: 7, Title: "foo: 1 -> 2", Branch: "nixos-unstable", CreatedAt: landed})
+	env.db.AddEvent(db.EventRecord{Type: "pr_fully_landed", PRNumber: 7, Title: "foo: 1 -> 2", CreatedAt: landed})
+
+	req := httptest.NewRequest("GET", "/api/feed.atom", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Content-Type = %q, want application/atom+xml", ct)
+	}
+
+	var feed struct {
+		XMLName xml.Name
+		ID      string `xml:"id"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Link    struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Summary string `xml:"summary"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("feed is not valid XML: %v\n%s", err, w.Body.String())
+	}
+	if feed.XMLName.Space != "http://www.w3.org/2005/Atom" || feed.XMLName.Local != "feed" {
+		t.Errorf("root = %v, want an Atom feed", feed.XMLName)
+	}
+	if feed.Updated != "2025-01-01T12:00:00Z" {
+		t.Errorf("updated = %q, want the newest landing", feed.Updated)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("entries = %d, want 2 (landings only)", len(feed.Entries))
+	}
+	ids := map[string]bool{}
+	var branchEntry bool
+	for _, e := range feed.Entries {
+		ids[e.ID] = true
+		if e.Title == "PR #7 landed in nixos-unstable" {
+			branchEntry = true
+			if e.Updated != "2025-01-01T12:00:00Z" || e.Summary != "foo: 1 -> 2" || e.Link.Href != "https://github.com/NixOS/nixpkgs/pull/7" {
+				t.Errorf("entry = %+v", e)
+			}
+		}
+	}
+	if !branchEntry {
+		t.Errorf("entries = %+v, want one for the nixos-unstable landing", feed.Entries)
+	}
+	if len(ids) != 2 {
+		t.Errorf("entry IDs = %v, want distinct IDs", ids)
+	}
+}
+
+func TestCheckEndpoint(t *testing.T) {
+	env := setupTest(t, []string{"master", "nixos-unstable"})
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/60", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 60, "title": "In Master", "user": map[string]any{"login": "alice"},
+			"state": "closed", "merged": true, "merge_commit_sha": "sha60",
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/master...sha60", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha60", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/61", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"number": 61, "title": "Still Open", "user": map[string]any{"login": "bob"},
+			"state": "open", "merged": false,
+		})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/62", http.NotFound)
+
+	var published atomic.Int32
+	env.bus.Subscribe(func(e event.Event) { published.Add(1) })
+
+	req := httptest.NewRequest("POST", "/api/check", strings.NewReader(`{"pr_numbers":[60,61,62]}`))
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Branches []string `json:"branches"`
+		PRs      []struct {
+			PRNumber int    `json:"pr_number"`
+			Title    string `json:"title"`
+			Status   string `json:"status"`
+			Error    string `json:"error"`
+			Cells    []struct {
+				State string `json:"state"`
+			} `json:"cells"`
+		} `json:"prs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got := strings.Join(resp.Branches, ","); got != "master,nixos-unstable" {
+		t.Errorf("branches = %s, want master,nixos-unstable", got)
+	}
+	if len(resp.PRs) != 3 {
+		t.Fatalf("got %d rows, want 3", len(resp.PRs))
+	}
+	want := []struct {
+		status, cells, err string
+	}{
+		{"merged", "landed,pending", ""},
+		{"open", "pending,pending", ""},
+		{"", "", "PR not found"},
+	}
+	for i, row := range resp.PRs {
+		var states []string
+		for _, c := range row.Cells {
+			states = append(states, c.State)
+		}
+		if row.Status != want[i].status || strings.Join(states, ",") != want[i].cells || row.Error != want[i].err {
+			t.Errorf("row %d = %+v, want status %q cells %q error %q", i, row, want[i].status, want[i].cells, want[i].err)
+		}
+	}
+
+	if prs, _ := env.db.ListPRs(); len(prs) != 0 {
+		t.Errorf("check stored %d PRs, want none", len(prs))
+	}
+	if n := published.Load(); n != 0 {
+		t.Errorf("check published %d events, want none", n)
+	}
+}
+
+func TestCheckEndpointRateLimited(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	reset := time.Now().Add(time.Minute).Unix()
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	req := httptest.NewRequest("POST", "/api/check", strings.NewReader(`{"pr_numbers":[60]}`))
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429; body: %s", w.Code, w.Body.String())
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 60 {
+		t.Errorf("Retry-After = %q, want 1..60 seconds", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestCheckEndpointValidation(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	tooMany := make([]string, maxCheckPRs+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i + 1)
+	}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{`},
+		{"empty", `{"pr_numbers":[]}`},
+		{"non-positive", `{"pr_numbers":[1,0]}`},
+		{"too many", `{"pr_numbers":[` + strings.Join(tooMany, ",") + `]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/check", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			env.router.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	env := setupTest(t, []string{"master", "nixos-unstable", "nixpkgs-unstable"}, []string{"nixos-unstable"})
+
+	req := httptest.NewRequest("GET", "/api/config", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+
+	var resp struct {
+		Branches []struct {
+			Name     string `json:"name"`
+			Required bool   `json:"required"`
+		} `json:"branches"`
+		NotificationBranches []string `json:"notification_branches"`
+		TargetBranches       []string `json:"target_branches"`
+		PollInterval         string   `json:"poll_interval"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(resp.Branches) != 3 {
+		t.Fatalf("branches = %+v, want 3", resp.Branches)
+	}
+	for _, b := range resp.Branches {
+		if want := b.Name == "nixos-unstable"; b.Required != want {
+			t.Errorf("branch %s required = %v, want %v", b.Name, b.Required, want)
+		}
+	}
+	if strings.Join(resp.NotificationBranches, ",") != "master,nixos-unstable,nixpkgs-unstable" {
+		t.Errorf("notification_branches = %v", resp.NotificationBranches)
+	}
+	if strings.Join(resp.TargetBranches, ",") != "nixos-unstable" {
+		t.Errorf("target_branches = %v", resp.TargetBranches)
+	}
+	if resp.PollInterval != "1h0m0s" {
+		t.Errorf("poll_interval = %q, want 1h0m0s", resp.PollInterval)
+	}
+
+	for _, secret := range []string{"token", "webhook", "proxy", "Bearer"} {
+		if strings.Contains(strings.ToLower(body), strings.ToLower(secret)) {
+			t.Errorf("response mentions %q: %s", secret, body)
+		}
+	}
+}
+
+func TestResetPR(t *testing.T) {
+	env := setupTest(t, []string{"master", "nixos-unstable"})
+
+	env.db.AddPR(81)
+	env.db.UpdatePRStatus(81, "merged", "sha81", "Reset Me", "kim")
+	env.db.UpdateBranchLanded(81, "master")
+
+	req := httptest.NewRequest("POST", "/api/prs/81/reset", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var pr db.TrackedPR
+	json.NewDecoder(w.Body).Decode(&pr)
+	if len(pr.Branches) != 0 || pr.Status != "merged" {
+		t.Errorf("after reset: status = %q, branches = %+v; want merged with none", pr.Status, pr.Branches)
+	}
+
+	var landed []string
+	env.bus.Subscribe(func(e event.Event) {
+		if e.Type == event.PRLandedBranch {
+			landed = append(landed, e.Branch)
+		}
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/master...sha81", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...sha81", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "ahead"})
+	})
+
+	req = httptest.NewRequest("POST", "/api/prs/81/refresh", nil)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	got, err := env.db.GetPR(81)
+	if err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	landedRows := make(map[string]bool)
+	for _, bs := range got.Branches {
+		landedRows[bs.Branch] = bs.Landed
+	}
+	if len(landedRows) != 2 || !landedRows["master"] || landedRows["nixos-unstable"] {
+		t.Errorf("branches after poll = %+v, want master re-recorded and nixos-unstable pending", got.Branches)
+	}
+	if strings.Join(landed, ",") != "master" {
+		t.Errorf("landed events = %v, want [master]", landed)
+	}
+}
+
+func TestResetPRNotTracked(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	for path, want := range map[string]int{
+		"/api/prs/999/reset": http.StatusNotFound,
+		"/api/prs/abc/reset": http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("POST %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestDebugConfigEndpoint(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	env.srv.SetDebugConfig(config.Config{
+		GitHubToken:    "ghp_secret",
+		WebhookURL:     "https://hooks.example.com/T000/B000/XXXX",
+		WebhookSecret:  "hunter2",
+		TargetBranches: []string{"nixos-unstable"},
+		PollInterval:   5 * time.Minute,
+	}.Redacted())
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/debug/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, secret := range []string{"ghp_secret", "hooks.example.com", "hunter2"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response leaks %q: %s", secret, body)
+		}
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	for _, field := range []string{"GitHubToken", "WebhookURL", "WebhookSecret"} {
+		if resp[field] != "***" {
+			t.Errorf("%s = %v, want ***", field, resp[field])
+		}
+	}
+	if resp["PollInterval"] != "5m0s" {
+		t.Errorf("PollInterval = %v, want 5m0s", resp["PollInterval"])
+	}
+	if branches, _ := resp["TargetBranches"].([]any); len(branches) != 1 || branches[0] != "nixos-unstable" {
+		t.Errorf("TargetBranches = %v, want [nixos-unstable]", resp["TargetBranches"])
+	}
+}
+
+func TestNewWithAuthAndCORS(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	srv := New(env.db, env.gh, env.bus, env.srv.poller,
+		WithBranches([]string{"nixos-unstable"}, []string{"nixos-unstable"}),
+		WithAuthToken("api-secret"),
+		WithCORS("https://dash.example.com"))
+	router := srv.Routes()
+
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/94", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"number": 94, "title": "Auth", "state": "open"})
+	})
+
+	add := func(auth string) int {
+		req := httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 94}`))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := add(""); code != http.StatusUnauthorized {
+		t.Errorf("add without token: status = %d, want 401", code)
+	}
+	if code := add("Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("add with wrong token: status = %d, want 401", code)
+	}
+	if code := add("Bearer api-secret"); code != http.StatusCreated {
+		t.Errorf("add with token: status = %d, want 201", code)
+	}
+
+	// Reads stay open.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/prs", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("list: status = %d, want 200", w.Code)
+	}
+
+	// Except the effective configuration.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/debug/config", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("debug config without token: status = %d, want 401", w.Code)
+	}
+	req := httptest.NewRequest("GET", "/api/debug/config", nil)
+	req.Header.Set("Authorization", "Bearer api-secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("debug config with token: status = %d, want 200", w.Code)
+	}
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/prs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := preflight("https://dash.example.com"); w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://dash.example.com" {
+		t.Errorf("allowed preflight: status = %d, headers = %v", w.Code, w.Header())
+	}
+	if w := preflight("https://evil.example.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin got Access-Control-Allow-Origin %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestSeparateAdmin(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+
+	env.srv.SetSeparateAdmin(true)
+	public := httptest.NewServer(env.srv.Routes())
+	defer public.Close()
+	admin := httptest.NewServer(env.srv.AdminRoutes())
+	defer admin.Close()
+
+	tests := []struct {
+		method    string
+		path      string
+		mainCode  int
+		adminCode int
+	}{
+		{"GET", "/healthz", http.StatusNotFound, http.StatusOK},
+		{"GET", "/api/poller/status", http.StatusNotFound, http.StatusOK},
+		{"POST", "/api/poller/pause", http.StatusMethodNotAllowed, http.StatusOK},
+		{"GET", "/api/prs", http.StatusOK, http.StatusNotFound},
+		{"GET", "/", http.StatusOK, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		for _, l := range []struct {
+			name string
+			url  string
+			want int
+		}{{"main", public.URL, tt.mainCode}, {"admin", admin.URL, tt.adminCode}} {
+			req, _ := http.NewRequest(tt.method, l.url+tt.path, nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s on %s: %v", tt.method, tt.path, l.name, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != l.want {
+				t.Errorf("%s %s on %s listener = %d, want %d", tt.method, tt.path, l.name, resp.StatusCode, l.want)
+			}
+		}
+	}
+	if !env.srv.poller.Paused() {
+		t.Error("poller not paused through the admin listener")
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	env := setupTest(t, []string{"nixos-unstable"})
+	env.db.AddPR(90)
+	env.db.UpdatePRStatus(90, "open", "", "Public", "lee")
+	env.ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/92", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"number": 92, "title": "Checked", "state": "open"})
+	})
+
+	env.srv.SetReadOnly(true)
+	router := env.srv.Routes()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"GET", "/api/prs", "", http.StatusOK},
+		{"GET", "/pr/90", "", http.StatusOK},
+		{"GET", "/api/poller/status", "", http.StatusOK},
+		{"POST", "/api/check", `{"pr_numbers": [92]}`, http.StatusOK},
+		{"POST", "/api/prs", `{"pr_number": 91}`, http.StatusForbidden},
+		{"DELETE", "/api/prs/90", "", http.StatusForbidden},
+		{"POST", "/api/prs/90/refresh", "", http.StatusForbidden},
+		{"POST", "/api/commits", `{"sha": "abc"}`, http.StatusForbidden},
+		{"POST", "/api/poller/pause", "", http.StatusForbidden},
+		{"PATCH", "/api/prs/90", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+
+	if _, err := env.db.GetPR(90); err != nil {
+		t.Errorf("PR 90 gone after rejected DELETE: %v", err)
+	}
+	if _, err := env.db.GetPR(91); err == nil {
+		t.Error("PR 91 added despite read-only mode")
+	}
+	if env.srv.poller.Paused() {
+		t.Error("poller paused despite read-only mode")
+	}
+
+	req := httptest.NewRequest("GET", "/api/config", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"read_only":true`) {
+		t.Errorf("/api/config = %s, want read_only true", w.Body.String())
+	}
+}
@@ -1,6 +1,9 @@
 package topology
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // NodeStatus represents the state of a branch in the pipeline.
 type NodeStatus string
@@ -129,3 +132,11 @@ func IsUpstreamOf(candidate, branch string) bool {
 		cur = parent
 	}
 }
+
+// IsStagingBranch reports whether branch is a staging-style base such as
+// staging, staging-next or a release's staging-24.11. A PR merged into one
+// only reaches master with a later staging-next merge, so its landings
+// typically lag a master PR's by days or weeks.
+func IsStagingBranch(branch string) bool {
+	return branch == "staging" || strings.HasPrefix(branch, "staging-")
+}
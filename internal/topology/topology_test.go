@@ -191,3 +191,25 @@ func TestIsUpstreamOf(t *testing.T) {
 		}
 	}
 }
+
+func TestIsStagingBranch(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   bool
+	}{
+		{"staging", true},
+		{"staging-next", true},
+		{"staging-24.11", true},
+		{"staging-next-24.11", true},
+		{"master", false},
+		{"release-24.11", false},
+		{"nixos-unstable", false},
+		{"stagingfoo", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsStagingBranch(tt.branch); got != tt.want {
+			t.Errorf("IsStagingBranch(%q) = %v, want %v", tt.branch, got, tt.want)
+		}
+	}
+}
@@ -3,13 +3,19 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/config"
 	"github.com/ningw42/nixpkgs-pr-tracker/internal/db"
@@ -24,6 +30,13 @@ import (
 var templateFS embed.FS
 
 func main() {
+	envFile := os.Getenv("NPT_ENV_FILE")
+	if envFile != "" {
+		if err := config.LoadEnvFile(envFile); err != nil {
+			log.Fatalf("loading %s: %v", envFile, err)
+		}
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("loading configuration: %v", err)
@@ -36,50 +49,72 @@ func main() {
 		log.Fatalf("invalid notification branches %v: %v", cfg.NotificationBranches, err)
 	}
 
-	database, err := db.New(cfg.DBPath)
+	a, err := newApp(cfg)
 	if err != nil {
-		log.Fatalf("opening database: %v", err)
+		log.Fatalf("%v", err)
 	}
-	defer database.Close()
+	defer a.db.Close()
 
-	ghClient := github.New(cfg.GitHubToken)
-	bus := event.New()
-
-	// Register notifiers
-	if cfg.WebhookURL != "" {
-		wh := notifier.NewWebhook(cfg.WebhookURL)
-		bus.Subscribe(func(e event.Event) {
-			if err := wh.Notify(context.Background(), e); err != nil {
-				log.Printf("webhook error: %v", err)
+	if cfg.VerifyBranches != "off" {
+		verifyCtx, verifyCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := verifyBranches(verifyCtx, a.gh, cfg.NotificationBranches, cfg.VerifyBranches == "fail")
+		for _, rc := range cfg.Repos {
+			if err != nil {
+				break
 			}
-		})
-		if u, err := url.Parse(cfg.WebhookURL); err == nil {
-			log.Printf("webhook notifier enabled: %s://%s/***", u.Scheme, u.Host)
-		} else {
-			log.Printf("webhook notifier enabled")
+			err = verifyBranches(verifyCtx, a.gh.ForRepo(rc.Repo), rc.Branches, cfg.VerifyBranches == "fail")
+		}
+		verifyCancel()
+		if err != nil {
+			log.Fatalf("%v", err)
 		}
-	} else {
-		log.Printf("webhook notifier disabled (NPT_WEBHOOK_URL not set)")
 	}
 
 	// Start poller
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	p := poller.New(database, ghClient, bus, cfg.PollInterval, cfg.NotificationBranches, cfg.TargetBranches)
-	p.Start(ctx)
+	a.poller.Start(ctx)
 	log.Printf("poller started (interval: %s, notification branches: %v, target branches: %v)", cfg.PollInterval, cfg.NotificationBranches, cfg.TargetBranches)
-
-	// Parse templates
-	tmpl := template.Must(template.ParseFS(templateFS, "web/templates/*.html"))
+	for i, rp := range a.repoPollers {
+		rp.Start(ctx)
+		log.Printf("poller started for %s (branches: %v)", cfg.Repos[i].Repo, cfg.Repos[i].Branches)
+	}
 
 	// Start HTTP server
-	srv := server.New(database, ghClient, bus, cfg.NotificationBranches, cfg.TargetBranches, tmpl)
-	httpServer := &http.Server{Addr: cfg.ListenAddr, Handler: srv.Routes()}
+	httpServer := &http.Server{Addr: cfg.ListenAddr, Handler: a.srv.Routes()}
+
+	// Reload on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func(cur config.Config) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				cur = reloadConfig(cur, envFile, a.poller, a.repoPollers, a.srv)
+			}
+		}
+	}(cfg)
+
+	var adminServer *http.Server
+	if cfg.AdminAddr != "" {
+		adminServer = &http.Server{Addr: cfg.AdminAddr, Handler: a.srv.AdminRoutes()}
+		go func() {
+			log.Printf("admin endpoints listening on %s", cfg.AdminAddr)
+			if err := adminServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("admin http server: %v", err)
+			}
+		}()
+	}
 
 	go func() {
 		<-ctx.Done()
 		log.Println("shutting down...")
+		if adminServer != nil {
+			adminServer.Shutdown(context.Background())
+		}
 		httpServer.Shutdown(context.Background())
 	}()
 
@@ -87,4 +122,418 @@ func main() {
 	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("http server: %v", err)
 	}
+
+	// Give notifications that are still being sent a chance to finish.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), notifierGracePeriod)
+	defer shutdownCancel()
+	if err := a.bus.Close(shutdownCtx); err != nil {
+		log.Printf("events still queued for notifiers after %s: %v", notifierGracePeriod, err)
+	}
+	for _, n := range a.notifiers {
+		if err := n.Shutdown(shutdownCtx); err != nil {
+			log.Printf("%s: in-flight notifications cut off after %s: %v", n.Name(), notifierGracePeriod, err)
+		}
+	}
+}
+
+// app holds the wired-up components of the tracker.
+type app struct {
+	db        *db.DB
+	gh        *github.Client
+	bus       *event.Bus
+	poller    *poller.Poller
+	srv       *server.Server
+	notifiers []*notifier.Graceful
+
+	// repoPollers poll the NPT_REPOS repositories, in config order.
+	repoPollers []*poller.Poller
+}
+
+// newApp builds the database, GitHub client, event bus, notifiers, poller
+// and HTTP handlers from cfg without starting anything. The caller closes
+// a.db.
+func newApp(cfg config.Config) (*app, error) {
+	dbOpts := []db.Option{db.WithMaxOpenConns(cfg.DBMaxOpenConns), db.WithMaxIdleConns(cfg.DBMaxIdleConns)}
+	if cfg.ArchiveOnRemove {
+		dbOpts = append(dbOpts, db.WithArchiveOnRemove())
+	}
+	database, err := db.New(cfg.DBPath, dbOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	var ghOpts []github.Option
+	var whOpts []notifier.WebhookOption
+	if cfg.HTTPProxy != "" {
+		proxyURL, err := url.Parse(cfg.HTTPProxy)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("invalid NPT_HTTP_PROXY: %w", err)
+		}
+		ghOpts = append(ghOpts, github.WithProxy(proxyURL))
+		whOpts = append(whOpts, notifier.WithProxy(proxyURL))
+		log.Printf("outbound requests use proxy %s://%s", proxyURL.Scheme, proxyURL.Host)
+	}
+
+	whOpts = append(whOpts, notifier.WithFormat(notifier.WebhookFormat(cfg.WebhookFormat)), notifier.WithTimeout(cfg.WebhookTimeout))
+	if cfg.InstanceName != "" {
+		whOpts = append(whOpts, notifier.WithInstance(cfg.InstanceName))
+	}
+	if cfg.WebhookSecret != "" {
+		whOpts = append(whOpts, notifier.WithSecret(cfg.WebhookSecret))
+	}
+	if cfg.WebhookInsecure {
+		whOpts = append(whOpts, notifier.WithInsecureSkipVerify())
+		log.Printf("WARNING: webhook TLS certificates are not verified (NPT_WEBHOOK_INSECURE_SKIP_VERIFY); only use this for receivers on a trusted network")
+	}
+	if cfg.WebhookBatch > 0 {
+		whOpts = append(whOpts, notifier.WithBatch(cfg.WebhookBatch))
+	}
+	if cfg.WebhookRetries > 0 {
+		whOpts = append(whOpts, notifier.WithRetries(cfg.WebhookRetries))
+	}
+
+	ghOpts = append(ghOpts, github.WithRepo(cfg.GitHubRepo), github.WithAPIVersion(cfg.GitHubAPIVersion), github.WithLandedStatuses(cfg.LandedStatuses))
+	ghClient := github.New(cfg.GitHubToken, ghOpts...)
+	// The daemon publishes asynchronously so slow notifiers (e.g. webhook
+	// retries) don't hold up the poller or API requests; shutdown drains it.
+	bus := event.NewAsync()
+
+	// Register notifiers
+	notifyTypes := notificationTypes(cfg)
+	if !cfg.NotifyOnAdd {
+		log.Printf("pr_added notifications disabled (NPT_NOTIFY_ON_ADD=false)")
+	}
+	if len(cfg.NotifyEvents) > 0 {
+		log.Printf("notifying only for %v (NPT_NOTIFY_EVENTS)", notifyTypes)
+	}
+
+	var suppressTypes []event.Type
+	for _, t := range cfg.SuppressAuthorEvents {
+		suppressTypes = append(suppressTypes, event.Type(t))
+	}
+	if cfg.SuppressAuthor != "" {
+		log.Printf("suppressing notifications for PRs by %s (NPT_SUPPRESS_AUTHOR)", cfg.SuppressAuthor)
+	}
+	// filter applies the notification settings to the webhook and desktop
+	// notifiers.
+	filter := func(n notifier.Notifier) notifier.Notifier {
+		n = notifier.NewFilter(n, notifyTypes)
+		if cfg.SuppressAuthor != "" {
+			n = notifier.NewAuthorFilter(n, cfg.SuppressAuthor, suppressTypes)
+		}
+		return n
+	}
+
+	var notifiers []*notifier.Graceful
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, subscribe(bus, filter(notifier.NewWebhook(cfg.WebhookURL, whOpts...))))
+		if u, err := url.Parse(cfg.WebhookURL); err == nil {
+			log.Printf("webhook notifier enabled: %s://%s/*** (format: %s)", u.Scheme, u.Host, cfg.WebhookFormat)
+		} else {
+			log.Printf("webhook notifier enabled")
+		}
+	} else {
+		log.Printf("webhook notifier disabled (NPT_WEBHOOK_URL not set)")
+	}
+	if cfg.DesktopNotify {
+		notifiers = append(notifiers, subscribe(bus, filter(notifier.NewDesktop(cfg.InstanceName))))
+		log.Printf("desktop notifier enabled")
+	}
+	if cfg.EventFile != "" {
+		fileOpts := []notifier.FileOption{notifier.WithMaxSize(cfg.EventFileMaxSize)}
+		if cfg.InstanceName != "" {
+			fileOpts = append(fileOpts, notifier.WithFileInstance(cfg.InstanceName))
+		}
+		// The file is an audit log, so it gets every event regardless of
+		// NPT_NOTIFY_ON_ADD.
+		notifiers = append(notifiers, subscribe(bus, notifier.NewFile(cfg.EventFile, fileOpts...)))
+		log.Printf("event file notifier enabled: %s", cfg.EventFile)
+	}
+	if cfg.NATSURL != "" {
+		var natsOpts []notifier.NATSOption
+		if cfg.InstanceName != "" {
+			natsOpts = append(natsOpts, notifier.WithNATSInstance(cfg.InstanceName))
+		}
+		// Like the event file, the stream is for machines and carries every
+		// event.
+		notifiers = append(notifiers, subscribe(bus, notifier.NewNATS(cfg.NATSURL, cfg.NATSSubject, natsOpts...)))
+		log.Printf("NATS notifier enabled: subject %s", cfg.NATSSubject)
+	}
+	if len(cfg.PersistEvents) > 0 {
+		log.Printf("events log records only %v (NPT_PERSIST_EVENTS)", cfg.PersistEvents)
+	}
+	recordEvents(bus, database, cfg.PersistEvents)
+
+	var pollerOpts []poller.Option
+	if cfg.LandingFallbackAfter > 0 {
+		pollerOpts = append(pollerOpts, poller.WithLandingFallback(cfg.LandingFallbackAfter))
+		log.Printf("commit-message landing fallback enabled after %s", cfg.LandingFallbackAfter)
+	}
+	if cfg.PollTimeout > 0 {
+		pollerOpts = append(pollerOpts, poller.WithPollTimeout(cfg.PollTimeout))
+	}
+	if cfg.CompareTimeout > 0 {
+		pollerOpts = append(pollerOpts, poller.WithCompareTimeout(cfg.CompareTimeout))
+	}
+	pollerOpts = append(pollerOpts, poller.WithBranchConcurrency(cfg.BranchConcurrency))
+	if cfg.PollJitter > 0 {
+		pollerOpts = append(pollerOpts, poller.WithJitter(cfg.PollJitter))
+	}
+	if cfg.DeferFirstPoll {
+		pollerOpts = append(pollerOpts, poller.WithDeferredFirstPoll())
+		log.Printf("first poll deferred until %s after startup", cfg.PollInterval)
+	}
+	if cfg.PollErrorBudget > 0 {
+		pollerOpts = append(pollerOpts, poller.WithErrorBudget(cfg.PollErrorBudget))
+	}
+	if cfg.RateReserve > 0 {
+		pollerOpts = append(pollerOpts, poller.WithRateReserve(cfg.RateReserve))
+	}
+	// Channel revisions and stages describe NPT_GITHUB_REPO's branches, so
+	// the NPT_REPOS pollers don't get them.
+	var primaryOpts []poller.Option
+	if cfg.ChannelRevisionURL != "" {
+		primaryOpts = append(primaryOpts, poller.WithChannelRevision(cfg.ChannelRevisionURL))
+		log.Printf("landings confirmed against channel revisions from %s", cfg.ChannelRevisionURL)
+	}
+	if len(cfg.Stages) > 0 {
+		primaryOpts = append(primaryOpts, poller.WithStages(cfg.Stages))
+		log.Printf("landings move through stages %v", cfg.Stages)
+	}
+	if cfg.NotifyChecks {
+		pollerOpts = append(pollerOpts, poller.WithChecksNotification())
+		log.Printf("check-run notifications enabled for open PRs")
+	}
+	if cfg.GroupLandings {
+		pollerOpts = append(pollerOpts, poller.WithGroupedLandings())
+	}
+	if cfg.CommentOnLand {
+		pollerOpts = append(pollerOpts, poller.WithCommentOnLand())
+		log.Printf("landings are commented on the PR")
+	}
+	if cfg.NotifyIncludeBody {
+		pollerOpts = append(pollerOpts, poller.WithPRBody())
+		log.Printf("notifications include PR descriptions")
+	}
+	if cfg.PRFailureThreshold > 0 {
+		pollerOpts = append(pollerOpts, poller.WithFailureThreshold(cfg.PRFailureThreshold, cfg.RemoveFailingPRs))
+		log.Printf("PRs failing %d polls in a row emit pr_error (remove: %t)", cfg.PRFailureThreshold, cfg.RemoveFailingPRs)
+	}
+	if cfg.EventRetention > 0 {
+		pollerOpts = append(pollerOpts, poller.WithEventRetention(cfg.EventRetention))
+	}
+	if cfg.SummaryAt != "" {
+		at, _ := time.Parse("15:04", cfg.SummaryAt) // validated by config.Load
+		pollerOpts = append(pollerOpts, poller.WithDailySummary(at.Hour(), at.Minute()))
+		log.Printf("daily summary sent at %s", cfg.SummaryAt)
+	}
+	if cfg.LandedRetention > 0 {
+		pollerOpts = append(pollerOpts, poller.WithLandedRetention(cfg.LandedRetention))
+		log.Printf("fully landed PRs are kept for %s before removal", cfg.LandedRetention)
+	}
+	if cfg.PruneClosedAfter > 0 {
+		pollerOpts = append(pollerOpts, poller.WithClosedPruning(cfg.PruneClosedAfter))
+		log.Printf("closed PRs older than %s are pruned at startup", cfg.PruneClosedAfter)
+	}
+	if cfg.ReopenCheckInterval > 0 {
+		pollerOpts = append(pollerOpts, poller.WithReopenCheck(cfg.ReopenCheckInterval))
+		log.Printf("closed PRs are re-checked for reopening every %s", cfg.ReopenCheckInterval)
+	}
+
+	p := poller.New(database, ghClient, bus, cfg.PollInterval, cfg.NotificationBranches, cfg.TargetBranches, append(slices.Clone(pollerOpts), primaryOpts...)...)
+
+	// Parse templates
+	tmpl := template.Must(template.ParseFS(templateFS, "web/templates/*.html"))
+
+	srvOpts := []server.Option{
+		server.WithBranches(cfg.NotificationBranches, cfg.TargetBranches),
+		server.WithTemplate(tmpl),
+	}
+	if cfg.APIToken != "" {
+		srvOpts = append(srvOpts, server.WithAuthToken(cfg.APIToken))
+		log.Printf("API writes require a bearer token")
+	}
+	if len(cfg.CORSOrigins) > 0 {
+		srvOpts = append(srvOpts, server.WithCORS(cfg.CORSOrigins...))
+	}
+	var repoPollers []*poller.Poller
+	for _, rc := range cfg.Repos {
+		repoDB, repoGH := database.ForRepo(rc.Repo), ghClient.ForRepo(rc.Repo)
+		rp := poller.New(repoDB, repoGH, bus, cfg.PollInterval, rc.Branches, rc.Branches, pollerOpts...)
+		repoPollers = append(repoPollers, rp)
+		srvOpts = append(srvOpts, server.WithRepo(rc.Repo, repoDB, repoGH, rp, rc.Branches))
+	}
+	srv := server.New(database, ghClient, bus, p, srvOpts...)
+	srv.SetIndexCacheTTL(cfg.IndexCacheTTL)
+	if cfg.ReadOnly {
+		srv.SetReadOnly(true)
+		log.Printf("read-only mode: API writes are rejected")
+	}
+	srv.SetSeparateAdmin(cfg.AdminAddr != "")
+	srv.SetIncludeBody(cfg.NotifyIncludeBody)
+	srv.SetTrackPaths(cfg.TrackPaths)
+	srv.SetGitHubWebhookSecret(cfg.GitHubWebhookSecret)
+	srv.SetDebugConfig(cfg.Redacted())
+
+	return &app{db: database, gh: ghClient, bus: bus, poller: p, srv: srv, notifiers: notifiers, repoPollers: repoPollers}, nil
+}
+
+// notifierGracePeriod is how long shutdown waits for in-flight notifications.
+const notifierGracePeriod = 10 * time.Second
+
+// subscribe delivers bus events to n, logging delivery failures and
+// publishing a NotificationFailed event for the other notifiers. Failures n
+// hits after Notify returns, such as a webhook batch sent on its timer, are
+// reported the same way. The returned wrapper lets shutdown wait for
+// deliveries in progress.
+func subscribe(bus *event.Bus, n notifier.Notifier) *notifier.Graceful {
+	g := notifier.NewGraceful(n)
+	failed := func(e event.Event, err error) {
+		log.Printf("%s error: %v", g.Name(), err)
+		// A failure to deliver a failure is only logged, so two broken
+		// notifiers can't keep reporting each other.
+		if e.Type == event.NotificationFailed || errors.Is(err, notifier.ErrShuttingDown) {
+			return
+		}
+		bus.Publish(event.Event{
+			Type:      event.NotificationFailed,
+			Repo:      e.Repo,
+			PRNumber:  e.PRNumber,
+			Title:     e.Title,
+			Notifier:  g.Name(),
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+	}
+	g.OnFailure(func(err error) { failed(event.Event{}, err) })
+	bus.Subscribe(func(e event.Event) {
+		if e.Type == event.NotificationFailed && e.Notifier == g.Name() {
+			return
+		}
+		if err := g.Notify(context.Background(), e); err != nil {
+			failed(e, err)
+		}
+	})
+	return g
+}
+
+// notificationTypes returns the event types notifiers should forward:
+// NPT_NOTIFY_EVENTS if set, otherwise every type, minus pr_added when
+// NPT_NOTIFY_ON_ADD is false.
+func notificationTypes(cfg config.Config) []event.Type {
+	types := event.Types
+	if len(cfg.NotifyEvents) > 0 {
+		types = make([]event.Type, len(cfg.NotifyEvents))
+		for i, t := range cfg.NotifyEvents {
+			types[i] = event.Type(t)
+		}
+	}
+	out := make([]event.Type, 0, len(types))
+	for _, t := range types {
+		if t == event.PRAdded && !cfg.NotifyOnAdd {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// verifyBranches checks that each branch exists on GitHub, logging a warning
+// for every missing one. With strict set, missing branches are an error.
+// Failures to reach GitHub are logged and never fatal.
+func verifyBranches(ctx context.Context, gh *github.Client, branches []string, strict bool) error {
+	var missing []string
+	for _, b := range branches {
+		ok, err := gh.BranchExists(ctx, b)
+		if err != nil {
+			log.Printf("warning: could not verify branch %q: %v", b, err)
+			continue
+		}
+		if !ok {
+			log.Printf("warning: branch %q does not exist on GitHub; every compare against it will fail", b)
+			missing = append(missing, b)
+		}
+	}
+	if strict && len(missing) > 0 {
+		return fmt.Errorf("configured branches do not exist on GitHub: %v", missing)
+	}
+	return nil
+}
+
+// recordEvents appends bus events to the events log: those of the given
+// types, or every event if types is empty.
+func recordEvents(bus *event.Bus, database *db.DB, types []string) {
+	bus.Subscribe(func(e event.Event) {
+		if len(types) > 0 && !slices.Contains(types, string(e.Type)) {
+			return
+		}
+		branch := e.Branch
+		if len(e.Branches) > 0 {
+			branch = strings.Join(e.Branches, ",")
+		}
+		err := database.AddEvent(db.EventRecord{
+			Repo:      e.Repo,
+			Type:      string(e.Type),
+			PRNumber:  e.PRNumber,
+			Title:     e.Title,
+			Author:    e.Author,
+			Branch:    branch,
+			Commit:    e.Commit,
+			CreatedAt: e.Timestamp,
+		})
+		if err != nil {
+			log.Printf("recording %s event: %v", e.Type, err)
+		}
+	})
+}
+
+// reloadConfig re-reads NPT_ENV_FILE (if set) and the environment, applies
+// the settings that can change at runtime, and logs the rest as ignored. p
+// polls NPT_GITHUB_REPO and repoPollers the NPT_REPOS repositories; the poll
+// interval applies to all of them, the branch lists only to p.
+func reloadConfig(cur config.Config, envFile string, p *poller.Poller, repoPollers []*poller.Poller, srv *server.Server) config.Config {
+	log.Printf("reloading configuration (SIGHUP)")
+	if envFile != "" {
+		if err := config.LoadEnvFile(envFile); err != nil {
+			log.Printf("reload: loading %s: %v; keeping current configuration", envFile, err)
+			return cur
+		}
+	}
+	next, err := config.Load()
+	if err != nil {
+		log.Printf("reload: %v; keeping current configuration", err)
+		return cur
+	}
+
+	if next.PollInterval != cur.PollInterval {
+		log.Printf("reload: poll interval %s -> %s", cur.PollInterval, next.PollInterval)
+		p.SetInterval(next.PollInterval)
+		for _, rp := range repoPollers {
+			rp.SetInterval(next.PollInterval)
+		}
+		cur.PollInterval = next.PollInterval
+	}
+
+	if !slices.Equal(next.NotificationBranches, cur.NotificationBranches) || !slices.Equal(next.TargetBranches, cur.TargetBranches) {
+		if err := config.ValidateBranches(append(slices.Clone(next.NotificationBranches), next.TargetBranches...)); err != nil {
+			log.Printf("reload: %v; keeping current branches", err)
+		} else {
+			log.Printf("reload: notification branches %v -> %v, target branches %v -> %v",
+				cur.NotificationBranches, next.NotificationBranches, cur.TargetBranches, next.TargetBranches)
+			p.SetBranches(next.NotificationBranches, next.TargetBranches)
+			srv.SetBranches(next.NotificationBranches, next.TargetBranches)
+			cur.NotificationBranches = next.NotificationBranches
+			cur.TargetBranches = next.TargetBranches
+		}
+	}
+
+	next.PollInterval = cur.PollInterval
+	next.NotificationBranches = cur.NotificationBranches
+	next.TargetBranches = cur.TargetBranches
+	if !reflect.DeepEqual(next, cur) {
+		log.Printf("reload: only NPT_POLL_INTERVAL and the branch lists are applied at runtime; other changes require a restart and were ignored")
+	}
+	srv.SetDebugConfig(cur.Redacted())
+	return cur
 }
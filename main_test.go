@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/config"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/db"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/event"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/github"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/notifier"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/poller"
+	"github.com/ningw42/nixpkgs-pr-tracker/internal/server"
+)
+
+func TestVerifyBranchesWarnsOnMissing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/NixOS/nixpkgs/branches/nixos-unstable", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"name": "nixos-unstable"})
+	})
+	mux.HandleFunc("/", http.NotFound)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	gh := github.New("")
+	gh.BaseURL = srv.URL
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	branches := []string{"nixos-unstable", "nixos-unstabel"}
+	if err := verifyBranches(context.Background(), gh, branches, false); err != nil {
+		t.Fatalf("verifyBranches (warn): %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `branch "nixos-unstabel" does not exist`) {
+		t.Errorf("log output %q does not warn about the missing branch", out)
+	}
+	if strings.Contains(out, `branch "nixos-unstable" does not exist`) {
+		t.Errorf("log output %q warns about an existing branch", out)
+	}
+
+	if err := verifyBranches(context.Background(), gh, branches, true); err == nil || !strings.Contains(err.Error(), "nixos-unstabel") {
+		t.Errorf("verifyBranches (strict) error = %v, want one naming nixos-unstabel", err)
+	}
+}
+
+func TestNotificationTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []event.Type
+	}{
+		{"default", config.Config{NotifyOnAdd: true}, event.Types},
+		{"only merged", config.Config{NotifyOnAdd: true, NotifyEvents: []string{"pr_merged"}}, []event.Type{event.PRMerged}},
+		{"merged and landed", config.Config{NotifyOnAdd: true, NotifyEvents: []string{"pr_merged", "pr_fully_landed"}}, []event.Type{event.PRMerged, event.PRFullyLanded}},
+		{"add disabled wins", config.Config{NotifyOnAdd: false, NotifyEvents: []string{"pr_added", "pr_merged"}}, []event.Type{event.PRMerged}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := notificationTypes(tt.cfg)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("notificationTypes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recorder is a Notifier that keeps every event it is sent.
+type recorder struct {
+	mu     sync.Mutex
+	events []event.Event
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Notify(ctx context.Context, e event.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestSubscribeNotificationFailed(t *testing.T) {
+	var mu sync.Mutex
+	var hookEvents []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Event string `json:"event"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		hookEvents = append(hookEvents, payload.Event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(hook.Close)
+
+	bus := event.New()
+	subscribe(bus, notifier.NewWebhook(hook.URL))
+	rec := &recorder{}
+	subscribe(bus, rec)
+
+	bus.Publish(event.Event{Type: event.PRMerged, PRNumber: 42, Title: "hello: 1.0 -> 2.0"})
+
+	if strings.Join(hookEvents, ",") != "pr_merged" {
+		t.Errorf("webhook received %v, want only [pr_merged]", hookEvents)
+	}
+	var failed []event.Event
+	for _, e := range rec.events {
+		if e.Type == event.NotificationFailed {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) != 1 {
+		t.Fatalf("got %d notification_failed events, want 1", len(failed))
+	}
+	if f := failed[0]; f.Notifier != "webhook" || f.PRNumber != 42 || !strings.Contains(f.Error, "500") {
+		t.Errorf("notification_failed = %+v, want webhook failure for PR 42 with status 500", f)
+	}
+}
+
+// Webhook URLs often embed a secret, so a delivery failure must not quote
+// the URL in the event other notifiers forward.
+func TestSubscribeNotificationFailedHidesURL(t *testing.T) {
+	hookURL := "http://127.0.0.1:1/hooks/s3cr3t-token" // port 1 — nothing listening
+
+	bus := event.New()
+	subscribe(bus, notifier.NewWebhook(hookURL))
+	rec := &recorder{}
+	subscribe(bus, rec)
+
+	bus.Publish(event.Event{Type: event.PRMerged, PRNumber: 42})
+
+	var failed []event.Event
+	for _, e := range rec.events {
+		if e.Type == event.NotificationFailed {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) != 1 {
+		t.Fatalf("got %d notification_failed events, want 1", len(failed))
+	}
+	if f := failed[0]; f.Error == "" || strings.Contains(f.Error, "s3cr3t-token") {
+		t.Errorf("notification_failed error = %q, want a failure without the webhook URL", f.Error)
+	}
+}
+
+func TestSubscribeBatchNotificationFailed(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(hook.Close)
+
+	bus := event.New()
+	subscribe(bus, notifier.NewFilter(notifier.NewWebhook(hook.URL, notifier.WithBatch(10*time.Millisecond)), event.Types))
+	rec := &recorder{}
+	subscribe(bus, rec)
+
+	bus.Publish(event.Event{Type: event.PRMerged, PRNumber: 42})
+
+	// The webhook accepts the event; the failure surfaces when the batch
+	// is sent.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec.mu.Lock()
+		var failed []event.Event
+		for _, e := range rec.events {
+			if e.Type == event.NotificationFailed {
+				failed = append(failed, e)
+			}
+		}
+		rec.mu.Unlock()
+		if len(failed) > 0 {
+			if f := failed[0]; f.Notifier != "webhook" || !strings.Contains(f.Error, "500") {
+				t.Errorf("notification_failed = %+v, want webhook batch failure with status 500", f)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failed batch never published notification_failed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecordEventsAllowlist(t *testing.T) {
+	database, err := db.New(t.TempDir() + "/tracker.db")
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	bus := event.New()
+	recordEvents(bus, database, []string{"pr_landed_branch"})
+
+	now := time.Now()
+	bus.Publish(event.Event{Type: event.PRAdded, PRNumber: 1, Timestamp: now})
+	bus.Publish(event.Event{Type: event.PRMerged, PRNumber: 1, Timestamp: now})
+	bus.Publish(event.Event{Type: event.PRLandedBranch, PRNumber: 1, Branch: "master", Timestamp: now})
+	bus.Publish(event.Event{Type: event.PRRemoved, PRNumber: 1, Timestamp: now})
+
+	events, err := database.ListEvents(10)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "pr_landed_branch" || events[0].Branch != "master" {
+		t.Errorf("recorded events = %+v, want only the pr_landed_branch event", events)
+	}
+}
+
+// TestAppEndToEnd wires up the real components against a mock GitHub and a
+// webhook receiver, tracks a PR through the API, lets it merge and land, and
+// checks the webhook sees the whole lifecycle in order.
+func TestReloadConfigIntervalAllRepos(t *testing.T) {
+	t.Setenv("NPT_TARGET_BRANCHES", "nixos-unstable")
+	t.Setenv("NPT_REPOS", "example/other=main")
+	t.Setenv("NPT_POLL_INTERVAL", "1h")
+	cur, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	database, err := db.New(t.TempDir() + "/tracker.db")
+	if err != nil {
+		t.Fatalf("db.New: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	gh := github.New("")
+	bus := event.New()
+	p := poller.New(database, gh, bus, time.Hour, cur.NotificationBranches, cur.TargetBranches)
+	rp := poller.New(database.ForRepo("example/other"), gh.ForRepo("example/other"), bus, time.Hour, []string{"main"}, []string{"main"})
+	srv := server.New(database, gh, bus, p)
+
+	t.Setenv("NPT_POLL_INTERVAL", "2m")
+	cur = reloadConfig(cur, "", p, []*poller.Poller{rp}, srv)
+
+	if cur.PollInterval != 2*time.Minute {
+		t.Errorf("PollInterval = %v, want 2m", cur.PollInterval)
+	}
+	for name, got := range map[string]time.Duration{"primary": p.Interval(), "example/other": rp.Interval()} {
+		if got != 2*time.Minute {
+			t.Errorf("%s poller interval = %v, want 2m", name, got)
+		}
+	}
+}
+
+// NPT_HTTP_PROXY sends both GitHub and webhook requests through the proxy.
+func TestAppHTTPProxy(t *testing.T) {
+	var mu sync.Mutex
+	var hosts []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute request URI.
+		mu.Lock()
+		hosts = append(hosts, r.URL.Host)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"number": 7, "user": map[string]any{"login": "x"}, "state": "open"})
+	}))
+	t.Cleanup(proxy.Close)
+
+	t.Setenv("NPT_DB_PATH", t.TempDir()+"/tracker.db")
+	t.Setenv("NPT_WEBHOOK_URL", "http://hooks.invalid/hook")
+	t.Setenv("NPT_TARGET_BRANCHES", "nixos-unstable")
+	t.Setenv("NPT_HTTP_PROXY", proxy.URL)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	a, err := newApp(cfg)
+	if err != nil {
+		t.Fatalf("newApp: %v", err)
+	}
+	t.Cleanup(func() { a.db.Close() })
+	a.gh.BaseURL = "http://api.github.invalid"
+
+	if _, err := a.gh.GetPR(context.Background(), 7); err != nil {
+		t.Fatalf("GetPR: %v", err)
+	}
+	if err := a.notifiers[0].Notify(context.Background(), event.Event{Type: event.PRAdded, PRNumber: 7}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"api.github.invalid", "hooks.invalid"}; !slices.Equal(hosts, want) {
+		t.Errorf("proxied hosts = %v, want %v", hosts, want)
+	}
+}
+
+func TestAppEndToEnd(t *testing.T) {
+	var ghMu sync.Mutex
+	merged := false
+	ghMux := http.NewServeMux()
+	ghMux.HandleFunc("/repos/NixOS/nixpkgs/pulls/42", func(w http.ResponseWriter, r *http.Request) {
+		ghMu.Lock()
+		defer ghMu.Unlock()
+		pr := map[string]any{
+			"number": 42, "title": "hello: 1.0 -> 2.0", "user": map[string]any{"login": "alice"},
+			"state": "open", "merged": false, "head": map[string]any{"sha": "headsha"},
+			"base": map[string]any{"ref": "master"},
+		}
+		if merged {
+			pr["state"], pr["merged"], pr["merge_commit_sha"] = "closed", true, "mergesha"
+		}
+		json.NewEncoder(w).Encode(pr)
+	})
+	ghMux.HandleFunc("/repos/NixOS/nixpkgs/compare/nixos-unstable...mergesha", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"status": "behind"})
+	})
+	ghMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected GitHub request %s", r.URL.Path)
+		http.NotFound(w, r)
+	})
+	ghSrv := httptest.NewServer(ghMux)
+	t.Cleanup(ghSrv.Close)
+
+	received := make(chan string, 16)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Event    string `json:"event"`
+			PRNumber int    `json:"pr_number"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decoding webhook body: %v", err)
+		}
+		if payload.PRNumber != 42 {
+			t.Errorf("webhook pr_number = %d, want 42", payload.PRNumber)
+		}
+		received <- payload.Event
+	}))
+	t.Cleanup(hook.Close)
+
+	t.Setenv("NPT_DB_PATH", t.TempDir()+"/tracker.db")
+	t.Setenv("NPT_WEBHOOK_URL", hook.URL)
+	t.Setenv("NPT_TARGET_BRANCHES", "nixos-unstable")
+	t.Setenv("NPT_POLL_INTERVAL", "1h")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		t.Fatalf("newApp: %v", err)
+	}
+	t.Cleanup(func() { a.db.Close() })
+	a.gh.BaseURL = ghSrv.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	a.poller.Start(ctx)
+	handler := a.srv.Routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/prs", strings.NewReader(`{"pr_number": 42}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/prs = %d: %s", rec.Code, rec.Body)
+	}
+
+	ghMu.Lock()
+	merged = true
+	ghMu.Unlock()
+
+	// The startup cycle may still be running; retry until the run is queued.
+	deadline := time.Now().Add(5 * time.Second)
+	for !a.poller.RunNow() {
+		if time.Now().After(deadline) {
+			t.Fatal("poller never accepted RunNow")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	want := []string{"pr_added", "pr_merged", "pr_landed_branch", "pr_fully_landed", "pr_removed"}
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case e := <-received:
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("webhook events = %v, want %v", got, want)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("webhook events = %v, want %v", got, want)
+	}
+
+	if _, err := a.db.GetPR(42); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetPR after landing: err = %v, want db.ErrNotFound", err)
+	}
+}